@@ -7,6 +7,9 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/devatherock/vela-template-tester/pkg/util"
 	"github.com/devatherock/vela-template-tester/pkg/validator"
@@ -14,13 +17,20 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-// Initializes log level
+// Restrictions and budgets applied to the templates supplied to the API
+var sandbox *validator.Sandbox
+var limits validator.Limits
+
+// Initializes log level, the template sandbox and limits
 func init() {
 	util.InitLogLevel()
+	sandbox = readSandbox()
+	limits = readLimits()
 }
 
 func main() {
 	http.HandleFunc("/api/expandTemplate", expandTemplate)
+	http.HandleFunc("/api/variables", listVariables)
 	http.HandleFunc("/api/health", checkHealth)
 
 	http.ListenAndServe(":"+lookupPort(), nil)
@@ -40,9 +50,11 @@ func expandTemplate(writer http.ResponseWriter, request *http.Request) {
 	// Parse request
 	validationRequest := validator.ValidationRequest{}
 	yaml.Unmarshal(requestBody, &validationRequest)
+	validationRequest.Sandbox = sandbox
+	validationRequest.Limits = limits
 
-	// Validate template
-	validationResponse := validator.Validate(validationRequest)
+	// Validate template. Processing stops if the client goes away or the template exceeds the limits
+	validationResponse := validator.Validate(request.Context(), validationRequest)
 
 	// Write response
 	responseBody, err := yaml.Marshal(&validationResponse)
@@ -53,11 +65,102 @@ func expandTemplate(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
+// Handles /api/variables endpoint. Lists the variables consumed by the supplied template
+func listVariables(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/x-yaml")
+
+	// Read request
+	requestBody, err := io.ReadAll(request.Body)
+	if err != nil {
+		return
+	}
+
+	// Parse request
+	validationRequest := validator.ValidationRequest{}
+	yaml.Unmarshal(requestBody, &validationRequest)
+
+	// List variables
+	variablesResponse := validator.ListVariables(validationRequest)
+
+	// Write response
+	responseBody, err := yaml.Marshal(&variablesResponse)
+	if err != nil {
+		log.Error("error: ", err)
+	} else {
+		writer.Write(responseBody)
+	}
+}
+
 // Handles /api/health endpoint. Indicates the health of the application
 func checkHealth(writer http.ResponseWriter, request *http.Request) {
 	writer.Write([]byte("UP"))
 }
 
+// Reads the template sandbox from SANDBOX_* environment variables, on top of the default sandbox
+func readSandbox() *validator.Sandbox {
+	sandbox := validator.DefaultSandbox()
+
+	if functions, ok := os.LookupEnv("SANDBOX_ALLOWED_FUNCTIONS"); ok {
+		sandbox.AllowedFunctions = splitFunctions(functions)
+	}
+	if functions, ok := os.LookupEnv("SANDBOX_DENIED_FUNCTIONS"); ok {
+		sandbox.DeniedFunctions = splitFunctions(functions)
+	}
+	if modules, ok := os.LookupEnv("SANDBOX_ALLOWED_MODULES"); ok {
+		sandbox.AllowedModules = splitFunctions(modules)
+	}
+
+	return sandbox
+}
+
+// Reads the template limits from SANDBOX_* environment variables, on top of the default limits
+func readLimits() validator.Limits {
+	limits := validator.DefaultLimits()
+
+	limits.MaxStarlarkSteps = uint64(lookupLimit("SANDBOX_MAX_STARLARK_STEPS", int(limits.MaxStarlarkSteps)))
+	limits.MaxRangeIterations = lookupLimit("SANDBOX_MAX_RANGE_ITERATIONS", limits.MaxRangeIterations)
+	limits.MaxOutputBytes = lookupLimit("SANDBOX_MAX_OUTPUT_BYTES", limits.MaxOutputBytes)
+
+	if value, ok := os.LookupEnv("SANDBOX_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Warnf("Invalid value '%s' for SANDBOX_TIMEOUT, using %s", value, limits.Timeout)
+		} else {
+			limits.Timeout = timeout
+		}
+	}
+
+	return limits
+}
+
+// Splits a comma separated list of function names
+func splitFunctions(functions string) []string {
+	names := []string{}
+	for _, name := range strings.Split(functions, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Reads a limit from an environment variable. Falls back to the default if it is not a positive number or zero
+func lookupLimit(variableName string, defaultLimit int) int {
+	value, ok := os.LookupEnv(variableName)
+	if !ok {
+		return defaultLimit
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Warnf("Invalid value '%s' for %s, using %d", value, variableName, defaultLimit)
+		return defaultLimit
+	}
+
+	return limit
+}
+
 // Reads port from PORT environment variable
 func lookupPort() string {
 	port, ok := os.LookupEnv("PORT")
@@ -91,7 +91,7 @@ func TestExpandTemplateError(test *testing.T) {
 				"notification_branch": "develop",
 			},
 			"Invalid template",
-			"Unable to parse template",
+			"template: test:4: unterminated quoted string",
 		},
 		{
 			"test/testdata/input_invalid_template.yml",
@@ -8,7 +8,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/devatherock/vela-template-tester/pkg/validator"
 	"github.com/devatherock/vela-template-tester/test/helper"
@@ -74,6 +76,43 @@ func TestExpandTemplate(test *testing.T) {
 	}
 }
 
+func TestExpandTemplatePipeline(test *testing.T) {
+	validationRequest := validator.ValidationRequest{}
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_pipeline.yml"))
+	validationRequest.Template = string(input)
+	validationRequest.Type = "pipeline"
+
+	goTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_template.yml"))
+	starlarkTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_starlark_template.py"))
+	validationRequest.Templates = map[string]string{
+		"notify": string(goTemplate),
+		"build":  string(starlarkTemplate),
+	}
+
+	yamlStr, _ := yaml.Marshal(&validationRequest)
+	request, _ := http.NewRequest("POST", "/api/expandTemplate", bytes.NewBuffer(yamlStr))
+
+	response := httptest.NewRecorder()
+	handler := http.HandlerFunc(expandTemplate)
+	handler.ServeHTTP(response, request)
+
+	assert.Equal(test, 200, response.Code)
+
+	validationResponse := validator.ValidationResponse{}
+	yaml.Unmarshal(response.Body.Bytes(), &validationResponse)
+	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
+	assert.Equal(test, "", validationResponse.Error)
+
+	expectedOutput, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/output_pipeline.yml"))
+	expectedOutputMap := make(map[interface{}]interface{})
+	yaml.Unmarshal([]byte(expectedOutput), &expectedOutputMap)
+
+	processedTemplateMap := make(map[interface{}]interface{})
+	yaml.Unmarshal([]byte(validationResponse.Template), &processedTemplateMap)
+
+	assert.Equal(test, expectedOutputMap, processedTemplateMap)
+}
+
 func TestExpandTemplateError(test *testing.T) {
 	cases := []struct {
 		inputFile     string
@@ -87,7 +126,7 @@ func TestExpandTemplateError(test *testing.T) {
 				"notification_branch": "develop",
 			},
 			"Invalid template",
-			"Unable to parse template",
+			"template: test:4: unterminated quoted string",
 		},
 		{
 			"test/testdata/input_invalid_template.yml",
@@ -121,6 +160,34 @@ func TestExpandTemplateError(test *testing.T) {
 	}
 }
 
+func TestExpandTemplateSchemaError(test *testing.T) {
+	validationRequest := validator.ValidationRequest{}
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_schema_error_template.yml"))
+	validationRequest.Template = string(input)
+	validationRequest.Parameters = map[string]interface{}{
+		"image": "alpine",
+	}
+
+	yamlStr, _ := yaml.Marshal(&validationRequest)
+	request, _ := http.NewRequest("POST", "/api/expandTemplate", bytes.NewBuffer(yamlStr))
+
+	response := httptest.NewRecorder()
+	handler := http.HandlerFunc(expandTemplate)
+	handler.ServeHTTP(response, request)
+
+	assert.Equal(test, 200, response.Code)
+
+	validationResponse := validator.ValidationResponse{}
+	yaml.Unmarshal(response.Body.Bytes(), &validationResponse)
+	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
+	assert.Equal(test, []validator.SchemaViolation{
+		{Path: "steps[0].ruleset.branch[1]", Message: "expected string, found integer"},
+		{Path: "steps[1].name", Message: "is required"},
+		{Path: "steps[1].pull", Message: "expected string, found boolean"},
+		{Path: "steps[1]", Message: "no commands, environment, parameters, secrets or template provided"},
+	}, validationResponse.SchemaErrors)
+}
+
 func TestExpandTemplateListParams(test *testing.T) {
 	validationRequest := validator.ValidationRequest{}
 	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/list_parameters_template.yml"))
@@ -162,6 +229,101 @@ func TestExpandTemplateListParams(test *testing.T) {
 	assert.Equal(test, expectedOutputMap, processedTemplateMap)
 }
 
+func TestExpandTemplateSandboxed(test *testing.T) {
+	items := make([]interface{}, 20000)
+	cases := []struct {
+		template     string
+		templateType string
+		parameters   map[string]interface{}
+		expected     string
+	}{
+		{
+			"home: {{ env \"HOME\" }}",
+			"",
+			nil,
+			"template: test:1: function \"env\" is not allowed",
+		},
+		{
+			"items:\n{{- range until 20000 }}\n  - {{ . }}\n{{- end }}",
+			"",
+			nil,
+			"template: test:2: function \"until\" is not allowed",
+		},
+		{
+			"content: {{ repeat 2000000 \"a\" }}",
+			"",
+			nil,
+			"template: test:1: function \"repeat\" is not allowed",
+		},
+		{
+			"items:\n{{- range .items }}\n  - {{ . }}\n{{- end }}",
+			"",
+			map[string]interface{}{"items": items},
+			"range iterations exceed the limit of 10000",
+		},
+		{
+			"content: {{ .content }}{{ .content }}",
+			"",
+			map[string]interface{}{"content": strings.Repeat("a", 600000)},
+			"template output exceeds the limit of 1048576 bytes",
+		},
+		{
+			"load('http.star', 'http')\n\ndef main(ctx):\n  return {'steps': []}",
+			"starlark",
+			nil,
+			"cannot load http.star: module 'http.star' is not allowed",
+		},
+	}
+
+	for _, data := range cases {
+		yamlStr, _ := yaml.Marshal(&validator.ValidationRequest{Template: data.template, Type: data.templateType, Parameters: data.parameters})
+		request, _ := http.NewRequest("POST", "/api/expandTemplate", bytes.NewBuffer(yamlStr))
+
+		response := httptest.NewRecorder()
+		handler := http.HandlerFunc(expandTemplate)
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(test, 200, response.Code)
+
+		validationResponse := validator.ValidationResponse{}
+		yaml.Unmarshal(response.Body.Bytes(), &validationResponse)
+		assert.Equal(test, "Invalid template", validationResponse.Message)
+		assert.Equal(test, data.expected, validationResponse.Error)
+	}
+}
+
+func TestListVariables(test *testing.T) {
+	validationRequest := validator.ValidationRequest{}
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_starlark_template.py"))
+	validationRequest.Template = string(input)
+	validationRequest.Type = "starlark"
+
+	yamlStr, _ := yaml.Marshal(&validationRequest)
+	request, _ := http.NewRequest("POST", "/api/variables", bytes.NewBuffer(yamlStr))
+
+	response := httptest.NewRecorder()
+	handler := http.HandlerFunc(listVariables)
+	handler.ServeHTTP(response, request)
+
+	assert.Equal(test, 200, response.Code)
+	assert.Equal(test, "message: template variables\nvariables:\n- name: image\n  lines:\n  - 7\n", response.Body.String())
+}
+
+func TestListVariablesError(test *testing.T) {
+	request, _ := http.NewRequest("POST", "/api/variables", bytes.NewBufferString("template: '{{ .name | shout }}'"))
+
+	response := httptest.NewRecorder()
+	handler := http.HandlerFunc(listVariables)
+	handler.ServeHTTP(response, request)
+
+	assert.Equal(test, 200, response.Code)
+
+	variablesResponse := validator.VariablesResponse{}
+	yaml.Unmarshal(response.Body.Bytes(), &variablesResponse)
+	assert.Equal(test, "Invalid template", variablesResponse.Message)
+	assert.Equal(test, "template: test:1: function \"shout\" not defined", variablesResponse.Error)
+}
+
 func TestCheckHealth(test *testing.T) {
 	request, _ := http.NewRequest("GET", "/api/health", nil)
 
@@ -182,3 +344,39 @@ func TestLookupPortEnvVariablePresent(test *testing.T) {
 func TestLookupPortEnvVariableAbsent(test *testing.T) {
 	assert.Equal(test, "8080", lookupPort())
 }
+
+func TestReadSandbox(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "SANDBOX_ALLOWED_FUNCTIONS", "quote, default,")
+	helper.SetEnvironmentVariable(test, "SANDBOX_DENIED_FUNCTIONS", "")
+	helper.SetEnvironmentVariable(test, "SANDBOX_ALLOWED_MODULES", "encoding/json.star")
+
+	assert.Equal(test, &validator.Sandbox{
+		AllowedFunctions: []string{"quote", "default"},
+		DeniedFunctions:  []string{},
+		AllowedModules:   []string{"encoding/json.star"},
+	}, readSandbox())
+}
+
+func TestReadSandboxDefault(test *testing.T) {
+	assert.Equal(test, validator.DefaultSandbox(), readSandbox())
+}
+
+func TestReadLimits(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "SANDBOX_MAX_STARLARK_STEPS", "5000")
+	helper.SetEnvironmentVariable(test, "SANDBOX_MAX_OUTPUT_BYTES", "2048")
+	helper.SetEnvironmentVariable(test, "SANDBOX_MAX_RANGE_ITERATIONS", "many")
+	helper.SetEnvironmentVariable(test, "SANDBOX_TIMEOUT", "2s")
+
+	assert.Equal(test, validator.Limits{
+		MaxStarlarkSteps:   5000,
+		MaxRangeIterations: 10000,
+		MaxOutputBytes:     2048,
+		Timeout:            2 * time.Second,
+	}, readLimits())
+}
+
+func TestReadLimitsInvalidTimeout(test *testing.T) {
+	helper.SetEnvironmentVariable(test, "SANDBOX_TIMEOUT", "10")
+
+	assert.Equal(test, validator.DefaultLimits(), readLimits())
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/devatherock/vela-template-tester/pkg/validator"
+)
+
+// Formats of the coverage reports
+const (
+	coverageText = "text"
+	coverageJson = "json"
+	coverageHtml = "html"
+)
+
+// Branch coverage of a template across all of its tests
+type templateCoverage struct {
+	InputFile string                     `json:"input_file"`
+	Coverage  float64                    `json:"coverage"` // Percentage of the branches taken
+	Covered   int                        `json:"covered"`
+	Total     int                        `json:"total"`
+	Branches  []validator.BranchCoverage `json:"branches"`
+}
+
+// Branch coverage of all the tested templates, in the order the templates were first tested
+type coverageReport struct {
+	Coverage  float64             `json:"coverage"`
+	Covered   int                 `json:"covered"`
+	Total     int                 `json:"total"`
+	Templates []*templateCoverage `json:"templates"`
+}
+
+// Adds the branches taken by a test of a template to the coverage of the template. The branches of the
+// templates referenced by a pipeline are added to the coverage of their files, in 'templateFiles' by name
+func (report *coverageReport) add(inputFile string, templateFiles map[string]string, branches []validator.BranchCoverage) {
+	for _, branch := range branches {
+		branchFile := inputFile
+		if branch.Template != "" {
+			branchFile = templateFiles[branch.Template]
+			branch.Template = ""
+		}
+
+		coverage := report.templateCoverage(branchFile)
+
+		index := coverage.indexOf(branch)
+		if index < 0 {
+			coverage.Branches = append(coverage.Branches, branch)
+			continue
+		}
+		coverage.Branches[index].Hits += branch.Hits
+	}
+}
+
+// Returns the coverage of a template file, adding it if the file was not tested before
+func (report *coverageReport) templateCoverage(inputFile string) *templateCoverage {
+	for _, coverage := range report.Templates {
+		if coverage.InputFile == inputFile {
+			return coverage
+		}
+	}
+
+	coverage := &templateCoverage{InputFile: inputFile}
+	report.Templates = append(report.Templates, coverage)
+	return coverage
+}
+
+func (coverage *templateCoverage) indexOf(branch validator.BranchCoverage) int {
+	for index, existingBranch := range coverage.Branches {
+		if existingBranch.Statement == branch.Statement && existingBranch.Branch == branch.Branch &&
+			existingBranch.Line == branch.Line && existingBranch.Column == branch.Column {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Counts the covered branches of each template and of all templates
+func (report *coverageReport) summarize() {
+	report.Covered, report.Total = 0, 0
+	for _, coverage := range report.Templates {
+		coverage.Covered, coverage.Total = 0, len(coverage.Branches)
+		for _, branch := range coverage.Branches {
+			if branch.Hits > 0 {
+				coverage.Covered++
+			}
+		}
+		coverage.Coverage = coveragePercentage(coverage.Covered, coverage.Total)
+
+		report.Covered += coverage.Covered
+		report.Total += coverage.Total
+	}
+	report.Coverage = coveragePercentage(report.Covered, report.Total)
+}
+
+// Percentage of covered branches, rounded to one decimal. Templates without branches are fully covered
+func coveragePercentage(covered int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+
+	return math.Round(float64(covered)*1000/float64(total)) / 10
+}
+
+// Summary of the coverage of each template, along with the branches that were not taken
+func (report *coverageReport) text() string {
+	lines := []string{fmt.Sprintf("Branch coverage: %.1f%% (%d of %d branches)", report.Coverage, report.Covered, report.Total)}
+	for _, coverage := range report.Templates {
+		lines = append(lines, fmt.Sprintf("  %s: %.1f%% (%d of %d branches)", coverage.InputFile, coverage.Coverage, coverage.Covered, coverage.Total))
+		for _, branch := range coverage.Branches {
+			if branch.Hits == 0 {
+				lines = append(lines, fmt.Sprintf("    line %d, column %d: %s branch of %s not taken", branch.Line, branch.Column, branch.Branch, branch.Statement))
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Writes the coverage to each of the comma separated report files. The format of a report is specified as a prefix
+// like 'html:coverage.html' or derived from the extension: '.txt' for text, '.json' for json and '.html' for html
+func writeCoverageReports(reports string, report *coverageReport) error {
+	for _, reportFile := range strings.Split(reports, ",") {
+		format, reportFile, error := parseCoverageReport(strings.TrimSpace(reportFile))
+		if error != nil {
+			return error
+		}
+
+		var content []byte
+		switch format {
+		case coverageText:
+			content = []byte(report.text() + "\n")
+		case coverageJson:
+			content, error = json.MarshalIndent(report, "", "  ")
+			content = append(content, '\n')
+		default:
+			content, error = htmlCoverageReport(report)
+		}
+		if error != nil {
+			return error
+		}
+
+		error = os.WriteFile(reportFile, content, 0644)
+		if error != nil {
+			return error
+		}
+	}
+
+	return nil
+}
+
+// Returns the format and the file of a coverage report
+func parseCoverageReport(report string) (string, string, error) {
+	if format, reportFile, found := strings.Cut(report, ":"); found {
+		switch format {
+		case coverageText, coverageJson, coverageHtml:
+			return format, reportFile, nil
+		}
+	}
+
+	switch filepath.Ext(report) {
+	case ".txt":
+		return coverageText, report, nil
+	case ".json":
+		return coverageJson, report, nil
+	case ".html", ".htm":
+		return coverageHtml, report, nil
+	}
+
+	return "", "", fmt.Errorf("unknown format of coverage report '%s'. Prefix the file with 'text:', 'json:' or 'html:'", report)
+}
+
+// A line of a template in the html report, with the branches of the statements on it
+type htmlCoverageLine struct {
+	Number   int
+	Source   string
+	Branches string
+	Status   string // 'covered', 'partial' or 'uncovered' for lines with branches
+}
+
+var htmlCoverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Branch coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 8px; vertical-align: top; white-space: pre; }
+td.number { color: #888; text-align: right; }
+td.branches { color: #555; }
+tr.covered { background: #dfd; }
+tr.partial { background: #ffd; }
+tr.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Branch coverage: {{printf "%.1f" .Coverage}}% ({{.Covered}} of {{.Total}} branches)</h1>
+{{- range .Templates}}
+<h2>{{.InputFile}}: {{printf "%.1f" .Coverage}}% ({{.Covered}} of {{.Total}} branches)</h2>
+<table>
+{{- range .Lines}}
+<tr{{if .Status}} class="{{.Status}}"{{end}}><td class="number">{{.Number}}</td><td class="branches">{{.Branches}}</td><td>{{.Source}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// Builds an html report with the source of each template, highlighting the lines with branches that were taken or not
+func htmlCoverageReport(report *coverageReport) ([]byte, error) {
+	type htmlTemplateCoverage struct {
+		*templateCoverage
+		Lines []htmlCoverageLine
+	}
+
+	templates := make([]htmlTemplateCoverage, len(report.Templates))
+	for index, coverage := range report.Templates {
+		content, error := os.ReadFile(coverage.InputFile)
+		if error != nil {
+			return nil, error
+		}
+
+		sourceLines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		lines := make([]htmlCoverageLine, len(sourceLines))
+		for lineIndex, source := range sourceLines {
+			lines[lineIndex] = htmlCoverageLine{Number: lineIndex + 1, Source: source}
+		}
+
+		for _, branch := range coverage.Branches {
+			if branch.Line < 1 || branch.Line > len(lines) {
+				continue
+			}
+
+			line := &lines[branch.Line-1]
+			if line.Branches != "" {
+				line.Branches += ", "
+			}
+			line.Branches += fmt.Sprintf("%s %s: %d", branch.Statement, branch.Branch, branch.Hits)
+			line.Status = branchLineStatus(line.Status, branch.Hits > 0)
+		}
+		templates[index] = htmlTemplateCoverage{coverage, lines}
+	}
+
+	output := &bytes.Buffer{}
+	error := htmlCoverageTemplate.Execute(output, struct {
+		*coverageReport
+		Templates []htmlTemplateCoverage
+	}{report, templates})
+	if error != nil {
+		return nil, error
+	}
+
+	return output.Bytes(), nil
+}
+
+// Status of a line after adding a branch that was or wasn't taken
+func branchLineStatus(status string, taken bool) string {
+	switch {
+	case status == "" && taken:
+		return "covered"
+	case status == "":
+		return "uncovered"
+	case (status == "covered") != taken:
+		return "partial"
+	}
+
+	return status
+}
@@ -0,0 +1,183 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/pkg/validator"
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCoverageReport(test *testing.T) {
+	report := &coverageReport{}
+	report.add("build.yml", nil, []validator.BranchCoverage{
+		{Statement: "if", Branch: "then", Line: 3, Column: 8, Hits: 1},
+		{Statement: "if", Branch: "else", Line: 3, Column: 8, Hits: 0},
+	})
+	report.add("deploy.star", nil, []validator.BranchCoverage{
+		{Statement: "if", Branch: "then", Line: 2, Column: 3, Hits: 0},
+		{Statement: "if", Branch: "else", Line: 2, Column: 3, Hits: 0},
+	})
+	report.add("notify.yml", nil, nil)
+	// Branches of a template referenced by a pipeline count towards the template
+	report.add(".vela.yml", map[string]string{"build": "build.yml"}, []validator.BranchCoverage{
+		{Statement: "if", Branch: "then", Line: 3, Column: 8, Hits: 0, Template: "build"},
+		{Statement: "if", Branch: "else", Line: 3, Column: 8, Hits: 2, Template: "build"},
+	})
+	report.summarize()
+
+	assert.Equal(test, 50.0, report.Coverage)
+	assert.Equal(test, 2, report.Covered)
+	assert.Equal(test, 4, report.Total)
+	assert.Equal(test, []*templateCoverage{
+		{
+			InputFile: "build.yml",
+			Coverage:  100,
+			Covered:   2,
+			Total:     2,
+			Branches: []validator.BranchCoverage{
+				{Statement: "if", Branch: "then", Line: 3, Column: 8, Hits: 1},
+				{Statement: "if", Branch: "else", Line: 3, Column: 8, Hits: 2},
+			},
+		},
+		{
+			InputFile: "deploy.star",
+			Coverage:  0,
+			Covered:   0,
+			Total:     2,
+			Branches: []validator.BranchCoverage{
+				{Statement: "if", Branch: "then", Line: 2, Column: 3, Hits: 0},
+				{Statement: "if", Branch: "else", Line: 2, Column: 3, Hits: 0},
+			},
+		},
+	}, report.Templates)
+
+	assert.Equal(test, `Branch coverage: 50.0% (2 of 4 branches)
+  build.yml: 100.0% (2 of 2 branches)
+  deploy.star: 0.0% (0 of 2 branches)
+    line 2, column 3: then branch of if not taken
+    line 2, column 3: else branch of if not taken`, report.text())
+}
+
+func TestCoveragePercentage(test *testing.T) {
+	assert.Equal(test, 66.7, coveragePercentage(2, 3))
+	assert.Equal(test, 0.0, coveragePercentage(0, 3))
+	assert.Equal(test, 100.0, coveragePercentage(0, 0))
+}
+
+func TestParseCoverageReport(test *testing.T) {
+	cases := []struct {
+		report         string
+		expectedFormat string
+		expectedFile   string
+	}{
+		{"coverage.txt", coverageText, "coverage.txt"},
+		{"build/coverage.json", coverageJson, "build/coverage.json"},
+		{"coverage.html", coverageHtml, "coverage.html"},
+		{"coverage.htm", coverageHtml, "coverage.htm"},
+		{"text:coverage.log", coverageText, "coverage.log"},
+		{"html:build/coverage", coverageHtml, "build/coverage"},
+	}
+
+	for _, data := range cases {
+		format, reportFile, err := parseCoverageReport(data.report)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expectedFormat, format)
+		assert.Equal(test, data.expectedFile, reportFile)
+	}
+
+	_, _, err := parseCoverageReport("junit:coverage.xml")
+	assert.EqualError(test, err, "unknown format of coverage report 'junit:coverage.xml'. Prefix the file with 'text:', 'json:' or 'html:'")
+}
+
+func TestHtmlCoverageReport(test *testing.T) {
+	report := &coverageReport{}
+	report.add(helper.AbsolutePath("test/testdata/input_coverage_template.yml"), nil, []validator.BranchCoverage{
+		{Statement: "if", Branch: "then", Line: 3, Column: 18, Hits: 1},
+		{Statement: "if", Branch: "else", Line: 3, Column: 18, Hits: 1},
+		{Statement: "range", Branch: "then", Line: 5, Column: 11, Hits: 0},
+		{Statement: "range", Branch: "else", Line: 5, Column: 11, Hits: 2},
+		{Statement: "if", Branch: "then", Line: 10, Column: 8, Hits: 0},
+		{Statement: "if", Branch: "else", Line: 10, Column: 8, Hits: 0},
+	})
+	report.summarize()
+
+	actual, err := htmlCoverageReport(report)
+	assert.Nil(test, err)
+	assert.Contains(test, string(actual), "<h1>Branch coverage: 50.0% (3 of 6 branches)</h1>")
+	assert.Contains(test, string(actual), `<tr><td class="number">2</td><td class="branches"></td><td>  - name: build</td></tr>`)
+	assert.Contains(test, string(actual), `<tr class="covered"><td class="number">3</td><td class="branches">if then: 1, if else: 1</td>`+
+		`<td>    image: {{ if eq .language &#34;java&#34; }}openjdk:17{{ else }}golang:1.23{{ end }}</td></tr>`)
+	assert.Contains(test, string(actual), `<tr class="partial"><td class="number">5</td><td class="branches">range then: 0, range else: 2</td>`)
+	assert.Contains(test, string(actual), `<tr class="uncovered"><td class="number">10</td><td class="branches">if then: 0, if else: 0</td>`)
+
+	report.Templates[0].InputFile = helper.AbsolutePath("test/testdata/missing.yml")
+	_, err = htmlCoverageReport(report)
+	assert.NotNil(test, err)
+}
+
+func TestBranchLineStatus(test *testing.T) {
+	cases := []struct {
+		status   string
+		taken    bool
+		expected string
+	}{
+		{"", true, "covered"},
+		{"", false, "uncovered"},
+		{"covered", true, "covered"},
+		{"covered", false, "partial"},
+		{"uncovered", false, "uncovered"},
+		{"uncovered", true, "partial"},
+		{"partial", true, "partial"},
+		{"partial", false, "partial"},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, branchLineStatus(data.status, data.taken))
+	}
+}
+
+func TestRunWithCoverage(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportFile := filepath.Join(test.TempDir(), "coverage.json")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_coverage_template.yml"), "")
+	set.String("matrix", `{"language":["java","go"],"include":[{"commands":["make test"],"notify":true}]}`, "")
+	set.String("coverage", reportFile, "")
+	set.String("coverage-threshold", "100", "")
+	set.String("concurrency", "2", "")
+
+	assert.Nil(test, run(cli.NewContext(nil, set, nil)))
+	assert.Equal(test, -1, exitCode[0])
+
+	content, _ := ioutil.ReadFile(reportFile)
+	report := coverageReport{}
+	assert.Nil(test, json.Unmarshal(content, &report))
+	assert.Equal(test, 100.0, report.Coverage)
+	assert.Equal(test, 6, report.Total)
+	assert.Equal(test, helper.AbsolutePath("test/testdata/input_coverage_template.yml"), report.Templates[0].InputFile)
+	assert.Equal(test, validator.BranchCoverage{Statement: "if", Branch: "else", Line: 3, Column: 18, Hits: 2}, report.Templates[0].Branches[1])
+}
+
+func TestRunWithCoverageBelowThreshold(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_coverage_template.yml"), "")
+	set.String("matrix", `{"language":["java","go"]}`, "")
+	set.String("coverage-threshold", "90", "")
+
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.EqualError(test, actual, "Branch coverage of 66.7% is below the threshold of 90.0%")
+	assert.Equal(test, 1, exitCode[0])
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/devatherock/vela-template-tester/pkg/validator"
+	"gopkg.in/yaml.v2"
+)
+
+// Suffix of the manifest files picked up from the directory to discover
+const manifestSuffix = ".template-test.yml"
+
+// Placeholder in the expected output that is replaced with the directory of each template matching a glob
+const templateDirPlaceholder = "{template_dir}"
+
+// A yaml file that lists templates to test, in the same format as the 'templates' parameter. Paths are relative to the manifest
+type manifest struct {
+	Templates []PluginValidationRequest `json:"templates"`
+}
+
+// Reads the templates to test from a manifest file
+func readManifest(manifestFile string) ([]PluginValidationRequest, error) {
+	content, error := os.ReadFile(manifestFile)
+	if error != nil {
+		return nil, error
+	}
+
+	// Converted to json, so that the templates are read the same way as the 'templates' parameter
+	var document interface{}
+	error = yaml.Unmarshal(content, &document)
+	if error != nil {
+		return nil, fmt.Errorf("invalid manifest '%s': %s", manifestFile, error.Error())
+	}
+
+	jsonContent, error := json.Marshal(validator.NormalizeValue(document))
+	if error != nil {
+		return nil, fmt.Errorf("invalid manifest '%s': %s", manifestFile, error.Error())
+	}
+
+	parsedManifest := manifest{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.DisallowUnknownFields()
+	error = decoder.Decode(&parsedManifest)
+	if error != nil {
+		return nil, fmt.Errorf("invalid manifest '%s': %s", manifestFile, error.Error())
+	}
+
+	manifestDirectory := filepath.Dir(manifestFile)
+	for index := range parsedManifest.Templates {
+		parsedManifest.Templates[index].resolvePaths(manifestDirectory)
+	}
+
+	return parsedManifest.Templates, nil
+}
+
+// Returns the manifest files within a directory and its subdirectories, in lexical order
+func discoverManifests(directory string) ([]string, error) {
+	manifestFiles := []string{}
+	error := filepath.WalkDir(directory, func(file string, entry fs.DirEntry, error error) error {
+		if error != nil {
+			return error
+		}
+
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), manifestSuffix) {
+			manifestFiles = append(manifestFiles, file)
+		}
+		return nil
+	})
+
+	return manifestFiles, error
+}
+
+// Makes the relative paths of a request relative to a directory
+func (request *PluginValidationRequest) resolvePaths(directory string) {
+	request.InputFile = resolvePath(directory, request.InputFile)
+	// '{template_dir}' is replaced with the directory of the input file, which is already resolved
+	if !strings.HasPrefix(request.ExpectedOutput, templateDirPlaceholder) {
+		request.ExpectedOutput = resolvePath(directory, request.ExpectedOutput)
+	}
+	request.VariableSchema = resolvePath(directory, request.VariableSchema)
+	for index, variablesFile := range request.VariablesFiles {
+		request.VariablesFiles[index] = resolvePath(directory, variablesFile)
+	}
+}
+
+func resolvePath(directory string, file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+
+	return filepath.Join(directory, file)
+}
+
+// Expands a request whose input file is a glob into a request for each matching file. '{template_dir}' and
+// '{template_name}' in the expected output are replaced with the directory and the name without extension
+// of the input file, so that each template can have its own expected output
+func expandInputFiles(request PluginValidationRequest) ([]PluginValidationRequest, error) {
+	inputFiles := []string{request.InputFile}
+	if strings.ContainsAny(request.InputFile, "*?[") {
+		var error error
+		inputFiles, error = globFiles(request.InputFile)
+		if error != nil {
+			return nil, fmt.Errorf("invalid input file '%s': %s", request.InputFile, error.Error())
+		}
+		if len(inputFiles) == 0 {
+			return nil, fmt.Errorf("no templates match '%s'", request.InputFile)
+		}
+	}
+
+	requests := make([]PluginValidationRequest, len(inputFiles))
+	for index, inputFile := range inputFiles {
+		expandedRequest := request
+		expandedRequest.InputFile = inputFile
+
+		templateName := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+		expandedRequest.ExpectedOutput = strings.NewReplacer(
+			templateDirPlaceholder, filepath.Dir(inputFile),
+			"{template_name}", templateName,
+		).Replace(request.ExpectedOutput)
+
+		requests[index] = expandedRequest
+	}
+
+	return requests, nil
+}
+
+// Returns the files matching a glob, in lexical order. In addition to the syntax of filepath.Match,
+// '**' matches any number of directories
+func globFiles(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	// Directories are walked from the part of the pattern before the first wildcard
+	patternSegments := strings.Split(filepath.ToSlash(pattern), "/")
+	root := []string{}
+	for _, segment := range patternSegments {
+		if strings.ContainsAny(segment, "*?[") {
+			break
+		}
+		root = append(root, segment)
+	}
+	rootDirectory := strings.Join(root, "/")
+	if rootDirectory == "" {
+		rootDirectory = "."
+	}
+
+	// Validates the syntax of the pattern, as path.Match only reports it for the segments it reaches
+	for _, segment := range patternSegments[len(root):] {
+		if _, error := path.Match(segment, ""); error != nil {
+			return nil, error
+		}
+	}
+
+	files := []string{}
+	error := filepath.WalkDir(filepath.FromSlash(rootDirectory), func(file string, entry fs.DirEntry, error error) error {
+		if error != nil {
+			return error
+		}
+
+		relativeFile := strings.TrimPrefix(filepath.ToSlash(file), rootDirectory+"/")
+		if !entry.IsDir() && matchSegments(patternSegments[len(root):], strings.Split(relativeFile, "/")) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if os.IsNotExist(error) {
+		return nil, nil
+	}
+	sort.Strings(files)
+
+	return files, error
+}
+
+// Matches the segments of a path against the segments of a glob, where '**' matches zero or more segments
+func matchSegments(patternSegments []string, pathSegments []string) bool {
+	if len(patternSegments) == 0 {
+		return len(pathSegments) == 0
+	}
+
+	if patternSegments[0] == "**" {
+		for index := 0; index <= len(pathSegments); index++ {
+			if matchSegments(patternSegments[1:], pathSegments[index:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(pathSegments) == 0 {
+		return false
+	}
+
+	matches, _ := path.Match(patternSegments[0], pathSegments[0])
+	return matches && matchSegments(patternSegments[1:], pathSegments[1:])
+}
+
+// Reads the variables files of a request and merges them, in order, with the variables of the request
+func readVariables(request PluginValidationRequest) (map[string]interface{}, error) {
+	if len(request.VariablesFiles) == 0 {
+		return request.Variables, nil
+	}
+
+	variables := map[string]interface{}{}
+	for _, variablesFile := range request.VariablesFiles {
+		content, error := os.ReadFile(variablesFile)
+		if error != nil {
+			return nil, error
+		}
+
+		var fileVariables interface{}
+		error = yaml.Unmarshal(content, &fileVariables)
+		if error != nil {
+			return nil, fmt.Errorf("invalid variables file '%s': %s", variablesFile, error.Error())
+		}
+
+		if fileVariables == nil {
+			continue
+		}
+		entries, ok := validator.NormalizeValue(fileVariables).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid variables file '%s': expected a map of variables", variablesFile)
+		}
+		for name, value := range entries {
+			variables[name] = value
+		}
+	}
+
+	for name, value := range request.Variables {
+		variables[name] = value
+	}
+
+	return variables, nil
+}
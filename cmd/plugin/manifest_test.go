@@ -0,0 +1,269 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestReadManifest(test *testing.T) {
+	actual, err := readManifest(helper.AbsolutePath("test/testdata/manifests/templates.template-test.yml"))
+
+	assert.Nil(test, err)
+	assert.Equal(test, []PluginValidationRequest{
+		{
+			InputFile:      helper.AbsolutePath("test/testdata/input_template.yml"),
+			VariablesFiles: []string{helper.AbsolutePath("test/testdata/manifests/vars/develop.yml")},
+			Variables:      map[string]interface{}{"notification_event": "push"},
+			ExpectedOutput: helper.AbsolutePath("test/testdata/output_template.yml"),
+			Tags:           []string{"smoke"},
+		},
+		{
+			InputFile:        helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
+			Variables:        map[string]interface{}{"notification_branch": "develop"},
+			ExpectError:      "did not find expected ','",
+			ExpectErrorStage: "yaml",
+			Tags:             []string{"negative"},
+		},
+	}, actual)
+}
+
+func TestReadManifestWithTemplateDir(test *testing.T) {
+	manifestDirectory := test.TempDir()
+	os.MkdirAll(filepath.Join(manifestDirectory, "templates", "slack"), 0755)
+	ioutil.WriteFile(filepath.Join(manifestDirectory, "templates", "slack", "template.yml"), []byte("steps: []"), 0644)
+	manifestFile := filepath.Join(manifestDirectory, "templates.template-test.yml")
+	ioutil.WriteFile(manifestFile, []byte(`templates:
+  - input_file: templates/*/template.yml
+    expected_output: '{template_dir}/samples/{template_name}.yml'
+  - input_file: templates/slack/template.yml
+    expected_output: samples/{template_name}.yml
+`), 0644)
+
+	templates, err := readManifest(manifestFile)
+	assert.Nil(test, err)
+
+	expanded := []PluginValidationRequest{}
+	for _, template := range templates {
+		requests, err := expandInputFiles(template)
+		assert.Nil(test, err)
+		expanded = append(expanded, requests...)
+	}
+
+	assert.Equal(test, []PluginValidationRequest{
+		{
+			InputFile:      filepath.Join(manifestDirectory, "templates", "slack", "template.yml"),
+			ExpectedOutput: filepath.Join(manifestDirectory, "templates", "slack", "samples", "template.yml"),
+		},
+		{
+			InputFile:      filepath.Join(manifestDirectory, "templates", "slack", "template.yml"),
+			ExpectedOutput: filepath.Join(manifestDirectory, "samples", "template.yml"),
+		},
+	}, expanded)
+}
+
+func TestReadManifestInvalid(test *testing.T) {
+	manifestDirectory := test.TempDir()
+
+	cases := []struct {
+		content  string
+		expected string
+	}{
+		{
+			"templates:\n  - input_file: template.yml\n    expected_ouput: output.yml",
+			`json: unknown field "expected_ouput"`,
+		},
+		{
+			"templates: [",
+			"yaml: line 1: did not find expected node content",
+		},
+		{
+			"templates:\n  - input_file: template.yml\n    matrix:\n      event: push",
+			"invalid matrix entry 'event'",
+		},
+	}
+
+	for _, data := range cases {
+		manifestFile := filepath.Join(manifestDirectory, "test.template-test.yml")
+		ioutil.WriteFile(manifestFile, []byte(data.content), 0644)
+
+		actual, err := readManifest(manifestFile)
+		assert.Nil(test, actual)
+		assert.ErrorContains(test, err, "invalid manifest '"+manifestFile+"': ")
+		assert.ErrorContains(test, err, data.expected)
+	}
+
+	_, err := readManifest(filepath.Join(manifestDirectory, "missing.template-test.yml"))
+	assert.True(test, os.IsNotExist(err))
+}
+
+func TestDiscoverManifests(test *testing.T) {
+	actual, err := discoverManifests(helper.AbsolutePath("test/testdata/manifests"))
+
+	assert.Nil(test, err)
+	assert.Equal(test, []string{
+		helper.AbsolutePath("test/testdata/manifests/starlark/starlark.template-test.yml"),
+		helper.AbsolutePath("test/testdata/manifests/templates.template-test.yml"),
+	}, actual)
+}
+
+func TestGlobFiles(test *testing.T) {
+	directory := test.TempDir()
+	for _, file := range []string{"build/template.yml", "deploy/helm/template.yml", "deploy/helm/values.yml", "template.yml"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(directory, file)), 0755)
+		ioutil.WriteFile(filepath.Join(directory, file), []byte{}, 0644)
+	}
+
+	cases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"*/template.yml", []string{"build/template.yml"}},
+		{"**/template.yml", []string{"build/template.yml", "deploy/helm/template.yml", "template.yml"}},
+		{"deploy/**/*.yml", []string{"deploy/helm/template.yml", "deploy/helm/values.yml"}},
+		{"**/helm/t*.yml", []string{"deploy/helm/template.yml"}},
+		{"**", []string{"build/template.yml", "deploy/helm/template.yml", "deploy/helm/values.yml", "template.yml"}},
+		{"missing/**/*.yml", []string{}},
+	}
+
+	for _, data := range cases {
+		actual, err := globFiles(filepath.Join(directory, data.pattern))
+		assert.Nil(test, err)
+
+		relativeFiles := []string{}
+		for _, file := range actual {
+			relativeFile, _ := filepath.Rel(directory, file)
+			relativeFiles = append(relativeFiles, filepath.ToSlash(relativeFile))
+		}
+		assert.Equal(test, data.expected, relativeFiles, data.pattern)
+	}
+
+	_, err := globFiles(filepath.Join(directory, "**/[.yml"))
+	assert.EqualError(test, err, "syntax error in pattern")
+}
+
+func TestExpandInputFiles(test *testing.T) {
+	actual, err := expandInputFiles(PluginValidationRequest{
+		InputFile:      helper.AbsolutePath("test/testdata/input_*_template.py"),
+		ExpectedOutput: "{template_dir}/expected/{template_name}.yml",
+		TemplateType:   "starlark",
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, []PluginValidationRequest{
+		{
+			InputFile:      helper.AbsolutePath("test/testdata/input_build_context_template.py"),
+			ExpectedOutput: helper.AbsolutePath("test/testdata/expected/input_build_context_template.yml"),
+			TemplateType:   "starlark",
+		},
+		{
+			InputFile:      helper.AbsolutePath("test/testdata/input_starlark_template.py"),
+			ExpectedOutput: helper.AbsolutePath("test/testdata/expected/input_starlark_template.yml"),
+			TemplateType:   "starlark",
+		},
+		{
+			InputFile:      helper.AbsolutePath("test/testdata/input_variables_template.py"),
+			ExpectedOutput: helper.AbsolutePath("test/testdata/expected/input_variables_template.yml"),
+			TemplateType:   "starlark",
+		},
+	}, actual)
+}
+
+func TestExpandInputFilesInvalid(test *testing.T) {
+	cases := []struct {
+		inputFile string
+		expected  string
+	}{
+		{"missing/*.yml", "no templates match 'missing/*.yml'"},
+		{"[.yml", "invalid input file '[.yml': syntax error in pattern"},
+	}
+
+	for _, data := range cases {
+		actual, err := expandInputFiles(PluginValidationRequest{InputFile: data.inputFile})
+
+		assert.Nil(test, actual)
+		assert.EqualError(test, err, data.expected)
+	}
+}
+
+func TestReadVariables(test *testing.T) {
+	directory := test.TempDir()
+	commonFile := filepath.Join(directory, "common.yml")
+	ioutil.WriteFile(commonFile, []byte("image: alpine\nslack:\n  channel: builds\nretries: 1"), 0644)
+	emptyFile := filepath.Join(directory, "empty.yml")
+	ioutil.WriteFile(emptyFile, []byte{}, 0644)
+
+	actual, err := readVariables(PluginValidationRequest{
+		VariablesFiles: []string{commonFile, emptyFile, helper.AbsolutePath("test/testdata/manifests/vars/develop.yml")},
+		Variables:      map[string]interface{}{"retries": float64(2)},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, map[string]interface{}{
+		"image":               "alpine",
+		"slack":               map[string]interface{}{"channel": "builds"},
+		"retries":             float64(2),
+		"notification_branch": "develop",
+		"notification_event":  "tag",
+	}, actual)
+}
+
+func TestReadVariablesInvalid(test *testing.T) {
+	variablesFile := filepath.Join(test.TempDir(), "variables.yml")
+	ioutil.WriteFile(variablesFile, []byte("- image"), 0644)
+
+	actual, err := readVariables(PluginValidationRequest{VariablesFiles: []string{variablesFile}})
+	assert.Nil(test, actual)
+	assert.EqualError(test, err, "invalid variables file '"+variablesFile+"': expected a map of variables")
+}
+
+func TestFilterByTags(test *testing.T) {
+	requests := []PluginValidationRequest{
+		{InputFile: "first.yml", Tags: []string{"smoke"}},
+		{InputFile: "second.yml", Tags: []string{"starlark", "slow"}},
+		{InputFile: "third.yml"},
+	}
+
+	assert.Equal(test, requests[:2], filterByTags(requests, []string{"smoke", " slow"}))
+	assert.Equal(test, []PluginValidationRequest{}, filterByTags(requests, []string{"fast"}))
+}
+
+func TestRunWithManifest(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	cases := []map[string]string{
+		{"manifest": helper.AbsolutePath("test/testdata/manifests/templates.template-test.yml")},
+		{"discover": helper.AbsolutePath("test/testdata/manifests")},
+		{"discover": helper.AbsolutePath("test/testdata/manifests"), "tags": "starlark,negative"},
+	}
+
+	for _, parameters := range cases {
+		set := flag.NewFlagSet("test", 0)
+		for key, value := range parameters {
+			set.String(key, value, "")
+		}
+
+		assert.Nil(test, run(cli.NewContext(nil, set, nil)))
+		assert.Equal(test, -1, exitCode[0])
+	}
+}
+
+func TestRunWithManifestNoMatchingTags(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("discover", helper.AbsolutePath("test/testdata/manifests"), "")
+	set.String("tags", "slow", "")
+
+	readInputParameters(cli.NewContext(nil, set, nil))
+	assert.Equal(test, 0, exitCode[0])
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Values of the variables to test a template with. The template is tested with every combination of
+// the values, except the ones matching an 'exclude' entry, along with the combinations in 'include'
+type Matrix struct {
+	Variables map[string][]interface{}
+	Include   []map[string]interface{}
+	Exclude   []map[string]interface{}
+}
+
+// Reads the variables of the matrix alongside the 'include' and 'exclude' keys, like in CI matrices
+func (matrix *Matrix) UnmarshalJSON(content []byte) error {
+	entries := map[string]json.RawMessage{}
+	error := json.Unmarshal(content, &entries)
+	if error != nil {
+		return error
+	}
+
+	matrix.Variables = map[string][]interface{}{}
+	for name, entry := range entries {
+		switch name {
+		case "include":
+			error = json.Unmarshal(entry, &matrix.Include)
+		case "exclude":
+			error = json.Unmarshal(entry, &matrix.Exclude)
+		default:
+			values := []interface{}{}
+			error = json.Unmarshal(entry, &values)
+			matrix.Variables[name] = values
+		}
+
+		if error != nil {
+			return fmt.Errorf("invalid matrix entry '%s': %s", name, error.Error())
+		}
+	}
+
+	return nil
+}
+
+// Returns the combinations of variable values of the matrix, in a stable order
+func (matrix Matrix) combinations() ([]map[string]interface{}, error) {
+	names := make([]string, 0, len(matrix.Variables))
+	for name, values := range matrix.Variables {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix variable '%s' has no values", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Cartesian product, with the values of the last variable changing the fastest
+	combinations := []map[string]interface{}{}
+	if len(names) > 0 {
+		combinations = append(combinations, map[string]interface{}{})
+	}
+	for _, name := range names {
+		product := make([]map[string]interface{}, 0, len(combinations)*len(matrix.Variables[name]))
+		for _, combination := range combinations {
+			for _, value := range matrix.Variables[name] {
+				extended := make(map[string]interface{}, len(combination)+1)
+				for key, existingValue := range combination {
+					extended[key] = existingValue
+				}
+				extended[name] = value
+				product = append(product, extended)
+			}
+		}
+		combinations = product
+	}
+
+	included := []map[string]interface{}{}
+	for _, combination := range combinations {
+		if !matchesAny(combination, matrix.Exclude) {
+			included = append(included, combination)
+		}
+	}
+	included = append(included, matrix.Include...)
+
+	if len(included) == 0 {
+		return nil, fmt.Errorf("matrix has no combinations")
+	}
+
+	return included, nil
+}
+
+// Indicates if a combination has all the values of any of the entries
+func matchesAny(combination map[string]interface{}, entries []map[string]interface{}) bool {
+	for _, entry := range entries {
+		matches := true
+		for name, value := range entry {
+			if !reflect.DeepEqual(combination[name], value) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expands a request with a matrix into a request for each combination. The variables of a combination
+// override the variables of the request. '{name}' in the expected output is replaced with the value of
+// the variable 'name', so that each combination can have its own expected output
+func expandMatrix(request PluginValidationRequest) ([]PluginValidationRequest, error) {
+	if request.Matrix == nil {
+		return []PluginValidationRequest{request}, nil
+	}
+
+	combinations, error := request.Matrix.combinations()
+	if error != nil {
+		return nil, fmt.Errorf("invalid matrix of template '%s': %s", request.InputFile, error.Error())
+	}
+
+	requests := make([]PluginValidationRequest, len(combinations))
+	for index, combination := range combinations {
+		expandedRequest := request
+		expandedRequest.Matrix = nil
+		expandedRequest.combination = formatCombination(combination)
+
+		expandedRequest.Variables = make(map[string]interface{}, len(request.Variables)+len(combination))
+		for name, value := range request.Variables {
+			expandedRequest.Variables[name] = value
+		}
+		for name, value := range combination {
+			expandedRequest.Variables[name] = value
+			expandedRequest.ExpectedOutput = strings.ReplaceAll(expandedRequest.ExpectedOutput, "{"+name+"}", formatMatrixValue(value))
+		}
+
+		requests[index] = expandedRequest
+	}
+
+	return requests, nil
+}
+
+// Formats a combination as 'name=value' pairs sorted by name, like 'branch=main, event=push'
+func formatCombination(combination map[string]interface{}) string {
+	names := make([]string, 0, len(combination))
+	for name := range combination {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for index, name := range names {
+		pairs[index] = name + "=" + formatMatrixValue(combination[name])
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+func formatMatrixValue(value interface{}) string {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		output, _ := json.Marshal(value)
+		return string(output)
+	}
+
+	return fmt.Sprint(value)
+}
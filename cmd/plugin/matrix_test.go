@@ -0,0 +1,223 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestMatrixCombinations(test *testing.T) {
+	cases := []struct {
+		matrix   string
+		expected []map[string]interface{}
+	}{
+		{
+			`{"event":["push","tag"],"branch":["main","develop"]}`,
+			[]map[string]interface{}{
+				{"branch": "main", "event": "push"},
+				{"branch": "main", "event": "tag"},
+				{"branch": "develop", "event": "push"},
+				{"branch": "develop", "event": "tag"},
+			},
+		},
+		{
+			`{"event":["push","tag"],"branch":["main","develop"],"exclude":[{"branch":"develop","event":"tag"},{"event":"push","branch":"main"}]}`,
+			[]map[string]interface{}{
+				{"branch": "main", "event": "tag"},
+				{"branch": "develop", "event": "push"},
+			},
+		},
+		{
+			`{"event":["push","tag"],"exclude":[{"event":"tag"}],"include":[{"event":"pull_request","draft":true}]}`,
+			[]map[string]interface{}{
+				{"event": "push"},
+				{"event": "pull_request", "draft": true},
+			},
+		},
+		{
+			`{"include":[{"event":"push"},{"event":"tag"}]}`,
+			[]map[string]interface{}{
+				{"event": "push"},
+				{"event": "tag"},
+			},
+		},
+		{
+			`{"retries":[1,2]}`,
+			[]map[string]interface{}{
+				{"retries": float64(1)},
+				{"retries": float64(2)},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		matrix := Matrix{}
+		err := json.Unmarshal([]byte(data.matrix), &matrix)
+		assert.Nil(test, err)
+
+		actual, err := matrix.combinations()
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual, data.matrix)
+	}
+}
+
+func TestMatrixCombinationsInvalid(test *testing.T) {
+	cases := []struct {
+		matrix   string
+		expected string
+	}{
+		{
+			`{"event":[]}`,
+			"matrix variable 'event' has no values",
+		},
+		{
+			`{"event":["push"],"exclude":[{"event":"push"}]}`,
+			"matrix has no combinations",
+		},
+		{
+			`{}`,
+			"matrix has no combinations",
+		},
+	}
+
+	for _, data := range cases {
+		matrix := Matrix{}
+		err := json.Unmarshal([]byte(data.matrix), &matrix)
+		assert.Nil(test, err)
+
+		actual, err := matrix.combinations()
+		assert.Nil(test, actual)
+		assert.EqualError(test, err, data.expected)
+	}
+}
+
+func TestUnmarshalInvalidMatrix(test *testing.T) {
+	cases := []struct {
+		matrix   string
+		expected string
+	}{
+		{`{"event":"push"}`, "invalid matrix entry 'event': json: cannot unmarshal string"},
+		{`{"include":{"event":"push"}}`, "invalid matrix entry 'include': json: cannot unmarshal object"},
+		{`["push"]`, "json: cannot unmarshal array"},
+	}
+
+	for _, data := range cases {
+		matrix := Matrix{}
+		assert.ErrorContains(test, json.Unmarshal([]byte(data.matrix), &matrix), data.expected)
+	}
+}
+
+func TestExpandMatrix(test *testing.T) {
+	request := PluginValidationRequest{
+		InputFile:      "template.yml",
+		Variables:      map[string]interface{}{"image": "alpine", "event": "comment"},
+		ExpectedOutput: "samples/{event}/output_{branch}.yml",
+		Matrix: &Matrix{
+			Variables: map[string][]interface{}{
+				"event":  {"push", "tag"},
+				"branch": {"main"},
+			},
+			Include: []map[string]interface{}{{"event": "pull_request", "labels": []interface{}{"ci"}}},
+		},
+	}
+
+	actual, err := expandMatrix(request)
+	assert.Nil(test, err)
+	assert.Equal(test, []PluginValidationRequest{
+		{
+			InputFile:      "template.yml",
+			Variables:      map[string]interface{}{"image": "alpine", "event": "push", "branch": "main"},
+			ExpectedOutput: "samples/push/output_main.yml",
+			combination:    "branch=main, event=push",
+		},
+		{
+			InputFile:      "template.yml",
+			Variables:      map[string]interface{}{"image": "alpine", "event": "tag", "branch": "main"},
+			ExpectedOutput: "samples/tag/output_main.yml",
+			combination:    "branch=main, event=tag",
+		},
+		{
+			InputFile:      "template.yml",
+			Variables:      map[string]interface{}{"image": "alpine", "event": "pull_request", "labels": []interface{}{"ci"}},
+			ExpectedOutput: "samples/pull_request/output_{branch}.yml",
+			combination:    `event=pull_request, labels=["ci"]`,
+		},
+	}, actual)
+	assert.Equal(test, "template.yml [branch=main, event=push]", actual[0].name())
+
+	// The variables of the request are not modified
+	assert.Equal(test, map[string]interface{}{"image": "alpine", "event": "comment"}, request.Variables)
+}
+
+func TestExpandMatrixInvalid(test *testing.T) {
+	actual, err := expandMatrix(PluginValidationRequest{
+		InputFile: "template.yml",
+		Matrix:    &Matrix{Variables: map[string][]interface{}{"event": {}}},
+	})
+
+	assert.Nil(test, actual)
+	assert.EqualError(test, err, "invalid matrix of template 'template.yml': matrix variable 'event' has no values")
+}
+
+func TestRunWithMatrix(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+	set.String("expected-output", helper.AbsolutePath("test/testdata/output_template.yml"), "")
+	set.String("matrix", `{"notification_branch":["develop","main"],"notification_event":["push"]}`, "")
+
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, fmt.Errorf(
+		"Template '%s [notification_branch=main, notification_event=push]' is valid, but did not match expected output",
+		helper.AbsolutePath("test/testdata/input_template.yml"),
+	), actual)
+	assert.Equal(test, 1, exitCode[0])
+}
+
+func TestRunWithMatrixGoldenFiles(test *testing.T) {
+	exitCode := captureExitCode(test)
+	goldenDirectory := test.TempDir()
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+	set.String("expected-output", filepath.Join(goldenDirectory, "output_{notification_branch}.yml"), "")
+	set.String("matrix", `{"notification_branch":["develop","main"],"exclude":[{"notification_branch":"main"}],"include":[{"notification_branch":"v1"}]}`, "")
+	set.String("update-golden", "true", "")
+
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.Nil(test, actual)
+	assert.Equal(test, -1, exitCode[0])
+
+	develop, _ := ioutil.ReadFile(filepath.Join(goldenDirectory, "output_develop.yml"))
+	v1, _ := ioutil.ReadFile(filepath.Join(goldenDirectory, "output_v1.yml"))
+	assert.Contains(test, string(develop), "    branch: develop\n")
+	assert.Contains(test, string(v1), "    branch: v1\n")
+	assert.NoFileExists(test, filepath.Join(goldenDirectory, "output_main.yml"))
+}
+
+func TestRunWithMatrixSharedGoldenFile(test *testing.T) {
+	goldenFile := filepath.Join(test.TempDir(), "output.yml")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+	set.String("expected-output", goldenFile, "")
+	set.String("matrix", `{"notification_branch":["develop","main"]}`, "")
+	set.String("update-golden", "true", "")
+
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, fmt.Errorf("Templates '%[1]s [notification_branch=develop]' and '%[1]s [notification_branch=main]' "+
+		"cannot update the same golden file '%[2]s'. Add a '{name}' placeholder of a matrix variable to the expected output",
+		helper.AbsolutePath("test/testdata/input_template.yml"), goldenFile), actual)
+	assert.NoFileExists(test, goldenFile)
+}
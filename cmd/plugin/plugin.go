@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"reflect"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/devatherock/vela-template-tester/pkg/util"
 	"github.com/devatherock/vela-template-tester/pkg/validator"
@@ -15,10 +21,73 @@ import (
 )
 
 type PluginValidationRequest struct {
-	InputFile      string                 `json:"input_file,omitempty"`
-	Variables      map[string]interface{} `json:",omitempty"`
-	ExpectedOutput string                 `json:"expected_output,omitempty"`
-	TemplateType   string                 `json:"template_type,omitempty"`
+	InputFile      string                  `json:"input_file,omitempty"`
+	Variables      map[string]interface{}  `json:",omitempty"`
+	VariablesFiles []string                `json:"variables_files,omitempty"` // Yaml files with variables, overridden by 'variables'
+	ExpectedOutput string                  `json:"expected_output,omitempty"`
+	TemplateType   string                  `json:"template_type,omitempty"`
+	Strict         bool                    `json:"strict,omitempty"`
+	VariableSchema string                  `json:"variable_schema,omitempty"`
+	VelaVersion    string                  `json:"vela_version,omitempty"`
+	BuildContext   *validator.BuildContext `json:"build_context,omitempty"`
+	Assertions     []validator.Assertion   `json:"assertions,omitempty"`
+	// Error that the template is expected to fail with, as a substring or a regular expression
+	ExpectError string `json:"expect_error,omitempty"`
+	// Stage at which the template is expected to fail, like 'execute' or 'schema'
+	ExpectErrorStage string `json:"expect_error_stage,omitempty"`
+	// Variable values to test the template with every combination of
+	Matrix *Matrix `json:"matrix,omitempty"`
+	// Labels to select the templates to test with the 'tags' parameter
+	Tags []string `json:"tags,omitempty"`
+	// Maximum time to process the template, like '30s'. Overrides the 'timeout' parameter
+	Timeout string `json:"timeout,omitempty"`
+	// Lints the processed template, even if the 'lint' parameter is not set
+	Lint bool `json:"lint,omitempty"`
+	// Severities of lint rules by name, overriding the 'lint_rules' parameter. 'off' suppresses a rule for the template
+	LintRules map[string]string `json:"lint_rules,omitempty"`
+
+	combination string // Variables of the matrix combination the request was expanded from
+}
+
+// Name of the request in the log. Includes the variables of the matrix combination, if any
+func (request PluginValidationRequest) name() string {
+	if request.combination == "" {
+		return request.InputFile
+	}
+
+	return fmt.Sprintf("%s [%s]", request.InputFile, request.combination)
+}
+
+// Differences of a template that did not match its expected output, in the diff report
+type diffReportEntry struct {
+	InputFile      string `json:"input_file"`
+	Combination    string `json:"combination,omitempty"`
+	ExpectedOutput string `json:"expected_output"`
+	validator.OutputDiff
+}
+
+// Statuses of a golden file after updating it
+const (
+	goldenCreated   = "created"
+	goldenUpdated   = "updated"
+	goldenUnchanged = "unchanged"
+)
+
+// Stages at which a template can be expected to fail
+var failureStages = []string{
+	validator.StageVariables,
+	validator.StageParse,
+	validator.StageExecute,
+	validator.StageYaml,
+	validator.StageSchema,
+}
+
+// Severities that lint findings can fail the tests at
+var lintThresholds = []string{
+	validator.SeverityError,
+	validator.SeverityWarning,
+	validator.SeverityInfo,
+	validator.SeverityOff,
 }
 
 var exit func(code int) = os.Exit
@@ -35,40 +104,194 @@ func main() {
 
 // Reads the plugin parameters and runs it
 func runApp(args []string) {
+	inputFileFlag := &cli.StringFlag{
+		Name:    "input-file",
+		Aliases: []string{"tf"},
+		Usage:   "The template file to test",
+		EnvVars: []string{"INPUT_FILE", "PARAMETER_INPUT_FILE"},
+	}
+	templateTypeFlag := &cli.StringFlag{
+		Name:    "template-type",
+		Aliases: []string{"tt"},
+		Usage:   "The template type. Needs to be 'starlark' if '--input-file' is a starlark template or 'pipeline' if it is a pipeline referencing templates",
+		EnvVars: []string{"TEMPLATE_TYPE", "PARAMETER_TEMPLATE_TYPE"},
+	}
+	velaVersionFlag := &cli.StringFlag{
+		Name:    "vela-version",
+		Aliases: []string{"vv"},
+		Usage:   "The vela version whose template functions are to be used. All sprig functions are available if not specified",
+		EnvVars: []string{"VELA_VERSION", "PARAMETER_VELA_VERSION"},
+	}
+
 	app := cli.NewApp()
 	app.Name = "vela template tester plugin"
 	app.Action = run
-	app.Flags = []cli.Flag{
-		&cli.StringFlag{
-			Name:    "input-file",
-			Aliases: []string{"tf"},
-			Usage:   "The template file to test",
-			EnvVars: []string{"INPUT_FILE", "PARAMETER_INPUT_FILE"},
-		},
-		&cli.StringFlag{
-			Name:    "template-type",
-			Aliases: []string{"tt"},
-			Usage:   "The template type. Needs to be 'starlark' if '--input-file' is a starlark template",
-			EnvVars: []string{"TEMPLATE_TYPE", "PARAMETER_TEMPLATE_TYPE"},
+	app.Commands = []*cli.Command{
+		{
+			Name:   "variables",
+			Usage:  "Lists the variables consumed by the template in '--input-file'",
+			Action: listVariables,
+			Flags:  []cli.Flag{inputFileFlag, templateTypeFlag, velaVersionFlag},
 		},
+	}
+	app.Flags = []cli.Flag{
+		inputFileFlag,
+		templateTypeFlag,
 		&cli.StringFlag{
 			Name:    "templates",
 			Aliases: []string{"ts"},
 			Usage:   "The list of template files and variables to test",
 			EnvVars: []string{"TEMPLATES", "PARAMETER_TEMPLATES"},
 		},
+		&cli.StringFlag{
+			Name:    "manifest",
+			Aliases: []string{"mf"},
+			Usage:   "Yaml file listing the templates to test, in the same format as '--templates'",
+			EnvVars: []string{"MANIFEST", "PARAMETER_MANIFEST"},
+		},
+		&cli.StringFlag{
+			Name:    "discover",
+			Aliases: []string{"d"},
+			Usage:   "Directory to find manifest files named '*" + manifestSuffix + "' in, including its subdirectories",
+			EnvVars: []string{"DISCOVER", "PARAMETER_DISCOVER"},
+		},
+		&cli.StringFlag{
+			Name:    "tags",
+			Usage:   "Comma separated tags. Only the templates with any of the tags are tested",
+			EnvVars: []string{"TAGS", "PARAMETER_TAGS"},
+		},
 		&cli.StringFlag{
 			Name:    "variables",
 			Aliases: []string{"v"},
 			Usage:   "Variables to apply to the template",
 			EnvVars: []string{"VARIABLES", "PARAMETER_VARIABLES"},
 		},
+		&cli.StringFlag{
+			Name:    "matrix",
+			Aliases: []string{"m"},
+			Usage:   "Lists of variable values to test the template with every combination of, as json",
+			EnvVars: []string{"MATRIX", "PARAMETER_MATRIX"},
+		},
+		&cli.StringFlag{
+			Name:    "variable-schema",
+			Aliases: []string{"vs"},
+			Usage:   "The variable schema of the template. Defaults to '<template name>.schema.yml' next to the template, if present",
+			EnvVars: []string{"VARIABLE_SCHEMA", "PARAMETER_VARIABLE_SCHEMA"},
+		},
+		&cli.BoolFlag{
+			Name:    "strict",
+			Aliases: []string{"s"},
+			Usage:   "Fails go templates that access variables which are not supplied",
+			EnvVars: []string{"STRICT", "PARAMETER_STRICT"},
+		},
+		velaVersionFlag,
+		&cli.StringFlag{
+			Name:    "build-context",
+			Aliases: []string{"bc"},
+			Usage:   "Build metadata to test the template with, like branch, event, commit, org and repo",
+			EnvVars: []string{"BUILD_CONTEXT", "PARAMETER_BUILD_CONTEXT"},
+		},
+		&cli.Uint64Flag{
+			Name:    "max-starlark-steps",
+			Usage:   "Maximum execution steps of a starlark template. No limit if not specified",
+			EnvVars: []string{"MAX_STARLARK_STEPS", "PARAMETER_MAX_STARLARK_STEPS"},
+		},
+		&cli.IntFlag{
+			Name:    "max-output-bytes",
+			Usage:   "Maximum size of a processed template in bytes. No limit if not specified",
+			EnvVars: []string{"MAX_OUTPUT_BYTES", "PARAMETER_MAX_OUTPUT_BYTES"},
+		},
+		&cli.DurationFlag{
+			Name:    "timeout",
+			Usage:   "Maximum time to process a template, like '30s'. No limit if not specified",
+			EnvVars: []string{"TIMEOUT", "PARAMETER_TIMEOUT"},
+		},
+		&cli.IntFlag{
+			Name:    "concurrency",
+			Aliases: []string{"c"},
+			Usage:   "Number of templates to test in parallel",
+			Value:   1,
+			EnvVars: []string{"CONCURRENCY", "PARAMETER_CONCURRENCY"},
+		},
+		&cli.BoolFlag{
+			Name:    "fail-fast",
+			Aliases: []string{"ff"},
+			Usage:   "Skips the templates not yet tested once a template fails",
+			EnvVars: []string{"FAIL_FAST", "PARAMETER_FAIL_FAST"},
+		},
 		&cli.StringFlag{
 			Name:    "expected-output",
 			Aliases: []string{"o"},
 			Usage:   "The expected output of the processed template",
 			EnvVars: []string{"EXPECTED_OUTPUT", "PARAMETER_EXPECTED_OUTPUT"},
 		},
+		&cli.StringFlag{
+			Name:    "expect-error",
+			Aliases: []string{"ee"},
+			Usage:   "Error that the template is expected to fail with, as a substring or a regular expression",
+			EnvVars: []string{"EXPECT_ERROR", "PARAMETER_EXPECT_ERROR"},
+		},
+		&cli.StringFlag{
+			Name:    "expect-error-stage",
+			Aliases: []string{"es"},
+			Usage:   "Stage at which the template is expected to fail. One of 'variables', 'parse', 'execute', 'yaml' or 'schema'",
+			EnvVars: []string{"EXPECT_ERROR_STAGE", "PARAMETER_EXPECT_ERROR_STAGE"},
+		},
+		&cli.StringFlag{
+			Name:    "assertions",
+			Aliases: []string{"a"},
+			Usage:   "Assertions on the values at paths of the processed template, as json",
+			EnvVars: []string{"ASSERTIONS", "PARAMETER_ASSERTIONS"},
+		},
+		&cli.BoolFlag{
+			Name:    "update-golden",
+			Aliases: []string{"ug"},
+			Usage:   "Writes the processed templates to their expected output files instead of verifying them",
+			EnvVars: []string{"UPDATE_GOLDEN", "PARAMETER_UPDATE_GOLDEN"},
+		},
+		&cli.StringFlag{
+			Name:    "report",
+			Aliases: []string{"r"},
+			Usage:   "Comma separated files to write the test results to, like 'results.xml'. The format is derived from the extension or a 'junit:', 'json:' or 'tap:' prefix",
+			EnvVars: []string{"REPORT", "PARAMETER_REPORT"},
+		},
+		&cli.StringFlag{
+			Name:    "coverage",
+			Usage:   "Comma separated files to write the branch coverage of the templates to, like 'coverage.html'. The format is derived from the extension or a 'text:', 'json:' or 'html:' prefix",
+			EnvVars: []string{"COVERAGE", "PARAMETER_COVERAGE"},
+		},
+		&cli.Float64Flag{
+			Name:    "coverage-threshold",
+			Usage:   "Minimum branch coverage of the templates in percent, like '80'. The tests fail if the coverage is lower",
+			EnvVars: []string{"COVERAGE_THRESHOLD", "PARAMETER_COVERAGE_THRESHOLD"},
+		},
+		&cli.StringFlag{
+			Name:    "downstream-variables",
+			Usage:   "Comma separated variables of downstream plugins, like 'DeployLink', that go templates are expected to escape. In addition to those of common plugins like 'BuildLink'",
+			EnvVars: []string{"DOWNSTREAM_VARIABLES", "PARAMETER_DOWNSTREAM_VARIABLES"},
+		},
+		&cli.BoolFlag{
+			Name:    "lint",
+			Aliases: []string{"l"},
+			Usage:   "Lints the processed templates that are valid vela pipelines",
+			EnvVars: []string{"LINT", "PARAMETER_LINT"},
+		},
+		&cli.StringFlag{
+			Name:    "lint-rules",
+			Usage:   "Severities of lint rules by name, as json, like '{\"missing-ruleset\":\"warning\",\"latest-tag\":\"off\"}'",
+			EnvVars: []string{"LINT_RULES", "PARAMETER_LINT_RULES"},
+		},
+		&cli.StringFlag{
+			Name:    "lint-threshold",
+			Usage:   "Lowest severity of lint findings that fails a template. One of 'error', 'warning', 'info' or 'off'",
+			Value:   validator.SeverityError,
+			EnvVars: []string{"LINT_THRESHOLD", "PARAMETER_LINT_THRESHOLD"},
+		},
+		&cli.StringFlag{
+			Name:    "diff-report",
+			Usage:   "File to write the differences of the templates that did not match their expected output to, as json",
+			EnvVars: []string{"DIFF_REPORT", "PARAMETER_DIFF_REPORT"},
+		},
 	}
 
 	err := app.Run(args)
@@ -77,41 +300,97 @@ func runApp(args []string) {
 
 // Tests the supplied templates using the validator
 func run(context *cli.Context) error {
-	pluginValidationRequests := readInputParameters(context)
-	var validationFailure bool
-	var validationStatus error // For easier testing
+	if threshold := lintThreshold(context); !slices.Contains(lintThresholds, threshold) {
+		return fmt.Errorf("invalid lint threshold '%s', expected one of %s", threshold, strings.Join(lintThresholds, ", "))
+	}
+
+	pluginValidationRequests := []PluginValidationRequest{}
+	for _, request := range readInputParameters(context) {
+		requestsByFile, error := expandInputFiles(request)
+		if error != nil {
+			return error
+		}
 
-	for _, request := range pluginValidationRequests {
-		validationRequest := validator.ValidationRequest{}
+		for _, requestByFile := range requestsByFile {
+			expandedRequests, error := expandMatrix(requestByFile)
+			if error != nil {
+				return error
+			}
+			pluginValidationRequests = append(pluginValidationRequests, expandedRequests...)
+		}
+	}
 
-		content, error := os.ReadFile(request.InputFile)
+	updateGolden := context.Bool("update-golden")
+	if updateGolden {
+		error := checkGoldenFiles(pluginValidationRequests)
 		if error != nil {
 			return error
 		}
-		validationRequest.Template = string(content)
-		validationRequest.Parameters = request.Variables
-		validationRequest.Type = request.TemplateType
+	}
+
+	var validationFailure bool
+	var validationStatus error // For easier testing
+	diffReport := []diffReportEntry{}
+	goldenFiles := make(map[string][]string) // Golden files by status
+	results := make([]testResult, 0, len(pluginValidationRequests))
+	coverage := &coverageReport{Templates: []*templateCoverage{}}
 
-		validationResponse := validator.Validate(validationRequest)
-		if validationResponse.Error != "" {
-			message := fmt.Sprintf("Template '%s' is invalid. Error: %s", request.InputFile, validationResponse.Error)
-			validationStatus = errors.New(message)
+	for outcome := range testTemplates(context, pluginValidationRequests) {
+		if outcome.error != nil {
+			return outcome.error
+		}
 
-			log.Error(message)
+		for _, entry := range outcome.logs {
+			log.StandardLogger().Log(entry.level, entry.message)
+		}
+		if outcome.diff != nil {
+			diffReport = append(diffReport, *outcome.diff)
+		}
+		if outcome.goldenStatus != "" {
+			goldenFiles[outcome.goldenStatus] = append(goldenFiles[outcome.goldenStatus], outcome.goldenFile)
+		}
+		coverage.add(outcome.result.InputFile, outcome.templateFiles, outcome.coverage)
+		if outcome.result.Status == testFailed {
+			validationStatus = errors.New(outcome.result.Message)
 			validationFailure = true
-		} else {
-			validationResult := verifyOutput(request, validationResponse)
+		}
+		results = append(results, outcome.result)
+	}
+
+	if updateGolden {
+		logGoldenSummary(goldenFiles)
+	}
 
-			if validationResult {
-				log.Printf("Template '%s' is valid.", request.InputFile)
-			} else {
-				message := fmt.Sprintf("Template '%s' is valid, but did not match expected output", request.InputFile)
-				validationStatus = errors.New(message)
+	if coverageEnabled(context) {
+		coverage.summarize()
+		log.Print(coverage.text())
 
-				log.Error(message)
-				validationFailure = true
+		if reports := context.String("coverage"); reports != "" {
+			error := writeCoverageReports(reports, coverage)
+			if error != nil {
+				return error
 			}
 		}
+
+		if threshold := context.Float64("coverage-threshold"); coverage.Coverage < threshold {
+			validationStatus = fmt.Errorf("Branch coverage of %.1f%% is below the threshold of %.1f%%", coverage.Coverage, threshold)
+			log.Error(validationStatus.Error())
+			validationFailure = true
+		}
+	}
+
+	if reports := context.String("report"); reports != "" {
+		error := writeReports(reports, results)
+		if error != nil {
+			return error
+		}
+	}
+
+	if reportFile := context.String("diff-report"); reportFile != "" {
+		error := writeDiffReport(reportFile, diffReport)
+		if error != nil {
+			return error
+		}
 	}
 
 	if validationFailure {
@@ -121,6 +400,281 @@ func run(context *cli.Context) error {
 	return validationStatus
 }
 
+// Tests a template. Log entries are collected in the outcome instead of being logged, so that
+// the templates tested in parallel are logged in the order of the requests
+func testTemplate(context *cli.Context, request PluginValidationRequest) templateOutcome {
+	started := time.Now()
+	outcome := templateOutcome{}
+	result := &outcome.result
+	*result = testResult{Name: request.name(), InputFile: request.InputFile, Combination: request.combination, Status: testPassed}
+
+	validationRequest := validator.ValidationRequest{}
+	if request.ExpectErrorStage != "" && !slices.Contains(failureStages, request.ExpectErrorStage) {
+		outcome.error = fmt.Errorf("invalid expected error stage '%s', expected one of %s", request.ExpectErrorStage, strings.Join(failureStages, ", "))
+		return outcome
+	}
+
+	timeout := context.Duration("timeout")
+	if request.Timeout != "" {
+		var error error
+		timeout, error = time.ParseDuration(request.Timeout)
+		if error != nil {
+			outcome.error = fmt.Errorf("invalid timeout '%s' of template '%s': %s", request.Timeout, request.name(), error.Error())
+			return outcome
+		}
+	}
+
+	content, error := os.ReadFile(request.InputFile)
+	if error != nil {
+		outcome.error = error
+		return outcome
+	}
+	validationRequest.Template = string(content)
+	validationRequest.Parameters, error = readVariables(request)
+	if error != nil {
+		outcome.error = error
+		return outcome
+	}
+	validationRequest.Type = request.TemplateType
+	validationRequest.Strict = request.Strict || context.Bool("strict")
+	validationRequest.VelaVersion = request.VelaVersion
+	if validationRequest.VelaVersion == "" {
+		validationRequest.VelaVersion = context.String("vela-version")
+	}
+
+	validationRequest.VariableSchema, error = readVariableSchema(request)
+	if error != nil {
+		outcome.error = error
+		return outcome
+	}
+
+	if request.TemplateType == "pipeline" {
+		validationRequest.Templates, validationRequest.VariableSchemas, error = readPipelineTemplates(request.InputFile, validationRequest.Template)
+		if error != nil {
+			outcome.error = error
+			return outcome
+		}
+		outcome.templateFiles = pipelineTemplateFiles(request.InputFile, validationRequest.Template)
+	}
+
+	validationRequest.BuildContext, error = readBuildContext(request, context)
+	if error != nil {
+		outcome.error = error
+		return outcome
+	}
+
+	validationRequest.Limits = validator.Limits{
+		MaxStarlarkSteps: context.Uint64("max-starlark-steps"),
+		MaxOutputBytes:   context.Int("max-output-bytes"),
+		Timeout:          timeout,
+	}
+	validationRequest.Coverage = coverageEnabled(context)
+	if downstreamVariables := context.String("downstream-variables"); downstreamVariables != "" {
+		validationRequest.DownstreamVariables = strings.Split(downstreamVariables, ",")
+	}
+	validationRequest.Lint, error = readLintConfig(request, context)
+	if error != nil {
+		outcome.error = error
+		return outcome
+	}
+
+	validationResponse := validator.Validate(context.Context, validationRequest)
+	outcome.coverage = validationResponse.Coverage
+	for _, message := range validationResponse.DebugLog {
+		outcome.logf(log.DebugLevel, "Template '%s' printed: %s", request.name(), message)
+	}
+	for _, undefinedVariable := range validationResponse.UndefinedVariables {
+		if undefinedVariable.RescuedBy != "" {
+			outcome.logf(log.WarnLevel, "Template '%s' accesses undefined variable '%s'%s at line %d, column %d, rescued by '%s'", request.name(),
+				undefinedVariable.Name, inPipelineTemplate(undefinedVariable.Template), undefinedVariable.Line, undefinedVariable.Column,
+				undefinedVariable.RescuedBy)
+		}
+	}
+	for _, expression := range validationResponse.UnescapedExpressions {
+		outcome.logf(log.WarnLevel, "Template '%s' has an unescaped expression%s: %s", request.name(), inPipelineTemplate(expression.Template), expression)
+	}
+	for _, scalar := range validationResponse.AmbiguousScalars {
+		outcome.logf(log.WarnLevel, "Template '%s' has an ambiguous scalar at %s", request.name(), scalar)
+	}
+	threshold := lintThreshold(context)
+	lintFailures := []validator.LintFinding{}
+	for _, finding := range validationResponse.Warnings {
+		if validator.SeverityAtLeast(finding.Severity, threshold) {
+			lintFailures = append(lintFailures, finding)
+		} else {
+			outcome.logf(log.WarnLevel, "Template '%s' has a lint finding: %s", request.name(), finding)
+		}
+	}
+
+	if request.ExpectError != "" || request.ExpectErrorStage != "" {
+		error := verifyExpectedError(request, validationResponse)
+		if error != nil {
+			result.fail(error.Error(), "")
+		} else {
+			outcome.logf(log.InfoLevel, "Template '%s' failed as expected at the %s stage.", request.name(), validationResponse.FailedStage)
+		}
+	} else if validationResponse.Error != "" {
+		diagnostics := make([]string, len(validationResponse.Diagnostics))
+		for index, diagnostic := range validationResponse.Diagnostics {
+			diagnostics[index] = formatDiagnostic(request.InputFile, diagnostic)
+		}
+
+		result.fail(fmt.Sprintf("Template '%s' is invalid. Error: %s", request.name(), validationResponse.Error), strings.Join(diagnostics, "\n"))
+	} else if len(validationResponse.SchemaErrors) > 0 {
+		result.fail(fmt.Sprintf("Template '%s' is not a valid vela pipeline. Violations: %s", request.name(), joinSchemaErrors(validationResponse.SchemaErrors)), "")
+	} else if len(lintFailures) > 0 {
+		result.fail(fmt.Sprintf("Template '%s' is valid, but has %d lint findings at or above the %s severity", request.name(), len(lintFailures), threshold),
+			formatLintFindings(lintFailures))
+	} else if assertionResults, failedAssertions, error := checkAssertions(request, validationResponse); error != nil {
+		result.fail(fmt.Sprintf("Template '%s' has invalid assertions. Error: %s", request.name(), error.Error()), "")
+	} else if failedAssertions > 0 {
+		result.fail(fmt.Sprintf("Template '%s' is valid, but failed %d of %d assertions", request.name(), failedAssertions, len(assertionResults)),
+			formatAssertionResults(assertionResults))
+	} else if context.Bool("update-golden") {
+		if request.ExpectedOutput == "" {
+			outcome.logf(log.WarnLevel, "Template '%s' has no expected output to update", request.name())
+			result.Status = testSkipped
+			result.Message = "no expected output to update"
+		} else {
+			outcome.goldenStatus, outcome.error = updateGoldenFile(request.ExpectedOutput, validationResponse.Template)
+			if outcome.error != nil {
+				return outcome
+			}
+			outcome.goldenFile = request.ExpectedOutput
+		}
+	} else {
+		validationResult, outputDiff, error := verifyOutput(request, validationResponse)
+
+		if error != nil {
+			result.fail(fmt.Sprintf("Template '%s' could not be compared with its expected output. Error: %s", request.name(), error.Error()), "")
+		} else if validationResult {
+			outcome.logf(log.InfoLevel, "Template '%s' is valid.", request.name())
+			if len(assertionResults) > 0 {
+				outcome.logf(log.DebugLevel, "%s", formatAssertionResults(assertionResults))
+			}
+		} else {
+			result.fail(fmt.Sprintf("Template '%s' is valid, but did not match expected output", request.name()), formatOutputDiff(outputDiff))
+			outcome.diff = &diffReportEntry{request.InputFile, request.combination, request.ExpectedOutput, outputDiff}
+		}
+	}
+
+	result.Duration = time.Since(started)
+	if result.Status == testFailed {
+		result.Output = validationResponse.Template
+
+		outcome.logf(log.ErrorLevel, "%s", result.Message)
+		if result.Details != "" {
+			outcome.logf(log.ErrorLevel, "%s", result.Details)
+		}
+	}
+
+	return outcome
+}
+
+// Indicates if the branch coverage of the templates is to be reported or checked
+func coverageEnabled(context *cli.Context) bool {
+	return context.String("coverage") != "" || context.Float64("coverage-threshold") > 0
+}
+
+// Prints the variables consumed by a template as yaml
+func listVariables(context *cli.Context) error {
+	templateFile := context.String("input-file")
+	if templateFile == "" {
+		log.Warn("No template specified")
+		exit(0)
+		return nil
+	}
+
+	content, error := os.ReadFile(templateFile)
+	if error != nil {
+		return error
+	}
+
+	variablesResponse := validator.ListVariables(validator.ValidationRequest{
+		Template:    string(content),
+		Type:        context.String("template-type"),
+		VelaVersion: context.String("vela-version"),
+	})
+	if variablesResponse.Error != "" {
+		message := fmt.Sprintf("Template '%s' is invalid. Error: %s", templateFile, variablesResponse.Error)
+		log.Error(message)
+		exit(1)
+
+		return errors.New(message)
+	}
+
+	output, error := yaml.Marshal(variablesResponse.Variables)
+	if error != nil {
+		return error
+	}
+	fmt.Fprint(context.App.Writer, string(output))
+
+	return nil
+}
+
+// Formats a template diagnostic as 'file:line:column: stage error: message', followed by the source excerpt
+func formatDiagnostic(inputFile string, diagnostic validator.TemplateDiagnostic) string {
+	location := inputFile
+	if diagnostic.Line > 0 {
+		location += fmt.Sprintf(":%d", diagnostic.Line)
+	}
+	if diagnostic.Column > 0 {
+		location += fmt.Sprintf(":%d", diagnostic.Column)
+	}
+
+	message := fmt.Sprintf("%s: %s error: %s", location, diagnostic.Stage, diagnostic.Message)
+	if diagnostic.Action != "" {
+		message += fmt.Sprintf(" (at <%s>)", diagnostic.Action)
+	}
+	if diagnostic.Excerpt != "" {
+		message += "\n" + diagnostic.Excerpt
+	}
+	if diagnostic.OutputExcerpt != "" {
+		message += fmt.Sprintf("\nline %d of the processed template:\n%s", diagnostic.OutputLine, diagnostic.OutputExcerpt)
+	}
+
+	return message
+}
+
+// Severity of lint findings that fails a template
+func lintThreshold(context *cli.Context) string {
+	if threshold := context.String("lint-threshold"); threshold != "" {
+		return threshold
+	}
+
+	return validator.SeverityError
+}
+
+// Returns the lint configuration of a template, if it is to be linted. The severities of the
+// template override those of the 'lint-rules' parameter
+func readLintConfig(request PluginValidationRequest, context *cli.Context) (*validator.LintConfig, error) {
+	if !request.Lint && !context.Bool("lint") {
+		return nil, nil
+	}
+
+	lintConfig := &validator.LintConfig{Severities: make(map[string]string)}
+	if lintRules := context.String("lint-rules"); lintRules != "" {
+		error := json.Unmarshal([]byte(lintRules), &lintConfig.Severities)
+		if error != nil {
+			return nil, fmt.Errorf("invalid lint rules '%s': %s", lintRules, error.Error())
+		}
+	}
+	for rule, severity := range request.LintRules {
+		lintConfig.Severities[rule] = severity
+	}
+
+	return lintConfig, nil
+}
+
+func formatLintFindings(findings []validator.LintFinding) string {
+	lines := make([]string, len(findings))
+	for index, finding := range findings {
+		lines[index] = finding.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 // Reads plugin input parameters
 func readInputParameters(context *cli.Context) []PluginValidationRequest {
 	pluginValidationRequests := []PluginValidationRequest{}
@@ -141,6 +695,26 @@ func readInputParameters(context *cli.Context) []PluginValidationRequest {
 			pluginValidationRequest.Variables = parsedVariables
 		}
 
+		variableSchemaFile := context.String("variable-schema")
+		if variableSchemaFile != "" {
+			pluginValidationRequest.VariableSchema = variableSchemaFile
+		}
+
+		assertions := context.String("assertions")
+		if assertions != "" {
+			error := json.Unmarshal([]byte(assertions), &pluginValidationRequest.Assertions)
+			util.HandleError(error)
+		}
+
+		pluginValidationRequest.ExpectError = context.String("expect-error")
+		pluginValidationRequest.ExpectErrorStage = context.String("expect-error-stage")
+
+		matrix := context.String("matrix")
+		if matrix != "" {
+			error := json.Unmarshal([]byte(matrix), &pluginValidationRequest.Matrix)
+			util.HandleError(error)
+		}
+
 		expectedOutputFile := context.String("expected-output")
 		if expectedOutputFile != "" {
 			pluginValidationRequest.ExpectedOutput = expectedOutputFile
@@ -164,6 +738,28 @@ func readInputParameters(context *cli.Context) []PluginValidationRequest {
 		pluginValidationRequests = append(pluginValidationRequests, suppliedValidationRequests...)
 	}
 
+	// Read the templates listed in manifest files
+	manifestFiles := []string{}
+	if manifestFile := context.String("manifest"); manifestFile != "" {
+		manifestFiles = append(manifestFiles, manifestFile)
+	}
+	if directory := context.String("discover"); directory != "" {
+		discoveredFiles, error := discoverManifests(directory)
+		util.HandleError(error)
+
+		manifestFiles = append(manifestFiles, discoveredFiles...)
+	}
+	for _, manifestFile := range manifestFiles {
+		manifestValidationRequests, error := readManifest(manifestFile)
+		util.HandleError(error)
+
+		pluginValidationRequests = append(pluginValidationRequests, manifestValidationRequests...)
+	}
+
+	if tags := context.String("tags"); tags != "" {
+		pluginValidationRequests = filterByTags(pluginValidationRequests, strings.Split(tags, ","))
+	}
+
 	if len(pluginValidationRequests) == 0 {
 		log.Warn("No template specified")
 		exit(0)
@@ -172,22 +768,292 @@ func readInputParameters(context *cli.Context) []PluginValidationRequest {
 	return pluginValidationRequests
 }
 
-// Verifies if the processed template matches the expected output
-func verifyOutput(request PluginValidationRequest, validationResponse validator.ValidationResponse) bool {
+// Returns the requests that have any of the tags
+func filterByTags(requests []PluginValidationRequest, tags []string) []PluginValidationRequest {
+	filteredRequests := []PluginValidationRequest{}
+	for _, request := range requests {
+		for _, tag := range tags {
+			if slices.Contains(request.Tags, strings.TrimSpace(tag)) {
+				filteredRequests = append(filteredRequests, request)
+				break
+			}
+		}
+	}
+
+	return filteredRequests
+}
+
+// Reads the local templates referenced by a pipeline along with their variable schemas, if present.
+// Template sources are relative to the pipeline file
+func readPipelineTemplates(pipelineFile string, pipeline string) (map[string]string, map[string]string, error) {
+	pipelineTemplates, error := validator.ReadPipelineTemplates(pipeline)
+	if error != nil {
+		return nil, nil, error
+	}
+
+	templates := make(map[string]string)
+	variableSchemas := make(map[string]string)
+	for _, pipelineTemplate := range pipelineTemplates {
+		templateFile := pipelineTemplateFile(pipelineFile, pipelineTemplate)
+
+		content, error := os.ReadFile(templateFile)
+		if error != nil {
+			return nil, nil, fmt.Errorf("unable to read template '%s': %s", pipelineTemplate.Name, error.Error())
+		}
+		templates[pipelineTemplate.Name] = string(content)
+
+		variableSchema, error := os.ReadFile(variableSchemaFile(templateFile))
+		if error == nil {
+			variableSchemas[pipelineTemplate.Name] = string(variableSchema)
+		}
+	}
+
+	return templates, variableSchemas, nil
+}
+
+// Describes the template of a pipeline a finding is in, like " in template 'notify'", or nothing for other templates
+func inPipelineTemplate(templateName string) string {
+	if templateName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" in template '%s'", templateName)
+}
+
+// Returns the files of the templates referenced by a pipeline that was read with readPipelineTemplates, by name
+func pipelineTemplateFiles(pipelineFile string, pipeline string) map[string]string {
+	pipelineTemplates, _ := validator.ReadPipelineTemplates(pipeline)
+
+	templateFiles := make(map[string]string)
+	for _, pipelineTemplate := range pipelineTemplates {
+		templateFiles[pipelineTemplate.Name] = pipelineTemplateFile(pipelineFile, pipelineTemplate)
+	}
+
+	return templateFiles
+}
+
+func pipelineTemplateFile(pipelineFile string, pipelineTemplate validator.PipelineTemplate) string {
+	if filepath.IsAbs(pipelineTemplate.Source) {
+		return pipelineTemplate.Source
+	}
+
+	return filepath.Join(filepath.Dir(pipelineFile), pipelineTemplate.Source)
+}
+
+// Returns the build context of a template, or the one from the 'build-context' parameter if not specified
+func readBuildContext(request PluginValidationRequest, context *cli.Context) (*validator.BuildContext, error) {
+	if request.BuildContext != nil {
+		return request.BuildContext, nil
+	}
+
+	buildContext := context.String("build-context")
+	if buildContext == "" {
+		return nil, nil
+	}
+
+	parsedBuildContext := &validator.BuildContext{}
+	error := json.Unmarshal([]byte(buildContext), parsedBuildContext)
+	if error != nil {
+		return nil, fmt.Errorf("invalid build context: %s", error.Error())
+	}
+
+	return parsedBuildContext, nil
+}
+
+// Reads the variable schema of a template. If not specified, the schema is picked up from the
+// file next to the template named as per the convention, if present
+func readVariableSchema(request PluginValidationRequest) (string, error) {
+	schemaFile := request.VariableSchema
+	if schemaFile == "" {
+		schemaFile = variableSchemaFile(request.InputFile)
+		if _, error := os.Stat(schemaFile); error != nil {
+			return "", nil
+		}
+	}
+
+	content, error := os.ReadFile(schemaFile)
+	return string(content), error
+}
+
+// Returns the conventional variable schema file of a template, 'template.schema.yml' for 'template.yml'
+func variableSchemaFile(templateFile string) string {
+	return strings.TrimSuffix(templateFile, filepath.Ext(templateFile)) + ".schema.yml"
+}
+
+// Verifies if the processed template matches the expected output. Returns the differences if it doesn't
+func verifyOutput(request PluginValidationRequest, validationResponse validator.ValidationResponse) (bool, validator.OutputDiff, error) {
 	if request.ExpectedOutput != "" {
 		expectedOutput, error := os.ReadFile(request.ExpectedOutput)
-		util.HandleError(error)
+		if error != nil {
+			return false, validator.OutputDiff{}, error
+		}
 
-		expectedOutputMap := make(map[interface{}]interface{})
-		error = yaml.Unmarshal([]byte(expectedOutput), &expectedOutputMap)
-		util.HandleError(error)
+		outputDiff, error := validator.DiffOutput(string(expectedOutput), validationResponse.Template)
+		if error != nil {
+			return false, validator.OutputDiff{}, error
+		}
 
-		processedTemplateMap := make(map[interface{}]interface{})
-		error = yaml.Unmarshal([]byte(validationResponse.Template), &processedTemplateMap)
-		util.HandleError(error)
+		return outputDiff.Matches(), outputDiff, nil
+	}
+
+	return true, validator.OutputDiff{}, nil
+}
+
+// Verifies that a template failed with the expected error at the expected stage
+func verifyExpectedError(request PluginValidationRequest, validationResponse validator.ValidationResponse) error {
+	if validationResponse.FailedStage == "" {
+		return fmt.Errorf("Template '%s' is valid, but was expected to fail", request.name())
+	}
+
+	failure := validationResponse.Error
+	if validationResponse.FailedStage == validator.StageSchema {
+		failure = joinSchemaErrors(validationResponse.SchemaErrors)
+	}
+
+	if request.ExpectErrorStage != "" && request.ExpectErrorStage != validationResponse.FailedStage {
+		return fmt.Errorf("Template '%s' failed at the %s stage instead of the %s stage. Error: %s",
+			request.name(), validationResponse.FailedStage, request.ExpectErrorStage, failure)
+	}
+
+	if request.ExpectError != "" && !matchesExpectedError(request.ExpectError, failure) {
+		return fmt.Errorf("Template '%s' did not fail with the expected error '%s'. Error: %s", request.name(), request.ExpectError, failure)
+	}
+
+	return nil
+}
+
+// Indicates if an error contains the expected error or matches it as a regular expression
+func matchesExpectedError(expectedError string, failure string) bool {
+	if strings.Contains(failure, expectedError) {
+		return true
+	}
+
+	pattern, error := regexp.Compile(expectedError)
+	return error == nil && pattern.MatchString(failure)
+}
+
+func joinSchemaErrors(schemaErrors []validator.SchemaViolation) string {
+	violations := make([]string, len(schemaErrors))
+	for index, violation := range schemaErrors {
+		violations[index] = violation.String()
+	}
+
+	return strings.Join(violations, "; ")
+}
+
+// Evaluates the assertions of a template against the processed template. Returns the results and the number of failed assertions
+func checkAssertions(request PluginValidationRequest, validationResponse validator.ValidationResponse) ([]validator.AssertionResult, int, error) {
+	if len(request.Assertions) == 0 {
+		return nil, 0, nil
+	}
+
+	assertionResults, error := validator.EvaluateAssertions(validationResponse.Template, request.Assertions)
+	if error != nil {
+		return nil, 0, error
+	}
+
+	failedAssertions := 0
+	for _, result := range assertionResults {
+		if !result.Passed {
+			failedAssertions++
+		}
+	}
+
+	return assertionResults, failedAssertions, nil
+}
+
+// Formats the result of each assertion for the log, along with the failures of the failed ones
+func formatAssertionResults(assertionResults []validator.AssertionResult) string {
+	lines := []string{"Assertions:"}
+	for _, result := range assertionResults {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+
+		lines = append(lines, fmt.Sprintf("  %s %s", status, result.Assertion))
+		for _, failure := range result.Failures {
+			lines = append(lines, "    "+failure)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Checks that no two templates update the same golden file, as the file would only hold the output of the
+// template written last. Combinations of a matrix need a '{name}' placeholder in their expected output
+func checkGoldenFiles(requests []PluginValidationRequest) error {
+	templatesByGoldenFile := make(map[string]string, len(requests))
+	for _, request := range requests {
+		if request.ExpectedOutput == "" {
+			continue
+		}
+
+		if template, ok := templatesByGoldenFile[request.ExpectedOutput]; ok {
+			return fmt.Errorf("Templates '%s' and '%s' cannot update the same golden file '%s'. "+
+				"Add a '{name}' placeholder of a matrix variable to the expected output", template, request.name(), request.ExpectedOutput)
+		}
+		templatesByGoldenFile[request.ExpectedOutput] = request.name()
+	}
+
+	return nil
+}
+
+// Writes the processed template to a golden file in a normalized format that keeps the order of its keys, if the
+// content of the file differs. Returns whether the file was created, updated or unchanged
+func updateGoldenFile(goldenFile string, processedTemplate string) (string, error) {
+	document, error := validator.ParseOrderedYaml(processedTemplate)
+	if error != nil {
+		return "", error
+	}
+	content := []byte(validator.NormalizeYaml(document))
+
+	status := goldenUpdated
+	existingContent, error := os.ReadFile(goldenFile)
+	if errors.Is(error, fs.ErrNotExist) {
+		status = goldenCreated
+	} else if error != nil {
+		return "", error
+	} else if bytes.Equal(existingContent, content) {
+		return goldenUnchanged, nil
+	}
+
+	error = os.MkdirAll(filepath.Dir(goldenFile), 0755)
+	if error != nil {
+		return "", error
+	}
+
+	return status, os.WriteFile(goldenFile, content, 0644)
+}
+
+// Logs the number of golden files in each status, along with the files that changed
+func logGoldenSummary(goldenFiles map[string][]string) {
+	log.Printf("Golden files: %d created, %d updated, %d unchanged",
+		len(goldenFiles[goldenCreated]), len(goldenFiles[goldenUpdated]), len(goldenFiles[goldenUnchanged]))
+
+	for _, status := range []string{goldenCreated, goldenUpdated} {
+		for _, goldenFile := range goldenFiles[status] {
+			log.Printf("  %s: %s", status, goldenFile)
+		}
+	}
+}
+
+// Formats the differences between the expected and the actual output for the log
+func formatOutputDiff(outputDiff validator.OutputDiff) string {
+	differences := make([]string, len(outputDiff.Differences))
+	for index, difference := range outputDiff.Differences {
+		differences[index] = "  " + difference.String()
+	}
+
+	return "Differences:\n" + strings.Join(differences, "\n") + "\n" + outputDiff.UnifiedDiff
+}
 
-		return reflect.DeepEqual(expectedOutputMap, processedTemplateMap)
+// Writes the differences of the templates that did not match their expected output as json
+func writeDiffReport(reportFile string, entries []diffReportEntry) error {
+	output, error := json.MarshalIndent(entries, "", "  ")
+	if error != nil {
+		return error
 	}
 
-	return true
+	return os.WriteFile(reportFile, append(output, '\n'), 0644)
 }
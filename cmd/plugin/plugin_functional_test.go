@@ -92,7 +92,7 @@ func TestMainWithEnvVariables(test *testing.T) {
 			},
 			1,
 			fmt.Sprintf(
-				"Template '%s' is invalid. Error: Unable to parse template",
+				"Template '%s' is invalid. Error: template: test:4: unterminated quoted string",
 				"test/testdata/input_parse_error_template.yml",
 			),
 		},
@@ -4,14 +4,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/devatherock/vela-template-tester/pkg/validator"
 	"github.com/devatherock/vela-template-tester/test/helper"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v2"
 )
@@ -135,6 +139,14 @@ func TestRunAppWithTemplatesParameter(test *testing.T) {
 				helper.AbsolutePath("test/testdata/output_starlark_template.yml"),
 			),
 		},
+		{
+			"--templates",
+			fmt.Sprintf(
+				`[{"input_file":"%s","expected_output":"%s","template_type":"pipeline"}]`,
+				helper.AbsolutePath("test/testdata/input_pipeline.yml"),
+				helper.AbsolutePath("test/testdata/output_pipeline.yml"),
+			),
+		},
 	}
 
 	for _, data := range cases {
@@ -159,6 +171,43 @@ func TestRun(test *testing.T) {
 			nil,
 			-1,
 		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_template.yml"),
+				"assertions": `[{"path":"steps[*].ruleset.branch","length":2},{"path":"steps[0].secrets","equals":["slack_webhook"]}]`,
+			},
+			nil,
+			-1,
+		},
+		{
+			map[string]string{
+				"input-file":         helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
+				"variables":          `{"notification_branch":"develop"}`,
+				"expect-error":       "did not find expected ','",
+				"expect-error-stage": "yaml",
+			},
+			nil,
+			-1,
+		},
+		{
+			map[string]string{
+				"input-file":         helper.AbsolutePath("test/testdata/input_schema_error_template.yml"),
+				"variables":          `{"image":"alpine"}`,
+				"expect-error":       `steps\[1\]\.name: is (required|missing)`,
+				"expect-error-stage": "schema",
+			},
+			nil,
+			-1,
+		},
+		{
+			map[string]string{
+				"input-file":    helper.AbsolutePath("test/testdata/input_build_context_template.py"),
+				"template-type": "starlark",
+				"build-context": `{"branch":"develop"}`,
+			},
+			nil,
+			-1,
+		},
 		{
 			map[string]string{
 				"input-file": helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
@@ -170,6 +219,140 @@ func TestRun(test *testing.T) {
 			),
 			1,
 		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_template.yml"),
+				"variables":  `{"notification_branch":"develop","notification_events":"push"}`,
+				"strict":     "true",
+			},
+			nil,
+			1,
+		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_strict_template.yml"),
+				"variables":  `{"name":"notify","slack":{"channel":"builds"}}`,
+				"strict":     "true",
+			},
+			fmt.Errorf(
+				"Template '%s' is invalid. Error: undefined variables: 'image' at line 3, column 15, 'recipients' at line 11, column 17",
+				helper.AbsolutePath("test/testdata/input_strict_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_variable_schema_template.yml"),
+				"variables":  `{"go_version":1.23,"commands":["go build"]}`,
+			},
+			fmt.Errorf(
+				"Template '%s' is invalid. Error: variables do not match the variable schema: go_version: expected string, found number",
+				helper.AbsolutePath("test/testdata/input_variable_schema_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_schema_error_template.yml"),
+				"variables":  `{"image":"alpine"}`,
+			},
+			fmt.Errorf(
+				"Template '%s' is not a valid vela pipeline. Violations: %s",
+				helper.AbsolutePath("test/testdata/input_schema_error_template.yml"),
+				"steps[0].ruleset.branch[1]: expected string, found integer; steps[1].name: is required; "+
+					"steps[1].pull: expected string, found boolean; steps[1]: no commands, environment, parameters, secrets or template provided",
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":         helper.AbsolutePath("test/testdata/input_starlark_template.py"),
+				"template-type":      "starlark",
+				"variables":          `{"image":"golang:1.23"}`,
+				"max-starlark-steps": "5",
+			},
+			fmt.Errorf(
+				"Template '%s' is invalid. Error: starlark execution exceeded the limit of 5 steps",
+				helper.AbsolutePath("test/testdata/input_starlark_template.py"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":       helper.AbsolutePath("test/testdata/input_template.yml"),
+				"max-output-bytes": "10",
+			},
+			fmt.Errorf(
+				"Template '%s' is invalid. Error: template output exceeds the limit of 10 bytes",
+				helper.AbsolutePath("test/testdata/input_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":   helper.AbsolutePath("test/testdata/input_env_function_template.yml"),
+				"vela-version": "0.16.0",
+			},
+			fmt.Errorf(
+				"Template '%s' is invalid. Error: template: test:5: function \"env\" is not provided by vela 0.16.0",
+				helper.AbsolutePath("test/testdata/input_env_function_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":         helper.AbsolutePath("test/testdata/input_template.yml"),
+				"expect-error-stage": "execute",
+			},
+			fmt.Errorf(
+				"Template '%s' is valid, but was expected to fail",
+				helper.AbsolutePath("test/testdata/input_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":         helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
+				"variables":          `{"notification_branch":"develop"}`,
+				"expect-error-stage": "parse",
+			},
+			fmt.Errorf(
+				"Template '%s' failed at the yaml stage instead of the parse stage. Error: yaml: line 4: did not find expected ',' or ']'",
+				helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":   helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
+				"variables":    `{"notification_branch":"develop"}`,
+				"expect-error": "unknown anchor",
+			},
+			fmt.Errorf(
+				"Template '%s' did not fail with the expected error 'unknown anchor'. Error: yaml: line 4: did not find expected ',' or ']'",
+				helper.AbsolutePath("test/testdata/input_invalid_template.yml"),
+			),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file":         helper.AbsolutePath("test/testdata/input_template.yml"),
+				"expect-error-stage": "render",
+			},
+			fmt.Errorf("invalid expected error stage 'render', expected one of variables, parse, execute, yaml, schema"),
+			1,
+		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_template.yml"),
+				"assertions": `[{"path":"steps[0].ruleset.branch","equals":"develop"},{"path":"steps[0].image","exists":true}]`,
+			},
+			fmt.Errorf(
+				"Template '%s' is valid, but failed 1 of 2 assertions",
+				helper.AbsolutePath("test/testdata/input_template.yml"),
+			),
+			1,
+		},
 		{
 			map[string]string{
 				"input-file":      helper.AbsolutePath("test/testdata/input_template.yml"),
@@ -287,7 +470,9 @@ func TestVerifyOutputDisabled(test *testing.T) {
 	request := PluginValidationRequest{}
 	validationResponse := validator.ValidationResponse{}
 
-	assert.True(test, verifyOutput(request, validationResponse))
+	matches, _, err := verifyOutput(request, validationResponse)
+	assert.Nil(test, err)
+	assert.True(test, matches)
 }
 
 func TestVerifyOutputSuccess(test *testing.T) {
@@ -298,7 +483,10 @@ func TestVerifyOutputSuccess(test *testing.T) {
 	expectedOutput, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/output_template.yml"))
 	validationResponse.Template = string(expectedOutput)
 
-	assert.True(test, verifyOutput(request, validationResponse))
+	matches, outputDiff, err := verifyOutput(request, validationResponse)
+	assert.Nil(test, err)
+	assert.True(test, matches)
+	assert.Equal(test, "", outputDiff.UnifiedDiff)
 }
 
 func TestVerifyOutputFailure(test *testing.T) {
@@ -308,7 +496,464 @@ func TestVerifyOutputFailure(test *testing.T) {
 	validationResponse := validator.ValidationResponse{}
 	validationResponse.Template = "foo: bar"
 
-	assert.False(test, verifyOutput(request, validationResponse))
+	matches, outputDiff, err := verifyOutput(request, validationResponse)
+	assert.Nil(test, err)
+	assert.False(test, matches)
+	assert.Equal(test, []validator.OutputDifference{
+		{Path: "foo", Type: validator.DifferenceAdded, Actual: "bar"},
+		{Path: "metadata", Type: validator.DifferenceRemoved, Expected: validator.OrderedMap{{Key: "template", Value: true}}},
+		{Path: "slack_plugin_image", Type: validator.DifferenceRemoved, Expected: validator.OrderedMap{{Key: "image", Value: "devatherock/simple-slack:0.2.0"}}},
+		{Path: "steps", Type: validator.DifferenceRemoved, Expected: []interface{}{
+			// Keys are in the order of the expected output, followed by the merged keys
+			validator.OrderedMap{
+				{Key: "name", Value: "notify_success"},
+				{Key: "ruleset", Value: validator.OrderedMap{{Key: "branch", Value: "develop"}, {Key: "event", Value: "push"}}},
+				{Key: "secrets", Value: []interface{}{"slack_webhook"}},
+				{Key: "parameters", Value: validator.OrderedMap{
+					{Key: "color", Value: "#33ad7f"},
+					{Key: "text", Value: "Success: {{.BuildLink}} ({{.BuildRef}}) by {{.BuildAuthor}}\n{{.BuildMessage}}"},
+				}},
+				{Key: "image", Value: "devatherock/simple-slack:0.2.0"},
+			},
+		}},
+	}, outputDiff.Differences)
+}
+
+func TestVerifyOutputError(test *testing.T) {
+	request := PluginValidationRequest{ExpectedOutput: filepath.Join(test.TempDir(), "missing.yml")}
+
+	_, _, err := verifyOutput(request, validator.ValidationResponse{Template: "foo: bar"})
+	assert.True(test, os.IsNotExist(err))
+
+	request.ExpectedOutput = helper.AbsolutePath("test/testdata/output_invalid_template.txt")
+	_, _, err = verifyOutput(request, validator.ValidationResponse{Template: "foo: bar"})
+	assert.ErrorContains(test, err, "expected output is not a valid yaml")
+}
+
+func TestRunWithVerificationErrors(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportFile := filepath.Join(test.TempDir(), "results.json")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%[1]s","expected_output":"%[2]s"},{"input_file":"%[1]s","assertions":[{"path":"steps[x]","exists":true}]},{"input_file":"%[1]s"}]`,
+		helper.AbsolutePath("test/testdata/input_template.yml"), filepath.Join(test.TempDir(), "missing.yml")), "")
+	set.String("concurrency", "2", "")
+	set.String("report", reportFile, "")
+
+	// Failures are reported along with the other templates instead of stopping the tests
+	err := run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, 1, exitCode[0])
+	assert.NotNil(test, err)
+
+	content, _ := ioutil.ReadFile(reportFile)
+	report := struct {
+		Results []testResult
+	}{}
+	assert.Nil(test, json.Unmarshal(content, &report))
+	assert.Equal(test, 3, len(report.Results))
+	assert.Equal(test, testFailed, report.Results[0].Status)
+	assert.Contains(test, report.Results[0].Message, "could not be compared with its expected output. Error: open ")
+	assert.Equal(test, testFailed, report.Results[1].Status)
+	assert.Contains(test, report.Results[1].Message, "has invalid assertions. Error: invalid assertion 'steps[x]'")
+	assert.Equal(test, testPassed, report.Results[2].Status)
+}
+
+func TestFormatOutputDiff(test *testing.T) {
+	outputDiff, _ := validator.DiffOutput("steps:\n  - name: build\n    image: golang:1.22\n", "steps:\n  - name: build\n    image: golang:1.23\n")
+
+	assert.Equal(test, `Differences:
+  steps[0].image: changed from "golang:1.22" to "golang:1.23"
+--- expected
++++ actual
+@@ -1,3 +1,3 @@
+ steps:
+ - name: build
+-  image: golang:1.22
++  image: golang:1.23
+`, formatOutputDiff(outputDiff))
+}
+
+func TestFormatAssertionResults(test *testing.T) {
+	two := 2
+	actual := formatAssertionResults([]validator.AssertionResult{
+		{Assertion: validator.Assertion{Path: "steps[0].image", Equals: "alpine"}, Passed: true},
+		{
+			Assertion: validator.Assertion{Path: "steps[*].commands", Length: &two},
+			Failures:  []string{"steps[0].commands: expected length 2, found 1", "steps[1].commands: not found"},
+		},
+	})
+
+	assert.Equal(test, `Assertions:
+  PASS steps[0].image equals "alpine"
+  FAIL steps[*].commands has length 2
+    steps[0].commands: expected length 2, found 1
+    steps[1].commands: not found`, actual)
+}
+
+func TestRunWithDiffReport(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportFile := filepath.Join(test.TempDir(), "diff-report.json")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%s","expected_output":"%s"},{"input_file":"%s","expected_output":"%s",`+
+		`"variables":{"notification_branch":"develop","notification_event":"push"}}]`,
+		helper.AbsolutePath("test/testdata/input_template.yml"), helper.AbsolutePath("test/testdata/output_template.yml"),
+		helper.AbsolutePath("test/testdata/input_template.yml"), helper.AbsolutePath("test/testdata/output_template.yml")), "")
+	set.String("diff-report", reportFile, "")
+
+	run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, 1, exitCode[0])
+
+	report, _ := ioutil.ReadFile(reportFile)
+	assert.Equal(test, fmt.Sprintf(`[
+  {
+    "input_file": "%s",
+    "expected_output": "%s",
+    "differences": [
+      {
+        "path": "steps[0].ruleset.branch",
+        "type": "changed",
+        "expected": "develop",
+        "actual": [
+          "master",
+          "v1"
+        ]
+      },
+      {
+        "path": "steps[0].ruleset.event",
+        "type": "changed",
+        "expected": "push",
+        "actual": [
+          "push",
+          "tag"
+        ]
+      }
+    ],
+    "unified_diff": "--- expected\n+++ actual\n@@ -5,8 +5,12 @@\n steps:\n - name: notify_success\n   ruleset:\n-    branch: develop\n-    event: push\n+    branch:\n+    - master\n+    - v1\n+    event:\n+    - push\n+    - tag\n   secrets:\n   - slack_webhook\n   parameters:\n"
+  }
+]
+`, helper.AbsolutePath("test/testdata/input_template.yml"), helper.AbsolutePath("test/testdata/output_template.yml")), string(report))
+}
+
+func TestRunWithUpdateGolden(test *testing.T) {
+	exitCode := captureExitCode(test)
+	goldenDirectory := test.TempDir()
+	createdFile := filepath.Join(goldenDirectory, "created", "output_template.yml")
+	updatedFile := filepath.Join(goldenDirectory, "updated.yml")
+	ioutil.WriteFile(updatedFile, []byte("steps: []\n"), 0644)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%s","expected_output":"%s"},{"input_file":"%s","expected_output":"%s"},{"input_file":"%s"}]`,
+		helper.AbsolutePath("test/testdata/input_template.yml"), createdFile,
+		helper.AbsolutePath("test/testdata/input_template.yml"), updatedFile,
+		helper.AbsolutePath("test/testdata/input_template.yml")), "")
+	set.String("update-golden", "true", "")
+
+	run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, -1, exitCode[0])
+
+	created, _ := ioutil.ReadFile(createdFile)
+	updated, _ := ioutil.ReadFile(updatedFile)
+	assert.Contains(test, string(created), "  ruleset:\n    branch:\n    - master\n    - v1\n")
+	assert.Equal(test, string(created), string(updated))
+}
+
+func TestUpdateGoldenFile(test *testing.T) {
+	goldenFile := filepath.Join(test.TempDir(), "golden.yml")
+
+	status, err := updateGoldenFile(goldenFile, "steps:\n  - name: test\n    image: alpine")
+	assert.Nil(test, err)
+	assert.Equal(test, goldenCreated, status)
+
+	status, err = updateGoldenFile(goldenFile, "steps: [ { name: test, image: alpine } ]")
+	assert.Nil(test, err)
+	assert.Equal(test, goldenUnchanged, status)
+
+	status, err = updateGoldenFile(goldenFile, "version: '1'\nsteps:\n  - name: test\n    image: golang")
+	assert.Nil(test, err)
+	assert.Equal(test, goldenUpdated, status)
+
+	// Keys keep the order of the processed template
+	content, _ := ioutil.ReadFile(goldenFile)
+	assert.Equal(test, "version: \"1\"\nsteps:\n- name: test\n  image: golang\n", string(content))
+
+	// Documents other than maps are written as well
+	status, err = updateGoldenFile(goldenFile, "- name: test\n  image: golang")
+	assert.Nil(test, err)
+	assert.Equal(test, goldenUpdated, status)
+
+	content, _ = ioutil.ReadFile(goldenFile)
+	assert.Equal(test, "- name: test\n  image: golang\n", string(content))
+}
+
+func TestUpdateGoldenFileInvalidYaml(test *testing.T) {
+	goldenFile := filepath.Join(test.TempDir(), "golden.yml")
+
+	_, err := updateGoldenFile(goldenFile, "steps: [")
+	assert.NotNil(test, err)
+	assert.NoFileExists(test, goldenFile)
+}
+
+func TestReadPipelineTemplates(test *testing.T) {
+	pipelineFile := helper.AbsolutePath("test/testdata/input_pipeline.yml")
+	pipeline, _ := ioutil.ReadFile(pipelineFile)
+	goTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_template.yml"))
+	starlarkTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_starlark_template.py"))
+
+	templates, variableSchemas, err := readPipelineTemplates(pipelineFile, string(pipeline))
+	assert.Nil(test, err)
+	assert.Equal(test, map[string]string{
+		"build":  string(starlarkTemplate),
+		"notify": string(goTemplate),
+	}, templates)
+	assert.Equal(test, map[string]string{}, variableSchemas)
+}
+
+func TestReadPipelineTemplatesWithVariableSchema(test *testing.T) {
+	pipeline := `
+templates:
+  - name: go
+    source: input_variable_schema_template.yml
+`
+	goTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.yml"))
+	variableSchema, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.schema.yml"))
+
+	templates, variableSchemas, err := readPipelineTemplates(helper.AbsolutePath("test/testdata/.vela.yml"), pipeline)
+	assert.Nil(test, err)
+	assert.Equal(test, map[string]string{"go": string(goTemplate)}, templates)
+	assert.Equal(test, map[string]string{"go": string(variableSchema)}, variableSchemas)
+}
+
+func TestReadVariableSchema(test *testing.T) {
+	variableSchema, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.schema.yml"))
+
+	cases := []struct {
+		request       PluginValidationRequest
+		expected      string
+		expectedError string
+	}{
+		{
+			PluginValidationRequest{
+				InputFile: helper.AbsolutePath("test/testdata/input_variable_schema_template.yml"),
+			},
+			string(variableSchema),
+			"",
+		},
+		{
+			PluginValidationRequest{
+				InputFile: helper.AbsolutePath("test/testdata/input_template.yml"),
+			},
+			"",
+			"",
+		},
+		{
+			PluginValidationRequest{
+				InputFile:      helper.AbsolutePath("test/testdata/input_template.yml"),
+				VariableSchema: helper.AbsolutePath("test/testdata/input_variable_schema_template.schema.yml"),
+			},
+			string(variableSchema),
+			"",
+		},
+		{
+			PluginValidationRequest{
+				InputFile:      helper.AbsolutePath("test/testdata/input_template.yml"),
+				VariableSchema: helper.AbsolutePath("test/testdata/missing.schema.yml"),
+			},
+			"",
+			fmt.Sprintf("open %s: no such file or directory", helper.AbsolutePath("test/testdata/missing.schema.yml")),
+		},
+	}
+
+	for _, data := range cases {
+		actual, err := readVariableSchema(data.request)
+
+		assert.Equal(test, data.expected, actual)
+		if data.expectedError == "" {
+			assert.Nil(test, err)
+		} else {
+			assert.Equal(test, data.expectedError, err.Error())
+		}
+	}
+}
+
+func TestReadBuildContext(test *testing.T) {
+	cases := []struct {
+		request       PluginValidationRequest
+		buildContext  string
+		expected      *validator.BuildContext
+		expectedError string
+	}{
+		{
+			PluginValidationRequest{},
+			"",
+			nil,
+			"",
+		},
+		{
+			PluginValidationRequest{},
+			`{"branch":"develop","event":"pull_request","number":12}`,
+			&validator.BuildContext{Branch: "develop", Event: "pull_request", Number: 12},
+			"",
+		},
+		{
+			PluginValidationRequest{BuildContext: &validator.BuildContext{Tag: "v1.0.0"}},
+			`{"branch":"develop"}`,
+			&validator.BuildContext{Tag: "v1.0.0"},
+			"",
+		},
+		{
+			PluginValidationRequest{},
+			`{"number":"twelve"}`,
+			nil,
+			"invalid build context: json: cannot unmarshal string into Go struct field BuildContext.number of type int",
+		},
+	}
+
+	for _, data := range cases {
+		set := flag.NewFlagSet("test", 0)
+		set.String("build-context", data.buildContext, "")
+		context := cli.NewContext(nil, set, nil)
+
+		actual, err := readBuildContext(data.request, context)
+
+		assert.Equal(test, data.expected, actual)
+		if data.expectedError == "" {
+			assert.Nil(test, err)
+		} else {
+			assert.Equal(test, data.expectedError, err.Error())
+		}
+	}
+}
+
+func TestReadPipelineTemplatesMissingFile(test *testing.T) {
+	pipeline := `
+templates:
+  - name: go
+    source: templates/go.yml
+`
+
+	_, _, err := readPipelineTemplates(helper.AbsolutePath("test/testdata/.vela.yml"), pipeline)
+	assert.Equal(test, fmt.Sprintf(
+		"unable to read template 'go': open %s: no such file or directory",
+		helper.AbsolutePath("test/testdata/templates/go.yml"),
+	), err.Error())
+}
+
+func TestListVariables(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	cases := []struct {
+		parameters       map[string]string
+		expected         error
+		expectedOutput   string
+		expectedExitCode int
+	}{
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_template.yml"),
+			},
+			nil,
+			"- name: notification_branch\n  default: '[ master, v1 ]'\n  lines:\n  - 10\n" +
+				"- name: notification_event\n  default: '[ push, tag ]'\n  lines:\n  - 11\n",
+			-1,
+		},
+		{
+			map[string]string{
+				"input-file":    helper.AbsolutePath("test/testdata/input_starlark_template.py"),
+				"template-type": "starlark",
+			},
+			nil,
+			"- name: image\n  lines:\n  - 7\n",
+			-1,
+		},
+		{
+			map[string]string{
+				"input-file": helper.AbsolutePath("test/testdata/input_parse_error_template.yml"),
+			},
+			fmt.Errorf(
+				"Template '%s' is invalid. Error: template: test:4: unterminated quoted string",
+				helper.AbsolutePath("test/testdata/input_parse_error_template.yml"),
+			),
+			"",
+			1,
+		},
+		{
+			map[string]string{},
+			nil,
+			"",
+			0,
+		},
+	}
+
+	for _, data := range cases {
+		exitCode[0] = -1
+		set := flag.NewFlagSet("test", 0)
+		for key, value := range data.parameters {
+			set.String(key, value, "")
+		}
+
+		output := new(bytes.Buffer)
+		context := cli.NewContext(&cli.App{Writer: output}, set, nil)
+		actual := listVariables(context)
+
+		assert.Equal(test, data.expected, actual)
+		assert.Equal(test, data.expectedOutput, output.String())
+		assert.Equal(test, data.expectedExitCode, exitCode[0])
+	}
+}
+
+func TestFormatDiagnostic(test *testing.T) {
+	cases := []struct {
+		diagnostic validator.TemplateDiagnostic
+		expected   string
+	}{
+		{
+			validator.TemplateDiagnostic{
+				Stage:   "execute",
+				Line:    2,
+				Column:  19,
+				Action:  ".step.name",
+				Message: "can't evaluate field name in type interface {}",
+				Excerpt: "   2 |   - name: {{ .step.name }}\n     |                   ^",
+			},
+			"template.yml:2:19: execute error: can't evaluate field name in type interface {} (at <.step.name>)\n" +
+				"   2 |   - name: {{ .step.name }}\n     |                   ^",
+		},
+		{
+			validator.TemplateDiagnostic{
+				Stage:   "parse",
+				Line:    4,
+				Message: "unterminated quoted string",
+			},
+			"template.yml:4: parse error: unterminated quoted string",
+		},
+		{
+			validator.TemplateDiagnostic{
+				Stage:   "parse",
+				Message: "undefined: main",
+			},
+			"template.yml: parse error: undefined: main",
+		},
+		{
+			validator.TemplateDiagnostic{
+				Stage:         "yaml",
+				Line:          4,
+				Column:        5,
+				Message:       "mapping values are not allowed in this context",
+				Excerpt:       "   4 |     image: {{ .image }}\n     |     ^",
+				OutputLine:    5,
+				OutputExcerpt: "   5 |     image: golangci: v1\n     |     ^",
+			},
+			"template.yml:4:5: yaml error: mapping values are not allowed in this context\n" +
+				"   4 |     image: {{ .image }}\n     |     ^\n" +
+				"line 5 of the processed template:\n" +
+				"   5 |     image: golangci: v1\n     |     ^",
+		},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, formatDiagnostic("template.yml", data.diagnostic))
+	}
 }
 
 // Overrides exit code function for tests
@@ -323,3 +968,119 @@ func captureExitCode(test *testing.T) []int {
 
 	return exitCode
 }
+
+func TestRunWithLint(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportFile := filepath.Join(test.TempDir(), "results.json")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%[1]s","variables":{"notify":true}},`+
+		`{"input_file":"%[1]s","variables":{"notify":true},"lint_rules":{"undeclared-secret":"off"}},`+
+		`{"input_file":"%[1]s","variables":{"notify":false}}]`,
+		helper.AbsolutePath("test/testdata/input_coverage_template.yml")), "")
+	set.String("lint", "true", "")
+	set.String("lint-rules", `{"missing-ruleset":"off"}`, "")
+	set.String("lint-threshold", "warning", "")
+	set.String("report", reportFile, "")
+
+	// Only the first template uses a secret that it does not declare
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.EqualError(test, actual, fmt.Sprintf("Template '%s' is valid, but has 1 lint findings at or above the warning severity",
+		helper.AbsolutePath("test/testdata/input_coverage_template.yml")))
+	assert.Equal(test, 1, exitCode[0])
+
+	content, _ := ioutil.ReadFile(reportFile)
+	report := struct {
+		Failed  int
+		Passed  int
+		Results []testResult
+	}{}
+	assert.Nil(test, json.Unmarshal(content, &report))
+	assert.Equal(test, 1, report.Failed)
+	assert.Equal(test, 2, report.Passed)
+	assert.Equal(test, "warning: steps[1].secrets[0]: secret 'slack_webhook' is not declared in the pipeline (undeclared-secret)", report.Results[0].Details)
+}
+
+func TestRunWithLintBelowThreshold(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%s","variables":{"notify":true},"lint":true}]`,
+		helper.AbsolutePath("test/testdata/input_coverage_template.yml")), "")
+
+	assert.Nil(test, run(cli.NewContext(nil, set, nil)))
+	assert.Equal(test, -1, exitCode[0])
+}
+
+func TestRunWithInvalidLintConfig(test *testing.T) {
+	cases := []struct {
+		flag     string
+		value    string
+		expected string
+	}{
+		{"lint-threshold", "fatal", "invalid lint threshold 'fatal', expected one of error, warning, info, off"},
+		{"lint-rules", "latest-tag", "invalid lint rules 'latest-tag': invalid character 'l' looking for beginning of value"},
+	}
+
+	for _, data := range cases {
+		captureExitCode(test)
+
+		set := flag.NewFlagSet("test", 0)
+		set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+		set.String("lint", "true", "")
+		set.String(data.flag, data.value, "")
+
+		assert.EqualError(test, run(cli.NewContext(nil, set, nil)), data.expected)
+	}
+}
+
+func TestReadLintConfig(test *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.String("lint-rules", `{"missing-ruleset":"warning","latest-tag":"error"}`, "")
+	context := cli.NewContext(nil, set, nil)
+
+	// Not linted unless enabled by the parameter or the template
+	lintConfig, error := readLintConfig(PluginValidationRequest{}, context)
+	assert.Nil(test, error)
+	assert.Nil(test, lintConfig)
+
+	lintConfig, error = readLintConfig(PluginValidationRequest{Lint: true, LintRules: map[string]string{"latest-tag": "off"}}, context)
+	assert.Nil(test, error)
+	assert.Equal(test, &validator.LintConfig{Severities: map[string]string{"missing-ruleset": "warning", "latest-tag": "off"}}, lintConfig)
+}
+
+func TestTestTemplateWithUnescapedExpressions(test *testing.T) {
+	templateFile := filepath.Join(test.TempDir(), "notify.yml")
+	ioutil.WriteFile(templateFile, []byte("steps:\n  - name: notify\n    image: devatherock/simple-slack:0.2.0\n    parameters:\n"+
+		"      text: {{.BuildLink}} deployed to {{ .DeployLink }} by {{\"{{.BuildAuthor}}\"}}\n"), 0644)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("downstream-variables", "DeployLink", "")
+
+	outcome := testTemplate(cli.NewContext(nil, set, nil), PluginValidationRequest{InputFile: templateFile})
+	assert.Equal(test, testPassed, outcome.result.Status)
+	assert.Equal(test, []logEntry{
+		{log.WarnLevel, fmt.Sprintf(`Template '%s' has an unescaped expression: '{{.BuildLink}}' at line 5 is not a template variable, `+
+			`but probably one of a downstream plugin. Escape it as '{{"{{.BuildLink}}"}}'`, templateFile)},
+		{log.WarnLevel, fmt.Sprintf(`Template '%s' has an unescaped expression: '{{.DeployLink}}' at line 5 is not a template variable, `+
+			`but probably one of a downstream plugin. Escape it as '{{"{{.DeployLink}}"}}'`, templateFile)},
+		{log.InfoLevel, fmt.Sprintf("Template '%s' is valid.", templateFile)},
+	}, outcome.logs)
+}
+
+func TestTestTemplateWithAmbiguousScalars(test *testing.T) {
+	templateFile := filepath.Join(test.TempDir(), "build.yml")
+	ioutil.WriteFile(templateFile, []byte("steps:\n  - name: build\n    image: golang:1.23\n    ruleset:\n      branch: {{ .branch }}\n    commands: [ go build ]\n"), 0644)
+
+	outcome := testTemplate(cli.NewContext(nil, flag.NewFlagSet("test", 0), nil), PluginValidationRequest{
+		InputFile: templateFile,
+		Variables: map[string]interface{}{"branch": "no"},
+	})
+	// The warning explains why the branch is not a string
+	assert.Equal(test, testFailed, outcome.result.Status)
+	assert.Equal(test, []logEntry{
+		{log.WarnLevel, fmt.Sprintf(`Template '%s' has an ambiguous scalar at steps[0].ruleset.branch: value 'no' at line 5, column 15 `+
+			`is read as the boolean false. Quote it as "no" if a string is intended`, templateFile)},
+		{log.ErrorLevel, fmt.Sprintf("Template '%s' is not a valid vela pipeline. Violations: steps[0].ruleset.branch: expected string or list, found boolean", templateFile)},
+	}, outcome.logs)
+}
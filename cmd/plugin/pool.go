@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"sync/atomic"
+
+	"github.com/devatherock/vela-template-tester/pkg/validator"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Message of the templates skipped with the 'fail-fast' parameter
+const failFastMessage = "not tested after a previous failure"
+
+// A message logged by a template, at the time the template is reported
+type logEntry struct {
+	level   log.Level
+	message string
+}
+
+// Outcome of testing a template, along with what it contributes to the log and the reports
+type templateOutcome struct {
+	result        testResult
+	logs          []logEntry
+	diff          *diffReportEntry // Differences, if the template did not match its expected output
+	goldenStatus  string           // Status of the golden file, if it was updated
+	goldenFile    string
+	coverage      []validator.BranchCoverage
+	templateFiles map[string]string // Files of the templates referenced by a pipeline, by name
+	error         error             // Error that stops the tests, like an unreadable template file
+}
+
+func (outcome *templateOutcome) logf(level log.Level, format string, args ...interface{}) {
+	outcome.logs = append(outcome.logs, logEntry{level, fmt.Sprintf(format, args...)})
+}
+
+// Tests the templates using up to 'concurrency' workers, yielding the outcomes in the order of the requests.
+// With 'fail-fast', the templates not yet started once a template fails are skipped
+func testTemplates(context *cli.Context, requests []PluginValidationRequest) iter.Seq[templateOutcome] {
+	return func(yield func(templateOutcome) bool) {
+		failFast := context.Bool("fail-fast")
+		workers := min(max(context.Int("concurrency"), 1), len(requests))
+
+		outcomes := make([]templateOutcome, len(requests))
+		done := make([]chan struct{}, len(requests))
+		for index := range done {
+			done[index] = make(chan struct{})
+		}
+
+		// Stops handing out requests once the caller stops consuming the outcomes
+		stopped := make(chan struct{})
+		defer close(stopped)
+
+		indexes := make(chan int)
+		go func() {
+			defer close(indexes)
+			for index := range requests {
+				select {
+				case indexes <- index:
+				case <-stopped:
+					return
+				}
+			}
+		}()
+
+		var failed atomic.Bool
+		for worker := 0; worker < workers; worker++ {
+			go func() {
+				for index := range indexes {
+					if failFast && failed.Load() {
+						outcomes[index] = skippedOutcome(requests[index])
+					} else {
+						outcomes[index] = testTemplate(context, requests[index])
+						if outcomes[index].error != nil || outcomes[index].result.Status == testFailed {
+							failed.Store(true)
+						}
+					}
+					close(done[index])
+				}
+			}()
+		}
+
+		for index := range requests {
+			<-done[index]
+			if !yield(outcomes[index]) {
+				return
+			}
+		}
+	}
+}
+
+func skippedOutcome(request PluginValidationRequest) templateOutcome {
+	outcome := templateOutcome{
+		result: testResult{
+			Name:        request.name(),
+			InputFile:   request.InputFile,
+			Combination: request.combination,
+			Status:      testSkipped,
+			Message:     failFastMessage,
+		},
+	}
+	outcome.logf(log.WarnLevel, "Template '%s' was %s", request.name(), failFastMessage)
+
+	return outcome
+}
@@ -0,0 +1,151 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestTestTemplates(test *testing.T) {
+	requests, _ := expandMatrix(PluginValidationRequest{
+		InputFile: helper.AbsolutePath("test/testdata/input_template.yml"),
+		Matrix: &Matrix{Variables: map[string][]interface{}{
+			"notification_branch": {"develop", "main", "v1", "v2"},
+			"notification_event":  {"push", "tag"},
+		}},
+	})
+
+	for _, concurrency := range []string{"1", "3", "16"} {
+		set := flag.NewFlagSet("test", 0)
+		set.String("concurrency", concurrency, "")
+
+		names := []string{}
+		for outcome := range testTemplates(cli.NewContext(nil, set, nil), requests) {
+			assert.Nil(test, outcome.error)
+			assert.Equal(test, testPassed, outcome.result.Status)
+			names = append(names, outcome.result.Name)
+		}
+
+		expectedNames := []string{}
+		for _, request := range requests {
+			expectedNames = append(expectedNames, request.name())
+		}
+		assert.Equal(test, expectedNames, names, concurrency)
+	}
+}
+
+func TestTestTemplatesStopped(test *testing.T) {
+	requests := []PluginValidationRequest{
+		{InputFile: helper.AbsolutePath("test/testdata/input_template.yml")},
+		{InputFile: helper.AbsolutePath("test/testdata/input_invalid_template.yml")},
+		{InputFile: helper.AbsolutePath("test/testdata/input_template.yml")},
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("concurrency", "2", "")
+
+	tested := 0
+	for range testTemplates(cli.NewContext(nil, set, nil), requests) {
+		tested++
+		break
+	}
+	assert.Equal(test, 1, tested)
+}
+
+func TestRunConcurrently(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportFile := filepath.Join(test.TempDir(), "results.json")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+	set.String("expected-output", helper.AbsolutePath("test/testdata/output_template.yml"), "")
+	set.String("matrix", `{"notification_branch":["main","develop","v1"],"notification_event":["push"]}`, "")
+	set.String("concurrency", "3", "")
+	set.String("report", reportFile, "")
+
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, fmt.Errorf(
+		"Template '%s [notification_branch=v1, notification_event=push]' is valid, but did not match expected output",
+		helper.AbsolutePath("test/testdata/input_template.yml"),
+	), actual)
+	assert.Equal(test, 1, exitCode[0])
+
+	content, _ := ioutil.ReadFile(reportFile)
+	report := struct{ Results []testResult }{}
+	assert.Nil(test, json.Unmarshal(content, &report))
+
+	statuses := []string{}
+	for _, result := range report.Results {
+		statuses = append(statuses, result.Combination+": "+result.Status)
+	}
+	assert.Equal(test, []string{
+		"notification_branch=main, notification_event=push: failed",
+		"notification_branch=develop, notification_event=push: passed",
+		"notification_branch=v1, notification_event=push: failed",
+	}, statuses)
+}
+
+func TestRunFailFast(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportFile := filepath.Join(test.TempDir(), "results.json")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+	set.String("expected-output", helper.AbsolutePath("test/testdata/output_template.yml"), "")
+	set.String("matrix", `{"notification_branch":["main","develop","v1"],"notification_event":["push"]}`, "")
+	set.String("fail-fast", "true", "")
+	set.String("report", reportFile, "")
+
+	actual := run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, fmt.Errorf(
+		"Template '%s [notification_branch=main, notification_event=push]' is valid, but did not match expected output",
+		helper.AbsolutePath("test/testdata/input_template.yml"),
+	), actual)
+	assert.Equal(test, 1, exitCode[0])
+
+	content, _ := ioutil.ReadFile(reportFile)
+	report := struct {
+		Failed  int
+		Skipped int
+		Results []testResult
+	}{}
+	assert.Nil(test, json.Unmarshal(content, &report))
+	assert.Equal(test, 1, report.Failed)
+	assert.Equal(test, 2, report.Skipped)
+	assert.Equal(test, failFastMessage, report.Results[1].Message)
+	assert.Equal(test, failFastMessage, report.Results[2].Message)
+}
+
+func TestRunWithTimeout(test *testing.T) {
+	exitCode := captureExitCode(test)
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%[1]s","timeout":"30s"},{"input_file":"%[1]s"}]`,
+		helper.AbsolutePath("test/testdata/input_template.yml")), "")
+	set.String("timeout", "1ns", "")
+
+	// Only the template without a timeout of its own exceeds the one from the parameter
+	assert.EqualError(test, run(cli.NewContext(nil, set, nil)), fmt.Sprintf(
+		"Template '%s' is invalid. Error: template processing exceeded the timeout of 1ns",
+		helper.AbsolutePath("test/testdata/input_template.yml"),
+	))
+	assert.Equal(test, 1, exitCode[0])
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("templates", fmt.Sprintf(`[{"input_file":"%s","timeout":"30"}]`, helper.AbsolutePath("test/testdata/input_template.yml")), "")
+
+	assert.EqualError(test, run(cli.NewContext(nil, set, nil)), fmt.Sprintf(
+		"invalid timeout '30' of template '%s': time: missing unit in duration \"30\"",
+		helper.AbsolutePath("test/testdata/input_template.yml"),
+	))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Statuses of a tested template
+const (
+	testPassed  = "passed"
+	testFailed  = "failed"
+	testSkipped = "skipped"
+)
+
+// Formats of the test reports
+const (
+	reportJunit = "junit"
+	reportJson  = "json"
+	reportTap   = "tap"
+)
+
+// Name of the test suite in the reports
+const testSuiteName = "vela-template-tester"
+
+// Outcome of testing a template or a matrix combination of a template
+type testResult struct {
+	Name        string        `json:"name"`
+	InputFile   string        `json:"input_file"`
+	Combination string        `json:"combination,omitempty"`
+	Status      string        `json:"status"`
+	Message     string        `json:"message,omitempty"` // Reason for the failure or for skipping the template
+	Details     string        `json:"details,omitempty"` // Diagnostics, assertion results or differences in the output
+	Output      string        `json:"output,omitempty"`  // Processed template, for failed templates
+	Duration    time.Duration `json:"-"`
+	Seconds     float64       `json:"duration"`
+}
+
+// Marks the template as failed, with the reason and the details of the failure
+func (result *testResult) fail(message string, details string) {
+	result.Status = testFailed
+	result.Message = message
+	result.Details = details
+}
+
+// Number of results with each status
+func countResults(results []testResult) map[string]int {
+	counts := map[string]int{testPassed: 0, testFailed: 0, testSkipped: 0}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	return counts
+}
+
+func totalDuration(results []testResult) time.Duration {
+	var duration time.Duration
+	for _, result := range results {
+		duration += result.Duration
+	}
+
+	return duration
+}
+
+// Writes the test results to each of the comma separated report files. The format of a report is specified
+// as a prefix like 'junit:report.xml' or derived from the extension: '.xml' for junit, '.json' for json and '.tap' for tap
+func writeReports(reports string, results []testResult) error {
+	for _, report := range strings.Split(reports, ",") {
+		format, reportFile, error := parseReport(strings.TrimSpace(report))
+		if error != nil {
+			return error
+		}
+
+		var content []byte
+		switch format {
+		case reportJunit:
+			content, error = junitReport(results)
+		case reportJson:
+			content, error = jsonReport(results)
+		default:
+			content, error = tapReport(results)
+		}
+		if error != nil {
+			return error
+		}
+
+		error = os.WriteFile(reportFile, content, 0644)
+		if error != nil {
+			return error
+		}
+	}
+
+	return nil
+}
+
+// Returns the format and the file of a report
+func parseReport(report string) (string, string, error) {
+	if format, reportFile, found := strings.Cut(report, ":"); found {
+		switch format {
+		case reportJunit, reportJson, reportTap:
+			return format, reportFile, nil
+		}
+	}
+
+	switch filepath.Ext(report) {
+	case ".xml":
+		return reportJunit, report, nil
+	case ".json":
+		return reportJson, report, nil
+	case ".tap":
+		return reportTap, report, nil
+	}
+
+	return "", "", fmt.Errorf("unknown format of report '%s'. Prefix the file with 'junit:', 'json:' or 'tap:'", report)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// Builds a junit xml report with a test case for each template
+func junitReport(results []testResult) ([]byte, error) {
+	counts := countResults(results)
+	suite := junitTestSuite{
+		Name:      testSuiteName,
+		Tests:     len(results),
+		Failures:  counts[testFailed],
+		Skipped:   counts[testSkipped],
+		Time:      formatSeconds(totalDuration(results)),
+		TestCases: make([]junitTestCase, len(results)),
+	}
+
+	for index, result := range results {
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: result.InputFile,
+			Time:      formatSeconds(result.Duration),
+			SystemOut: result.Output,
+		}
+
+		switch result.Status {
+		case testFailed:
+			testCase.Failure = &junitMessage{result.Message, result.Details}
+		case testSkipped:
+			testCase.Skipped = &junitMessage{Message: result.Message}
+		}
+		suite.TestCases[index] = testCase
+	}
+
+	output, error := xml.MarshalIndent(junitTestSuites{
+		Name:     testSuiteName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if error != nil {
+		return nil, error
+	}
+
+	return []byte(xml.Header + string(output) + "\n"), nil
+}
+
+// Builds a json report with the counts of the statuses and the result of each template
+func jsonReport(results []testResult) ([]byte, error) {
+	jsonResults := make([]testResult, len(results))
+	for index, result := range results {
+		result.Seconds = result.Duration.Seconds()
+		jsonResults[index] = result
+	}
+
+	// Templates are written as is, without escaping characters like '<' and '>'
+	output := &bytes.Buffer{}
+	encoder := json.NewEncoder(output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	counts := countResults(results)
+	error := encoder.Encode(struct {
+		Tests    int          `json:"tests"`
+		Passed   int          `json:"passed"`
+		Failed   int          `json:"failed"`
+		Skipped  int          `json:"skipped"`
+		Duration float64      `json:"duration"`
+		Results  []testResult `json:"results"`
+	}{len(results), counts[testPassed], counts[testFailed], counts[testSkipped], totalDuration(results).Seconds(), jsonResults})
+	if error != nil {
+		return nil, error
+	}
+
+	return output.Bytes(), nil
+}
+
+// Details of a failed template in a tap report, as a yaml block
+type tapDiagnostic struct {
+	Message    string `yaml:"message"`
+	DurationMs int64  `yaml:"duration_ms"`
+	Details    string `yaml:"details,omitempty"`
+	Output     string `yaml:"output,omitempty"`
+}
+
+// Builds a tap version 13 report with a test point for each template
+func tapReport(results []testResult) ([]byte, error) {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "TAP version 13\n1..%d\n", len(results))
+
+	for index, result := range results {
+		switch result.Status {
+		case testPassed:
+			fmt.Fprintf(builder, "ok %d - %s\n", index+1, result.Name)
+		case testSkipped:
+			fmt.Fprintf(builder, "ok %d - %s # SKIP %s\n", index+1, result.Name, result.Message)
+		default:
+			fmt.Fprintf(builder, "not ok %d - %s\n", index+1, result.Name)
+
+			diagnostic, error := yaml.Marshal(tapDiagnostic{result.Message, result.Duration.Milliseconds(), result.Details, result.Output})
+			if error != nil {
+				return nil, error
+			}
+			builder.WriteString("  ---\n")
+			for _, line := range strings.Split(strings.TrimSuffix(string(diagnostic), "\n"), "\n") {
+				builder.WriteString("  " + line + "\n")
+			}
+			builder.WriteString("  ...\n")
+		}
+	}
+
+	return []byte(builder.String()), nil
+}
+
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
@@ -0,0 +1,186 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+var reportResults = []testResult{
+	{
+		Name:      "build.yml",
+		InputFile: "build.yml",
+		Status:    testPassed,
+		Duration:  12 * time.Millisecond,
+	},
+	{
+		Name:        "deploy.yml [event=tag]",
+		InputFile:   "deploy.yml",
+		Combination: "event=tag",
+		Status:      testFailed,
+		Message:     "Template 'deploy.yml [event=tag]' is valid, but did not match expected output",
+		Details:     "Differences:\n  steps[0].image: changed from \"alpine\" to \"<golang>\"",
+		Output:      "steps:\n- image: <golang>",
+		Duration:    1500 * time.Millisecond,
+	},
+	{
+		Name:      "notify.yml",
+		InputFile: "notify.yml",
+		Status:    testSkipped,
+		Message:   "no expected output to update",
+	},
+}
+
+func TestJunitReport(test *testing.T) {
+	actual, err := junitReport(reportResults)
+
+	assert.Nil(test, err)
+	assert.Equal(test, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="vela-template-tester" tests="3" failures="1" skipped="1" time="1.512">
+  <testsuite name="vela-template-tester" tests="3" failures="1" skipped="1" time="1.512">
+    <testcase name="build.yml" classname="build.yml" time="0.012"></testcase>
+    <testcase name="deploy.yml [event=tag]" classname="deploy.yml" time="1.500">
+      <failure message="Template &#39;deploy.yml [event=tag]&#39; is valid, but did not match expected output">Differences:&#xA;  steps[0].image: changed from &#34;alpine&#34; to &#34;&lt;golang&gt;&#34;</failure>
+      <system-out>steps:&#xA;- image: &lt;golang&gt;</system-out>
+    </testcase>
+    <testcase name="notify.yml" classname="notify.yml" time="0.000">
+      <skipped message="no expected output to update"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(actual))
+}
+
+func TestJsonReport(test *testing.T) {
+	actual, err := jsonReport(reportResults)
+
+	assert.Nil(test, err)
+	assert.Equal(test, `{
+  "tests": 3,
+  "passed": 1,
+  "failed": 1,
+  "skipped": 1,
+  "duration": 1.512,
+  "results": [
+    {
+      "name": "build.yml",
+      "input_file": "build.yml",
+      "status": "passed",
+      "duration": 0.012
+    },
+    {
+      "name": "deploy.yml [event=tag]",
+      "input_file": "deploy.yml",
+      "combination": "event=tag",
+      "status": "failed",
+      "message": "Template 'deploy.yml [event=tag]' is valid, but did not match expected output",
+      "details": "Differences:\n  steps[0].image: changed from \"alpine\" to \"<golang>\"",
+      "output": "steps:\n- image: <golang>",
+      "duration": 1.5
+    },
+    {
+      "name": "notify.yml",
+      "input_file": "notify.yml",
+      "status": "skipped",
+      "message": "no expected output to update",
+      "duration": 0
+    }
+  ]
+}
+`, string(actual))
+	assert.Equal(test, float64(0), reportResults[0].Seconds)
+}
+
+func TestTapReport(test *testing.T) {
+	actual, err := tapReport(reportResults)
+
+	assert.Nil(test, err)
+	assert.Equal(test, `TAP version 13
+1..3
+ok 1 - build.yml
+not ok 2 - deploy.yml [event=tag]
+  ---
+  message: Template 'deploy.yml [event=tag]' is valid, but did not match expected output
+  duration_ms: 1500
+  details: |-
+    Differences:
+      steps[0].image: changed from "alpine" to "<golang>"
+  output: |-
+    steps:
+    - image: <golang>
+  ...
+ok 3 - notify.yml # SKIP no expected output to update
+`, string(actual))
+}
+
+func TestParseReport(test *testing.T) {
+	cases := []struct {
+		report         string
+		expectedFormat string
+		expectedFile   string
+	}{
+		{"results.xml", reportJunit, "results.xml"},
+		{"build/results.json", reportJson, "build/results.json"},
+		{"results.tap", reportTap, "results.tap"},
+		{"tap:results.txt", reportTap, "results.txt"},
+		{"junit:build/results", reportJunit, "build/results"},
+		{"json:results.xml", reportJson, "results.xml"},
+	}
+
+	for _, data := range cases {
+		format, reportFile, err := parseReport(data.report)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expectedFormat, format)
+		assert.Equal(test, data.expectedFile, reportFile)
+	}
+
+	_, _, err := parseReport("html:results.html")
+	assert.EqualError(test, err, "unknown format of report 'html:results.html'. Prefix the file with 'junit:', 'json:' or 'tap:'")
+}
+
+func TestRunWithReport(test *testing.T) {
+	exitCode := captureExitCode(test)
+	reportDirectory := test.TempDir()
+	jsonFile := filepath.Join(reportDirectory, "results.json")
+	tapFile := filepath.Join(reportDirectory, "results.tap")
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("input-file", helper.AbsolutePath("test/testdata/input_template.yml"), "")
+	set.String("expected-output", helper.AbsolutePath("test/testdata/output_template.yml"), "")
+	set.String("matrix", `{"notification_branch":["develop","main"],"notification_event":["push"]}`, "")
+	set.String("report", fmt.Sprintf("%s, tap:%s", jsonFile, tapFile), "")
+
+	run(cli.NewContext(nil, set, nil))
+	assert.Equal(test, 1, exitCode[0])
+
+	content, _ := ioutil.ReadFile(jsonFile)
+	report := struct {
+		Tests   int
+		Failed  int
+		Results []testResult
+	}{}
+	assert.Nil(test, json.Unmarshal(content, &report))
+	assert.Equal(test, 2, report.Tests)
+	assert.Equal(test, 1, report.Failed)
+	assert.Equal(test, testPassed, report.Results[0].Status)
+	assert.Equal(test, testFailed, report.Results[1].Status)
+	assert.Equal(test, "notification_branch=main, notification_event=push", report.Results[1].Combination)
+	assert.Contains(test, report.Results[1].Details, "steps[0].ruleset.branch: changed from \"develop\" to \"main\"")
+	assert.Contains(test, report.Results[1].Output, "branch: main")
+
+	tapContent, _ := ioutil.ReadFile(tapFile)
+	assert.Contains(test, string(tapContent), "1..2\nok 1 - ")
+	assert.Contains(test, string(tapContent), "not ok 2 - ")
+}
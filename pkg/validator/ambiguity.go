@@ -0,0 +1,113 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// A plain scalar of a processed template that yaml 1.1 parsers, like the one vela uses, read as a value other than
+// the string it is written as, like 'no' as false or '1.10' as 1.1
+type AmbiguousScalar struct {
+	Path           string `yaml:"path" json:"path"`
+	Key            bool   `yaml:"key,omitempty" json:"key,omitempty"` // Indicates if the scalar is the key of the entry at the path
+	Line           int    `yaml:"line" json:"line"`
+	Column         int    `yaml:"column" json:"column"`
+	Value          string `yaml:"value" json:"value"`
+	Interpretation string `yaml:"interpretation" json:"interpretation"` // Value read by yaml 1.1 parsers, like 'boolean false'
+	Suggestion     string `yaml:"suggestion" json:"suggestion"`         // Quoted form of the scalar, which is read as a string
+}
+
+func (scalar AmbiguousScalar) String() string {
+	kind := "value"
+	if scalar.Key {
+		kind = "key"
+	}
+
+	return fmt.Sprintf("%s: %s '%s' at line %d, column %d is read as the %s. Quote it as %s if a string is intended",
+		scalar.Path, kind, scalar.Value, scalar.Line, scalar.Column, scalar.Interpretation, scalar.Suggestion)
+}
+
+// Finds the plain scalars of a yaml document that are read as booleans or numbers which are
+// written differently, in the order they appear in the document
+func findAmbiguousScalars(document string) []AmbiguousScalar {
+	root := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal([]byte(document), root); err != nil {
+		return nil
+	}
+
+	var scalars []AmbiguousScalar
+	var walk func(node *yamlv3.Node, path string)
+	walk = func(node *yamlv3.Node, path string) {
+		switch node.Kind {
+		case yamlv3.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yamlv3.SequenceNode:
+			for index, child := range node.Content {
+				walk(child, fmt.Sprintf("%s[%d]", path, index))
+			}
+		case yamlv3.MappingNode:
+			for index := 0; index+1 < len(node.Content); index += 2 {
+				key, value := node.Content[index], node.Content[index+1]
+				entryPath := joinPath(path, key.Value)
+				if scalar, ok := ambiguousScalar(key, entryPath); ok {
+					scalar.Key = true
+					scalars = append(scalars, scalar)
+				}
+				walk(value, entryPath)
+			}
+		case yamlv3.ScalarNode:
+			if scalar, ok := ambiguousScalar(node, path); ok {
+				scalars = append(scalars, scalar)
+			}
+		}
+	}
+	walk(root, "")
+
+	return scalars
+}
+
+// Checks if a scalar is plain and read by yaml 1.1 as a value that is written differently
+func ambiguousScalar(node *yamlv3.Node, path string) (AmbiguousScalar, bool) {
+	if node.Kind != yamlv3.ScalarNode || node.Style != 0 {
+		return AmbiguousScalar{}, false
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(node.Value), &value); err != nil {
+		return AmbiguousScalar{}, false
+	}
+
+	var written string
+	switch typedValue := value.(type) {
+	case bool:
+		// 'True' or 'FALSE' are booleans in yaml 1.2 as well
+		written = strconv.FormatBool(typedValue)
+		if strings.EqualFold(node.Value, written) {
+			return AmbiguousScalar{}, false
+		}
+	case int, int64, uint64:
+		written = fmt.Sprint(typedValue)
+	case float64:
+		written = strconv.FormatFloat(typedValue, 'f', -1, 64)
+	default:
+		return AmbiguousScalar{}, false
+	}
+	if written == node.Value {
+		return AmbiguousScalar{}, false
+	}
+
+	return AmbiguousScalar{
+		Path:           path,
+		Line:           node.Line,
+		Column:         node.Column,
+		Value:          node.Value,
+		Interpretation: fmt.Sprintf("%s %v", kindOf(value), value),
+		Suggestion:     strconv.Quote(node.Value),
+	}, true
+}
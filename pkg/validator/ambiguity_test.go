@@ -0,0 +1,71 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindAmbiguousScalars(test *testing.T) {
+	document := `on: push
+version: 1.10
+steps:
+  - name: build
+    image: golang:1.23
+    pull: true
+    ruleset:
+      branch: [ main, no, "off" ]
+      tag: 'yes'
+    environment:
+      JAVA_VERSION: 17
+      GO_VERSION: 1.20
+      RELEASE: 3.0
+      COUNT: 1_000
+      MODE: 0755
+      HEX: 0x1F
+      SCALE: 1e3
+      RATIO: 0.5
+      DEBUG: False
+      EMPTY:
+      VERBOSE: !!str on
+`
+
+	assert.Equal(test, []AmbiguousScalar{
+		{Path: "on", Key: true, Line: 1, Column: 1, Value: "on", Interpretation: "boolean true", Suggestion: `"on"`},
+		{Path: "version", Line: 2, Column: 10, Value: "1.10", Interpretation: "number 1.1", Suggestion: `"1.10"`},
+		{Path: "steps[0].ruleset.branch[1]", Line: 8, Column: 23, Value: "no", Interpretation: "boolean false", Suggestion: `"no"`},
+		{Path: "steps[0].environment.GO_VERSION", Line: 12, Column: 19, Value: "1.20", Interpretation: "number 1.2", Suggestion: `"1.20"`},
+		{Path: "steps[0].environment.RELEASE", Line: 13, Column: 16, Value: "3.0", Interpretation: "number 3", Suggestion: `"3.0"`},
+		{Path: "steps[0].environment.COUNT", Line: 14, Column: 14, Value: "1_000", Interpretation: "integer 1000", Suggestion: `"1_000"`},
+		{Path: "steps[0].environment.MODE", Line: 15, Column: 13, Value: "0755", Interpretation: "integer 493", Suggestion: `"0755"`},
+		{Path: "steps[0].environment.HEX", Line: 16, Column: 12, Value: "0x1F", Interpretation: "integer 31", Suggestion: `"0x1F"`},
+		{Path: "steps[0].environment.SCALE", Line: 17, Column: 14, Value: "1e3", Interpretation: "number 1000", Suggestion: `"1e3"`},
+	}, findAmbiguousScalars(document))
+
+	assert.Nil(test, findAmbiguousScalars("steps: [ { name: build, image: 'golang:1.23' } ]"))
+	assert.Nil(test, findAmbiguousScalars("steps: [ build"))
+}
+
+func TestAmbiguousScalarString(test *testing.T) {
+	assert.Equal(test, `steps[0].ruleset.branch: value 'no' at line 8, column 15 is read as the boolean false. Quote it as "no" if a string is intended`,
+		AmbiguousScalar{Path: "steps[0].ruleset.branch", Line: 8, Column: 15, Value: "no", Interpretation: "boolean false", Suggestion: `"no"`}.String())
+	assert.Equal(test, `on: key 'on' at line 1, column 1 is read as the boolean true. Quote it as "on" if a string is intended`,
+		AmbiguousScalar{Path: "on", Key: true, Line: 1, Column: 1, Value: "on", Interpretation: "boolean true", Suggestion: `"on"`}.String())
+}
+
+func TestValidateWithAmbiguousScalars(test *testing.T) {
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template:   "steps:\n  - name: build\n    image: golang:{{ .version }}\n    ruleset:\n      branch: {{ .branch }}\n    commands: [ go build ]",
+		Parameters: map[string]interface{}{"version": "1.20", "branch": "no"},
+	})
+
+	// The image is a string as the version is part of it
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Equal(test, []AmbiguousScalar{
+		{Path: "steps[0].ruleset.branch", Line: 5, Column: 15, Value: "no", Interpretation: "boolean false", Suggestion: `"no"`},
+	}, validationResponse.AmbiguousScalars)
+}
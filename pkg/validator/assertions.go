@@ -0,0 +1,289 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A check on the value at a path of the processed template, like 'steps[0].image'. Elements of all
+// items of a list are selected with '[*]' and all entries of a map with '*', like 'steps[*].ruleset'.
+// At least one condition needs to be specified and all of them need to hold for each selected value
+type Assertion struct {
+	Path    string      `yaml:"path" json:"path"`
+	Equals  interface{} `yaml:"equals,omitempty" json:"equals,omitempty"`
+	Exists  *bool       `yaml:"exists,omitempty" json:"exists,omitempty"`   // Whether the path should be present or absent
+	Matches string      `yaml:"matches,omitempty" json:"matches,omitempty"` // Regular expression that scalar values should match
+	Length  *int        `yaml:"length,omitempty" json:"length,omitempty"`   // Number of items of a list or map, or characters of a string
+}
+
+func (assertion Assertion) String() string {
+	path := assertion.Path
+	if path == "" {
+		path = "(document)"
+	}
+
+	conditions := []string{}
+	if assertion.Exists != nil {
+		if *assertion.Exists {
+			conditions = append(conditions, "exists")
+		} else {
+			conditions = append(conditions, "is absent")
+		}
+	}
+	if assertion.Equals != nil {
+		conditions = append(conditions, "equals "+formatDiffValue(NormalizeValue(assertion.Equals)))
+	}
+	if assertion.Matches != "" {
+		conditions = append(conditions, "matches "+strconv.Quote(assertion.Matches))
+	}
+	if assertion.Length != nil {
+		conditions = append(conditions, fmt.Sprintf("has length %d", *assertion.Length))
+	}
+
+	return path + " " + strings.Join(conditions, " and ")
+}
+
+// Outcome of an assertion, with a failure for each selected value that does not meet a condition
+type AssertionResult struct {
+	Assertion Assertion `yaml:"assertion" json:"assertion"`
+	Passed    bool      `yaml:"passed" json:"passed"`
+	Failures  []string  `yaml:"failures,omitempty" json:"failures,omitempty"`
+}
+
+// A key or list index of an assertion path
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// A value selected by an assertion path. Found is false if the path is not present in the template
+type pathMatch struct {
+	path  string
+	value interface{}
+	found bool
+}
+
+// Evaluates assertions against a processed template and returns the result of each one
+func EvaluateAssertions(output string, assertions []Assertion) ([]AssertionResult, error) {
+	var document interface{}
+	err := yaml.Unmarshal([]byte(output), &document)
+	if err != nil {
+		return nil, fmt.Errorf("output is not a valid yaml: %s", err.Error())
+	}
+
+	results := make([]AssertionResult, len(assertions))
+	for index, assertion := range assertions {
+		failures, err := assertion.evaluate(document)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion '%s': %s", assertion.Path, err.Error())
+		}
+
+		results[index] = AssertionResult{assertion, len(failures) == 0, failures}
+	}
+
+	return results, nil
+}
+
+func (assertion Assertion) evaluate(document interface{}) ([]string, error) {
+	if assertion.Equals == nil && assertion.Exists == nil && assertion.Matches == "" && assertion.Length == nil {
+		return nil, fmt.Errorf("no condition specified")
+	}
+
+	segments, err := parsePath(assertion.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pattern *regexp.Regexp
+	if assertion.Matches != "" {
+		pattern, err = regexp.Compile(assertion.Matches)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	failures := []string{}
+	for _, match := range resolvePath(document, segments) {
+		path := match.path
+		if path == "" {
+			path = "(document)"
+		}
+
+		if assertion.Exists != nil && !*assertion.Exists {
+			if match.found {
+				failures = append(failures, fmt.Sprintf("%s: expected to be absent, found %s", path, formatDiffValue(NormalizeValue(match.value))))
+			}
+			continue
+		}
+
+		if !match.found {
+			failures = append(failures, fmt.Sprintf("%s: not found", path))
+			continue
+		}
+
+		if assertion.Equals != nil {
+			expected := formatDiffValue(NormalizeValue(assertion.Equals))
+			actual := formatDiffValue(NormalizeValue(match.value))
+			if expected != actual {
+				failures = append(failures, fmt.Sprintf("%s: expected %s, found %s", path, expected, actual))
+			}
+		}
+
+		if pattern != nil {
+			switch match.value.(type) {
+			case []interface{}, map[interface{}]interface{}, nil:
+				failures = append(failures, fmt.Sprintf("%s: expected a scalar to match, found %s", path, formatDiffValue(NormalizeValue(match.value))))
+			default:
+				if !pattern.MatchString(fmt.Sprint(match.value)) {
+					failures = append(failures, fmt.Sprintf("%s: %s does not match %s", path, formatDiffValue(match.value), strconv.Quote(assertion.Matches)))
+				}
+			}
+		}
+
+		if assertion.Length != nil {
+			length, ok := valueLength(match.value)
+			if !ok {
+				failures = append(failures, fmt.Sprintf("%s: expected a list, map or string, found %s", path, formatDiffValue(NormalizeValue(match.value))))
+			} else if length != *assertion.Length {
+				failures = append(failures, fmt.Sprintf("%s: expected length %d, found %d", path, *assertion.Length, length))
+			}
+		}
+	}
+
+	return failures, nil
+}
+
+// Parses a path like 'steps[0].ruleset.branch' into its segments. An empty path selects the whole document
+func parsePath(path string) ([]pathSegment, error) {
+	segments := []pathSegment{}
+	for remaining := path; remaining != ""; {
+		if strings.HasPrefix(remaining, "[") {
+			end := strings.Index(remaining, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+
+			index := remaining[1:end]
+			if index == "*" {
+				segments = append(segments, pathSegment{isIndex: true, wildcard: true})
+			} else {
+				parsedIndex, err := strconv.Atoi(index)
+				if err != nil || parsedIndex < 0 {
+					return nil, fmt.Errorf("invalid list index '%s'", index)
+				}
+				segments = append(segments, pathSegment{isIndex: true, index: parsedIndex})
+			}
+
+			remaining = strings.TrimPrefix(remaining[end+1:], ".")
+			continue
+		}
+
+		end := strings.IndexAny(remaining, ".[")
+		if end < 0 {
+			end = len(remaining)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("empty key")
+		}
+
+		key := remaining[:end]
+		segments = append(segments, pathSegment{key: key, wildcard: key == "*"})
+		remaining = remaining[end:]
+		if strings.HasPrefix(remaining, ".") {
+			remaining = remaining[1:]
+			if remaining == "" {
+				return nil, fmt.Errorf("empty key")
+			}
+		}
+	}
+
+	return segments, nil
+}
+
+// Selects the values at a path of a document. Wildcards select all items in a list or map,
+// so that a condition applies to every one of them
+func resolvePath(document interface{}, segments []pathSegment) []pathMatch {
+	matches := []pathMatch{{"", document, true}}
+	for _, segment := range segments {
+		selected := []pathMatch{}
+		for _, match := range matches {
+			if !match.found {
+				selected = append(selected, pathMatch{path: segment.join(match.path)})
+				continue
+			}
+			selected = append(selected, segment.resolve(match)...)
+		}
+		matches = selected
+	}
+
+	return matches
+}
+
+func (segment pathSegment) resolve(match pathMatch) []pathMatch {
+	if segment.isIndex {
+		items, ok := match.value.([]interface{})
+		if !ok {
+			return []pathMatch{{path: segment.join(match.path)}}
+		}
+
+		if segment.wildcard {
+			selected := make([]pathMatch, len(items))
+			for index, item := range items {
+				selected[index] = pathMatch{fmt.Sprintf("%s[%d]", match.path, index), item, true}
+			}
+			return selected
+		}
+
+		if segment.index >= len(items) {
+			return []pathMatch{{path: segment.join(match.path)}}
+		}
+		return []pathMatch{{segment.join(match.path), items[segment.index], true}}
+	}
+
+	entries, ok := toStringMap(match.value)
+	if !ok {
+		return []pathMatch{{path: segment.join(match.path)}}
+	}
+
+	if segment.wildcard {
+		selected := make([]pathMatch, 0, len(entries))
+		for _, key := range sortedStringKeys(entries) {
+			selected = append(selected, pathMatch{joinPath(match.path, key), entries[key], true})
+		}
+		return selected
+	}
+
+	entry, found := entries[segment.key]
+	return []pathMatch{{segment.join(match.path), entry, found}}
+}
+
+// Appends the segment to a path
+func (segment pathSegment) join(path string) string {
+	switch {
+	case segment.isIndex && segment.wildcard:
+		return path + "[*]"
+	case segment.isIndex:
+		return fmt.Sprintf("%s[%d]", path, segment.index)
+	}
+
+	return joinPath(path, segment.key)
+}
+
+func valueLength(value interface{}) (int, bool) {
+	switch typedValue := value.(type) {
+	case []interface{}:
+		return len(typedValue), true
+	case map[interface{}]interface{}:
+		return len(typedValue), true
+	case string:
+		return len([]rune(typedValue)), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,183 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const assertedOutput = `version: "1"
+steps:
+  - name: build
+    image: golang:1.23
+    commands: [ go build, go test ]
+    ruleset:
+      branch: main
+  - name: publish
+    image: plugins/docker
+    pull: not_present
+`
+
+func TestEvaluateAssertions(test *testing.T) {
+	present, absent := true, false
+	two, three := 2, 3
+
+	cases := []struct {
+		assertion        Assertion
+		expectedFailures []string
+	}{
+		{
+			Assertion{Path: "steps[0].image", Equals: "golang:1.23"},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[1].image", Equals: "alpine"},
+			[]string{`steps[1].image: expected "alpine", found "plugins/docker"`},
+		},
+		{
+			Assertion{Path: "version", Equals: "1"},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[0].commands", Equals: []interface{}{"go build", "go test"}},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[0].ruleset", Exists: &present},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[*].ruleset", Exists: &present},
+			[]string{"steps[1].ruleset: not found"},
+		},
+		{
+			Assertion{Path: "services", Exists: &absent},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[1].pull", Exists: &absent},
+			[]string{`steps[1].pull: expected to be absent, found "not_present"`},
+		},
+		{
+			Assertion{Path: "steps[*].image", Matches: "^golang:"},
+			[]string{`steps[1].image: "plugins/docker" does not match "^golang:"`},
+		},
+		{
+			Assertion{Path: "steps", Matches: "build"},
+			[]string{`steps: expected a scalar to match, found [{"commands":["go build","go test"],"image":"golang:1.23","name":"build","ruleset":{"branch":"main"}},{"image":"plugins/docker","name":"publish","pull":"not_present"}]`},
+		},
+		{
+			Assertion{Path: "steps", Length: &two},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[*]", Length: &three},
+			[]string{"steps[0]: expected length 3, found 4"},
+		},
+		{
+			Assertion{Path: "steps[*].name", Length: &three},
+			[]string{"steps[0].name: expected length 3, found 5", "steps[1].name: expected length 3, found 7"},
+		},
+		{
+			Assertion{Path: "version", Length: &two},
+			[]string{"version: expected length 2, found 1"},
+		},
+		{
+			Assertion{Path: "steps[0].ruleset.*", Equals: "main"},
+			[]string{},
+		},
+		{
+			Assertion{Path: "steps[2].image", Equals: "alpine"},
+			[]string{"steps[2].image: not found"},
+		},
+		{
+			Assertion{Path: "services[*].name", Exists: &present},
+			[]string{"services[*].name: not found"},
+		},
+		{
+			Assertion{Path: "", Length: &two},
+			[]string{},
+		},
+	}
+
+	for _, data := range cases {
+		results, err := EvaluateAssertions(assertedOutput, []Assertion{data.assertion})
+
+		assert.Nil(test, err)
+		assert.Equal(test, 1, len(results))
+		assert.Equal(test, data.assertion, results[0].Assertion)
+		assert.Equal(test, len(data.expectedFailures) == 0, results[0].Passed, data.assertion.String())
+		assert.Equal(test, data.expectedFailures, results[0].Failures, data.assertion.String())
+	}
+}
+
+func TestEvaluateAssertionsInvalid(test *testing.T) {
+	present := true
+
+	cases := []struct {
+		output        string
+		assertion     Assertion
+		expectedError string
+	}{
+		{
+			assertedOutput,
+			Assertion{Path: "steps[0].image"},
+			"invalid assertion 'steps[0].image': no condition specified",
+		},
+		{
+			assertedOutput,
+			Assertion{Path: "steps[first].image", Exists: &present},
+			"invalid assertion 'steps[first].image': invalid list index 'first'",
+		},
+		{
+			assertedOutput,
+			Assertion{Path: "steps[0", Exists: &present},
+			"invalid assertion 'steps[0': missing ']'",
+		},
+		{
+			assertedOutput,
+			Assertion{Path: "steps..image", Exists: &present},
+			"invalid assertion 'steps..image': empty key",
+		},
+		{
+			assertedOutput,
+			Assertion{Path: "steps[0].image", Matches: "golang:("},
+			"invalid assertion 'steps[0].image': error parsing regexp: missing closing ): `golang:(`",
+		},
+		{
+			"steps: [",
+			Assertion{Path: "steps", Exists: &present},
+			"output is not a valid yaml: yaml: line 1: did not find expected node content",
+		},
+	}
+
+	for _, data := range cases {
+		results, err := EvaluateAssertions(data.output, []Assertion{data.assertion})
+
+		assert.Nil(test, results)
+		assert.EqualError(test, err, data.expectedError)
+	}
+}
+
+func TestAssertionString(test *testing.T) {
+	present, absent := true, false
+	two := 2
+
+	cases := []struct {
+		assertion Assertion
+		expected  string
+	}{
+		{Assertion{Path: "steps[0].image", Equals: "alpine"}, `steps[0].image equals "alpine"`},
+		{Assertion{Path: "steps[*].ruleset", Exists: &present}, "steps[*].ruleset exists"},
+		{Assertion{Path: "services", Exists: &absent}, "services is absent"},
+		{Assertion{Path: "steps", Length: &two, Matches: "x"}, `steps matches "x" and has length 2`},
+		{Assertion{Length: &two}, "(document) has length 2"},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.assertion.String())
+	}
+}
@@ -0,0 +1,132 @@
+package validator
+
+import (
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// Metadata of the simulated vela build that processes a template. Fields that are not
+// specified are filled with defaults
+type BuildContext struct {
+	Branch        string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Event         string `yaml:"event,omitempty" json:"event,omitempty"`
+	Commit        string `yaml:"commit,omitempty" json:"commit,omitempty"`
+	Number        int    `yaml:"number,omitempty" json:"number,omitempty"`
+	Ref           string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	Tag           string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Author        string `yaml:"author,omitempty" json:"author,omitempty"`
+	Message       string `yaml:"message,omitempty" json:"message,omitempty"`
+	Org           string `yaml:"org,omitempty" json:"org,omitempty"`
+	Repo          string `yaml:"repo,omitempty" json:"repo,omitempty"`                     // Name of the repository, without the org
+	Address       string `yaml:"address,omitempty" json:"address,omitempty"`               // Address of the vela server
+	DefaultBranch string `yaml:"default_branch,omitempty" json:"default_branch,omitempty"` // Default branch of the repository
+}
+
+// Returns a copy of the build context with defaults for the fields that are not specified
+func (buildContext BuildContext) withDefaults() BuildContext {
+	buildContext.Branch = valueOrDefault(buildContext.Branch, "main")
+	buildContext.Event = valueOrDefault(buildContext.Event, "push")
+	buildContext.Commit = valueOrDefault(buildContext.Commit, "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d")
+	buildContext.Author = valueOrDefault(buildContext.Author, "octocat")
+	buildContext.Message = valueOrDefault(buildContext.Message, "Simulated build")
+	buildContext.Org = valueOrDefault(buildContext.Org, "octocat")
+	buildContext.Repo = valueOrDefault(buildContext.Repo, "hello-world")
+	buildContext.Address = valueOrDefault(buildContext.Address, "https://vela.example.com")
+	buildContext.DefaultBranch = valueOrDefault(buildContext.DefaultBranch, "main")
+
+	if buildContext.Number == 0 {
+		buildContext.Number = 1
+	}
+
+	if buildContext.Ref == "" {
+		if buildContext.Tag != "" {
+			buildContext.Ref = "refs/tags/" + buildContext.Tag
+		} else {
+			buildContext.Ref = "refs/heads/" + buildContext.Branch
+		}
+	}
+
+	return buildContext
+}
+
+// Returns the vela platform variables of the build, keyed by the lower case name without the 'VELA_' prefix,
+// like 'build_branch' for VELA_BUILD_BRANCH
+func (buildContext BuildContext) platformVariables() map[string]string {
+	buildContext = buildContext.withDefaults()
+	fullName := buildContext.Org + "/" + buildContext.Repo
+	number := strconv.Itoa(buildContext.Number)
+
+	return map[string]string{
+		"build_author":   buildContext.Author,
+		"build_branch":   buildContext.Branch,
+		"build_commit":   buildContext.Commit,
+		"build_event":    buildContext.Event,
+		"build_link":     buildContext.Address + "/" + fullName + "/" + number,
+		"build_message":  buildContext.Message,
+		"build_number":   number,
+		"build_ref":      buildContext.Ref,
+		"build_tag":      buildContext.Tag,
+		"repo_branch":    buildContext.DefaultBranch,
+		"repo_clone":     "https://github.com/" + fullName + ".git",
+		"repo_full_name": fullName,
+		"repo_link":      "https://github.com/" + fullName,
+		"repo_name":      buildContext.Repo,
+		"repo_org":       buildContext.Org,
+		"addr":           buildContext.Address,
+		"workspace":      "/vela/src/github.com/" + fullName,
+	}
+}
+
+// Returns a 'vela' function that resolves platform variables from the build context. Like in vela,
+// the name is case insensitive and the 'VELA_' prefix is optional. Variables that the build context
+// does not define keep the placeholder of the simulated 'vela' function
+func (buildContext BuildContext) velaFunction() func(string) (string, error) {
+	variables := buildContext.platformVariables()
+
+	return func(variableName string) (string, error) {
+		placeholder, err := vela(variableName)
+		if err != nil {
+			return "", err
+		}
+
+		if value, ok := variables[strings.TrimPrefix(strings.ToLower(variableName), "vela_")]; ok {
+			return value, nil
+		}
+
+		return placeholder, nil
+	}
+}
+
+// Adds the 'build', 'repo' and 'system' dicts of the build context to the 'ctx' of a starlark template
+func (buildContext BuildContext) addToStarlarkContext(templateContext *starlark.Dict) {
+	build := starlark.NewDict(9)
+	repo := starlark.NewDict(6)
+	system := starlark.NewDict(2)
+
+	variables := buildContext.platformVariables()
+	for _, name := range sortedStringKeys(variables) {
+		value := starlark.String(variables[name])
+		switch {
+		case strings.HasPrefix(name, "build_"):
+			build.SetKey(starlark.String(strings.TrimPrefix(name, "build_")), value)
+		case strings.HasPrefix(name, "repo_"):
+			repo.SetKey(starlark.String(strings.TrimPrefix(name, "repo_")), value)
+		default:
+			system.SetKey(starlark.String(name), value)
+		}
+	}
+
+	templateContext.SetKey(starlark.String("build"), build)
+	templateContext.SetKey(starlark.String("repo"), repo)
+	templateContext.SetKey(starlark.String("system"), system)
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
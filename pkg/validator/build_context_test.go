@@ -0,0 +1,137 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildContextWithDefaults(test *testing.T) {
+	cases := []struct {
+		buildContext BuildContext
+		expected     BuildContext
+	}{
+		{
+			BuildContext{},
+			BuildContext{
+				Branch:        "main",
+				Event:         "push",
+				Commit:        "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				Number:        1,
+				Ref:           "refs/heads/main",
+				Author:        "octocat",
+				Message:       "Simulated build",
+				Org:           "octocat",
+				Repo:          "hello-world",
+				Address:       "https://vela.example.com",
+				DefaultBranch: "main",
+			},
+		},
+		{
+			BuildContext{Event: "tag", Tag: "v1.2.0", Number: 42, Org: "devatherock", Repo: "vela-template-tester"},
+			BuildContext{
+				Branch:        "main",
+				Event:         "tag",
+				Commit:        "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				Number:        42,
+				Ref:           "refs/tags/v1.2.0",
+				Tag:           "v1.2.0",
+				Author:        "octocat",
+				Message:       "Simulated build",
+				Org:           "devatherock",
+				Repo:          "vela-template-tester",
+				Address:       "https://vela.example.com",
+				DefaultBranch: "main",
+			},
+		},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.buildContext.withDefaults())
+	}
+}
+
+func TestVelaFunction(test *testing.T) {
+	vela := BuildContext{Branch: "develop", Org: "devatherock"}.velaFunction()
+
+	cases := []struct {
+		variableName string
+		expected     string
+	}{
+		{"VELA_BUILD_BRANCH", "develop"},
+		{"build_branch", "develop"},
+		{"BUILD_EVENT", "push"},
+		{"VELA_REPO_FULL_NAME", "devatherock/hello-world"},
+		{"VELA_BUILD_NUMBER", "1"},
+		{"VELA_ADDR", "https://vela.example.com"},
+		{"VELA_REPO_BRANCH", "main"},
+		{"VELA_REPO_CLONE", "https://github.com/devatherock/hello-world.git"},
+		{"VELA_BUILD_LINK", "https://vela.example.com/devatherock/hello-world/1"},
+		{"VELA_WORKSPACE", "/vela/src/github.com/devatherock/hello-world"},
+		{"vela_unknown", "${VELA_UNKNOWN}"},
+	}
+
+	for _, data := range cases {
+		actual, err := vela(data.variableName)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual)
+	}
+
+	_, err := vela("")
+	assert.Equal(test, "environment variable name cannot be empty in 'vela' function", err.Error())
+}
+
+func TestValidateWithBuildContext(test *testing.T) {
+	starlarkTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_build_context_template.py"))
+
+	cases := []struct {
+		template     string
+		templateType string
+		buildContext *BuildContext
+		expected     string
+	}{
+		{
+			"steps:\n  - name: {{ vela \"VELA_BUILD_BRANCH\" }}\n    image: alpine:{{ vela \"build_number\" }}\n    commands: [ ls ]",
+			"",
+			&BuildContext{Branch: "release", Number: 7},
+			"steps:\n  - name: release\n    image: alpine:7\n    commands: [ ls ]",
+		},
+		{
+			"steps:\n  - name: {{ vela \"VELA_BUILD_BRANCH\" }}\n    image: alpine\n    commands: [ ls ]",
+			"",
+			nil,
+			"steps:\n  - name: ${VELA_BUILD_BRANCH}\n    image: alpine\n    commands: [ ls ]",
+		},
+		{
+			string(starlarkTemplate),
+			"starlark",
+			nil,
+			"version: \"1\"\nsteps:\n- name: build\n  image: golang:1.23\n  commands:\n  - go build\n" +
+				"- name: publish\n  image: plugins/docker\n  parameters:\n    repo: octocat/hello-world\n    tags:\n    - 7fd1a60",
+		},
+		{
+			string(starlarkTemplate),
+			"starlark",
+			&BuildContext{Event: "pull_request"},
+			"version: \"1\"\nsteps:\n- name: build\n  image: golang:1.23\n  commands:\n  - go build",
+		},
+	}
+
+	for _, data := range cases {
+		actual := Validate(context.Background(), ValidationRequest{
+			Template:     data.template,
+			Type:         data.templateType,
+			BuildContext: data.buildContext,
+		})
+
+		assert.Equal(test, "template is a valid yaml", actual.Message)
+		assert.Equal(test, data.expected, actual.Template)
+	}
+}
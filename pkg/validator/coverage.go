@@ -0,0 +1,231 @@
+package validator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template/parse"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Statements whose branches are covered
+const (
+	StatementIf    = "if"
+	StatementRange = "range"
+	StatementWith  = "with"
+)
+
+// Branches of a statement. The 'then' branch of a 'range' is taken when the collection has items
+// and the 'else' branch when it is empty, whether or not the template has an 'else'
+const (
+	BranchThen = "then"
+	BranchElse = "else"
+)
+
+// Function injected into each branch of a go template to record that the branch was taken
+const coverBranchFunction = "coverBranch"
+
+// Function injected into each branch of a starlark template. Not a valid identifier, so that templates cannot shadow it
+const starlarkCoverBranchFunction = "$coverBranch"
+
+// A branch of an 'if', 'range' or 'with' statement in a template and the number of times it was taken
+type BranchCoverage struct {
+	Statement string `yaml:"statement" json:"statement"`
+	Branch    string `yaml:"branch" json:"branch"`
+	Line      int    `yaml:"line" json:"line"` // Location of the statement, 'elif' or 'else if' for chained statements
+	Column    int    `yaml:"column" json:"column"`
+	Hits      int    `yaml:"hits" json:"hits"`
+	Template  string `yaml:"template,omitempty" json:"template,omitempty"` // Name of the template of a pipeline the branch is in
+}
+
+// Branches of a template and the number of times each was taken while processing it
+type branchRecorder struct {
+	branches []BranchCoverage
+	hits     []int64 // Updated atomically, as a go template keeps running in the background after a timeout
+}
+
+// Adds a branch and returns its index
+func (recorder *branchRecorder) add(statement string, branch string, line int, column int) int {
+	recorder.branches = append(recorder.branches, BranchCoverage{Statement: statement, Branch: branch, Line: line, Column: column})
+	recorder.hits = append(recorder.hits, 0)
+
+	return len(recorder.branches) - 1
+}
+
+func (recorder *branchRecorder) hit(index int) {
+	if index >= 0 && index < len(recorder.hits) {
+		atomic.AddInt64(&recorder.hits[index], 1)
+	}
+}
+
+// Returns the branches with their hits, in the order of their location in the template
+func (recorder *branchRecorder) coverage() []BranchCoverage {
+	if len(recorder.branches) == 0 {
+		return nil
+	}
+
+	branches := make([]BranchCoverage, len(recorder.branches))
+	for index, branch := range recorder.branches {
+		branch.Hits = int(atomic.LoadInt64(&recorder.hits[index]))
+		branches[index] = branch
+	}
+	sort.SliceStable(branches, func(first int, second int) bool {
+		if branches[first].Line != branches[second].Line {
+			return branches[first].Line < branches[second].Line
+		}
+		return branches[first].Column < branches[second].Column
+	})
+
+	return branches
+}
+
+// Returns the function that records the branches taken by a go template
+func (recorder *branchRecorder) goFunction() func(index int) string {
+	return func(index int) string {
+		recorder.hit(index)
+		return ""
+	}
+}
+
+// Inserts an action that records the branch at the start of each branch of the 'if', 'range' and 'with' actions
+// in a go template. Actions without an 'else' get an empty one, so that skipping them is also recorded
+func (recorder *branchRecorder) instrumentGoTemplate(node parse.Node, source string) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for _, child := range typedNode.Nodes {
+			recorder.instrumentGoTemplate(child, source)
+		}
+	case *parse.IfNode:
+		recorder.instrumentGoBranches(StatementIf, &typedNode.BranchNode, source)
+	case *parse.RangeNode:
+		recorder.instrumentGoBranches(StatementRange, &typedNode.BranchNode, source)
+	case *parse.WithNode:
+		recorder.instrumentGoBranches(StatementWith, &typedNode.BranchNode, source)
+	}
+}
+
+func (recorder *branchRecorder) instrumentGoBranches(statement string, node *parse.BranchNode, source string) {
+	line, column := lineAndColumn(source, int(node.Position()))
+	elseChain := isElseChain(node, source)
+
+	recorder.instrumentGoTemplate(node.List, source)
+	recorder.instrumentGoTemplate(node.ElseList, source)
+
+	thenIndex := recorder.add(statement, BranchThen, line, column)
+	node.List.Nodes = append([]parse.Node{indexedAction(coverBranchFunction, thenIndex, node.Position())}, node.List.Nodes...)
+
+	// The 'else' of an 'else if' or 'else with' is covered by the branches of the chained statement
+	if elseChain {
+		return
+	}
+
+	elseIndex := recorder.add(statement, BranchElse, line, column)
+	if node.ElseList == nil {
+		node.ElseList = &parse.ListNode{NodeType: parse.NodeList, Pos: node.Position()}
+	}
+	node.ElseList.Nodes = append([]parse.Node{indexedAction(coverBranchFunction, elseIndex, node.ElseList.Position())}, node.ElseList.Nodes...)
+}
+
+// Indicates if the 'else' of a statement is an 'else if' or 'else with', which the parser
+// turns into an 'else' that contains only the chained statement, starting at its keyword
+func isElseChain(node *parse.BranchNode, source string) bool {
+	if node.ElseList == nil || len(node.ElseList.Nodes) != 1 {
+		return false
+	}
+
+	switch node.ElseList.Nodes[0].(type) {
+	case *parse.IfNode, *parse.WithNode:
+	default:
+		return false
+	}
+
+	position := int(node.ElseList.Position())
+	if position >= len(source) {
+		return false
+	}
+
+	return strings.HasPrefix(source[position:], "if") || strings.HasPrefix(source[position:], "with")
+}
+
+// Builds an action like '{{coverBranch index}}', which calls a function that records something and outputs nothing
+func indexedAction(function string, index int, position parse.Pos) *parse.ActionNode {
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      position,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      position,
+			Cmds: []*parse.CommandNode{{
+				NodeType: parse.NodeCommand,
+				Pos:      position,
+				Args: []parse.Node{
+					parse.NewIdentifier(function).SetPos(position),
+					&parse.NumberNode{NodeType: parse.NodeNumber, Pos: position, IsInt: true, Int64: int64(index), Text: strconv.Itoa(index)},
+				},
+			}},
+		},
+	}
+}
+
+// Returns the builtin that records the branches taken by a starlark template
+func (recorder *branchRecorder) starlarkFunction() *starlark.Builtin {
+	return starlark.NewBuiltin(starlarkCoverBranchFunction, func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var index int
+		if err := starlark.UnpackPositionalArgs(builtin.Name(), args, kwargs, 1, &index); err != nil {
+			return nil, err
+		}
+		recorder.hit(index)
+
+		return starlark.None, nil
+	})
+}
+
+// Inserts a call that records the branch at the start of both branches of each 'if' statement in a starlark template.
+// Statements without an 'else' get one with just the call
+func (recorder *branchRecorder) instrumentStarlark(file *syntax.File) {
+	for _, statement := range file.Stmts {
+		syntax.Walk(statement, func(node syntax.Node) bool {
+			ifStatement, ok := node.(*syntax.IfStmt)
+			if !ok {
+				return true
+			}
+
+			thenIndex := recorder.add(StatementIf, BranchThen, int(ifStatement.If.Line), int(ifStatement.If.Col))
+			ifStatement.True = append([]syntax.Stmt{starlarkCoverCall(thenIndex, syntax.Start(ifStatement.True[0]))}, ifStatement.True...)
+
+			// The 'else' of an 'elif' is covered by the branches of the chained statement
+			if len(ifStatement.False) == 1 {
+				if elif, ok := ifStatement.False[0].(*syntax.IfStmt); ok && elif.If == ifStatement.ElsePos {
+					return true
+				}
+			}
+
+			elseIndex := recorder.add(StatementIf, BranchElse, int(ifStatement.If.Line), int(ifStatement.If.Col))
+			elsePosition := ifStatement.ElsePos
+			if !elsePosition.IsValid() {
+				elsePosition = ifStatement.If
+			}
+			ifStatement.False = append([]syntax.Stmt{starlarkCoverCall(elseIndex, elsePosition)}, ifStatement.False...)
+
+			return true
+		})
+	}
+}
+
+// Builds the statement '$coverBranch(index)'
+func starlarkCoverCall(index int, position syntax.Position) syntax.Stmt {
+	return &syntax.ExprStmt{
+		X: &syntax.CallExpr{
+			Fn:     &syntax.Ident{NamePos: position, Name: starlarkCoverBranchFunction},
+			Lparen: position,
+			Args:   []syntax.Expr{&syntax.Literal{Token: syntax.INT, TokenPos: position, Raw: strconv.Itoa(index), Value: int64(index)}},
+			Rparen: position,
+		},
+	}
+}
@@ -0,0 +1,144 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGoTemplateCoverage(test *testing.T) {
+	cases := []struct {
+		template   string
+		parameters map[string]interface{}
+		expected   []BranchCoverage
+	}{
+		{
+			"image: {{if .go}}golang{{else if .java}}openjdk{{else}}alpine{{end}}\n" +
+				"{{- range .commands}}\ncommands: {{.}}{{end}}\n" +
+				"{{with .ruleset}}ruleset: {{.}}{{end}}",
+			map[string]interface{}{"java": true, "commands": []string{"ls"}},
+			[]BranchCoverage{
+				{Statement: StatementIf, Branch: BranchThen, Line: 1, Column: 13, Hits: 0},
+				{Statement: StatementIf, Branch: BranchThen, Line: 1, Column: 34, Hits: 1},
+				{Statement: StatementIf, Branch: BranchElse, Line: 1, Column: 34, Hits: 0},
+				{Statement: StatementRange, Branch: BranchThen, Line: 2, Column: 11, Hits: 1},
+				{Statement: StatementRange, Branch: BranchElse, Line: 2, Column: 11, Hits: 0},
+				{Statement: StatementWith, Branch: BranchThen, Line: 4, Column: 8, Hits: 0},
+				{Statement: StatementWith, Branch: BranchElse, Line: 4, Column: 8, Hits: 1},
+			},
+		},
+		{
+			"{{define \"image\"}}{{if .}}{{.}}{{else}}alpine{{end}}{{end}}" +
+				"steps:{{range .images}}\n- image: {{template \"image\" .}}{{else}} []{{end}}",
+			map[string]interface{}{"images": []string{"golang", ""}},
+			[]BranchCoverage{
+				{Statement: StatementIf, Branch: BranchThen, Line: 1, Column: 24, Hits: 1},
+				{Statement: StatementIf, Branch: BranchElse, Line: 1, Column: 24, Hits: 1},
+				{Statement: StatementRange, Branch: BranchThen, Line: 1, Column: 74, Hits: 2},
+				{Statement: StatementRange, Branch: BranchElse, Line: 1, Column: 74, Hits: 0},
+			},
+		},
+		{
+			"image: alpine",
+			nil,
+			nil,
+		},
+	}
+
+	for _, data := range cases {
+		validationResponse := Validate(context.Background(), ValidationRequest{
+			Template:   data.template,
+			Parameters: data.parameters,
+			Coverage:   true,
+		})
+
+		assert.Equal(test, "", validationResponse.Error, data.template)
+		assert.Equal(test, data.expected, validationResponse.Coverage, data.template)
+	}
+}
+
+func TestValidateStarlarkTemplateCoverage(test *testing.T) {
+	template := "def main(ctx):\n" +
+		"  image = 'alpine'\n" +
+		"  if ctx['vars'].get('go'):\n" +
+		"    image = 'golang'\n" +
+		"  elif ctx['vars'].get('java'):\n" +
+		"    image = 'openjdk'\n" +
+		"  else:\n" +
+		"    pass\n" +
+		"  steps = []\n" +
+		"  for command in ctx['vars']['commands']:\n" +
+		"    if command: steps.append({'name': command, 'image': image, 'commands': [command]})\n" +
+		"  return {'version': '1', 'steps': steps}"
+
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template:   template,
+		Type:       "starlark",
+		Parameters: map[string]interface{}{"java": true, "commands": []interface{}{"ls", "pwd"}},
+		Coverage:   true,
+	})
+
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Contains(test, validationResponse.Template, "image: openjdk")
+	assert.Equal(test, []BranchCoverage{
+		{Statement: StatementIf, Branch: BranchThen, Line: 3, Column: 3, Hits: 0},
+		{Statement: StatementIf, Branch: BranchThen, Line: 5, Column: 3, Hits: 1},
+		{Statement: StatementIf, Branch: BranchElse, Line: 5, Column: 3, Hits: 0},
+		{Statement: StatementIf, Branch: BranchThen, Line: 11, Column: 5, Hits: 2},
+		{Statement: StatementIf, Branch: BranchElse, Line: 11, Column: 5, Hits: 0},
+	}, validationResponse.Coverage)
+}
+
+func TestValidateCoverageOfFailedTemplates(test *testing.T) {
+	cases := []struct {
+		request          ValidationRequest
+		expectedError    string
+		expectedCoverage []BranchCoverage
+	}{
+		{
+			ValidationRequest{Template: "{{if .fail}}{{fail \"failed\"}}{{end}}", Parameters: map[string]interface{}{"fail": true}},
+			"template: test:1:14: executing \"test\" at <fail \"failed\">: error calling fail: failed",
+			[]BranchCoverage{{Statement: StatementIf, Branch: BranchThen, Line: 1, Column: 6, Hits: 1}, {Statement: StatementIf, Branch: BranchElse, Line: 1, Column: 6, Hits: 0}},
+		},
+		{
+			ValidationRequest{Template: "{{if .fail}}", Parameters: map[string]interface{}{"fail": true}},
+			"template: test:1: unexpected EOF",
+			nil,
+		},
+		{
+			ValidationRequest{Template: "def main(ctx):\n  if True:\n    fail('failed')", Type: "starlark"},
+			"fail: failed",
+			[]BranchCoverage{{Statement: StatementIf, Branch: BranchThen, Line: 2, Column: 3, Hits: 1}, {Statement: StatementIf, Branch: BranchElse, Line: 2, Column: 3, Hits: 0}},
+		},
+		{
+			ValidationRequest{Template: "def main(ctx):\n  if True\n    return {}", Type: "starlark"},
+			"template.star:3:1: got newline, want ':'",
+			nil,
+		},
+		{
+			ValidationRequest{Template: "def main(ctx):\n  return {'x': undefined}", Type: "starlark"},
+			"template.star:2:16: undefined: undefined",
+			nil,
+		},
+	}
+
+	for _, data := range cases {
+		data.request.Coverage = true
+		validationResponse := Validate(context.Background(), data.request)
+
+		assert.Equal(test, data.expectedError, validationResponse.Error)
+		assert.Equal(test, data.expectedCoverage, validationResponse.Coverage)
+		assert.Len(test, validationResponse.Diagnostics, 1)
+	}
+}
+
+func TestValidateWithoutCoverage(test *testing.T) {
+	validationResponse := Validate(context.Background(), ValidationRequest{Template: "image: {{if .go}}golang{{end}}"})
+
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Nil(test, validationResponse.Coverage)
+}
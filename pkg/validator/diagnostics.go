@@ -0,0 +1,210 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Stages of template validation at which an error can occur
+const (
+	StageVariables = "variables" // Supplied variables do not match the variable schema
+	StageParse     = "parse"
+	StageExecute   = "execute"
+	StageYaml      = "yaml"   // Processed template is not a valid yaml
+	StageSchema    = "schema" // Processed template is not a valid vela pipeline
+	StageLint      = "lint"   // Lint configuration of the request is invalid
+)
+
+// An error in a template, along with its location in the template
+type TemplateDiagnostic struct {
+	Stage   string `yaml:"stage"`
+	Line    int    `yaml:"line,omitempty"`
+	Column  int    `yaml:"column,omitempty"`
+	Action  string `yaml:"action,omitempty"`
+	Message string `yaml:"message"`
+	Excerpt string `yaml:"excerpt,omitempty"`
+	// Line of a yaml error in the processed template and the processed line, as the line in the
+	// template is that of the text or action that produced the line
+	OutputLine    int    `yaml:"output_line,omitempty"`
+	OutputExcerpt string `yaml:"output_excerpt,omitempty"`
+}
+
+// Matches errors like 'yaml: line 14: did not find expected key' and 'yaml: unmarshal errors:\n  line 1: cannot unmarshal'
+var yamlErrorRegex = regexp.MustCompile(`(?s)^yaml: (?:unmarshal errors:\s*)?line (\d+): (.*)$`)
+
+// Matches errors like 'template: test:2:10: executing "test" at <.x.y>: can't evaluate field y'
+var goTemplateErrorRegex = regexp.MustCompile(`(?s)^template: [^:]*:(\d+)(?::(\d+))?: (?:executing "[^"]*" at <(.*?)>: )?(.*)$`)
+
+// Converts an error from processing a template into a diagnostic with the template location
+func diagnose(err error, source string, templateType string) *TemplateDiagnostic {
+	if templateType == "starlark" {
+		return diagnoseStarlarkError(err, source)
+	}
+
+	return diagnoseGoTemplateError(err, source)
+}
+
+func diagnoseGoTemplateError(err error, source string) *TemplateDiagnostic {
+	var execError template.ExecError
+	stage := StageParse
+	if errors.As(err, &execError) {
+		stage = StageExecute
+	}
+
+	matches := goTemplateErrorRegex.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return nil
+	}
+
+	diagnostic := &TemplateDiagnostic{
+		Stage:   stage,
+		Action:  matches[3],
+		Message: matches[4],
+	}
+	diagnostic.Line, _ = strconv.Atoi(matches[1])
+
+	// The limits are enforced by injected functions, which are not part of the template
+	var limitError *LimitExceededError
+	if errors.As(err, &limitError) {
+		diagnostic.Message = limitError.Message
+		if diagnostic.Action == rangeLimitFunction {
+			diagnostic.Action = ""
+		}
+	}
+
+	// Go templates report a zero based column
+	if matches[2] != "" {
+		column, _ := strconv.Atoi(matches[2])
+		diagnostic.Column = column + 1
+	}
+	diagnostic.Excerpt = excerpt(source, diagnostic.Line, diagnostic.Column)
+
+	return diagnostic
+}
+
+func diagnoseStarlarkError(err error, source string) *TemplateDiagnostic {
+	var syntaxError syntax.Error
+	var resolveErrors resolve.ErrorList
+	var evalError *starlark.EvalError
+
+	diagnostic := &TemplateDiagnostic{}
+	if errors.As(err, &syntaxError) {
+		diagnostic.Stage = StageParse
+		diagnostic.Line = int(syntaxError.Pos.Line)
+		diagnostic.Column = int(syntaxError.Pos.Col)
+		diagnostic.Message = syntaxError.Msg
+	} else if errors.As(err, &resolveErrors) && len(resolveErrors) > 0 {
+		diagnostic.Stage = StageParse
+		diagnostic.Line = int(resolveErrors[0].Pos.Line)
+		diagnostic.Column = int(resolveErrors[0].Pos.Col)
+		diagnostic.Message = resolveErrors[0].Msg
+	} else if errors.As(err, &evalError) {
+		diagnostic.Stage = StageExecute
+		diagnostic.Message = evalError.Msg
+
+		// The innermost frame with a position is the location of the failure. Built-in functions have none
+		for index := 0; index < len(evalError.CallStack); index++ {
+			frame := evalError.CallStack.At(index)
+			if frame.Pos.Line > 0 {
+				diagnostic.Line = int(frame.Pos.Line)
+				diagnostic.Column = int(frame.Pos.Col)
+				diagnostic.Action = frame.Name
+				break
+			}
+		}
+	} else {
+		return nil
+	}
+
+	diagnostic.Excerpt = excerpt(source, diagnostic.Line, diagnostic.Column)
+
+	return diagnostic
+}
+
+// Converts an error parsing the processed template into a diagnostic with the line in the processed template and,
+// for go templates, the location in the template of the text or action that produced the start of the line
+func diagnoseYamlError(err error, validationRequest *ValidationRequest, output string, processedTemplate string) *TemplateDiagnostic {
+	matches := yamlErrorRegex.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return nil
+	}
+
+	diagnostic := &TemplateDiagnostic{Stage: StageYaml, Message: matches[2]}
+	diagnostic.OutputLine, _ = strconv.Atoi(matches[1])
+	lines := strings.Split(processedTemplate, "\n")
+	if diagnostic.OutputLine < 1 || diagnostic.OutputLine > len(lines) {
+		return diagnostic
+	}
+
+	outputLine := lines[diagnostic.OutputLine-1]
+	indentation := len(outputLine) - len(strings.TrimLeft(outputLine, " \t"))
+	diagnostic.OutputExcerpt = excerpt(processedTemplate, diagnostic.OutputLine, indentation+1)
+
+	if validationRequest.sourceMap == nil {
+		return diagnostic
+	}
+	offset, ok := outputOffset(output, diagnostic.OutputLine)
+	if !ok {
+		return diagnostic
+	}
+	templateOffset, ok := validationRequest.sourceMap.locate(offset)
+	if !ok {
+		return diagnostic
+	}
+	diagnostic.Line, diagnostic.Column = lineAndColumn(validationRequest.Template, templateOffset)
+	diagnostic.Excerpt = excerpt(validationRequest.Template, diagnostic.Line, diagnostic.Column)
+
+	return diagnostic
+}
+
+// Returns the offset in the output of a template of the first character of a line of the processed template, which
+// is the output without leading and trailing whitespace. Lines only lose their trailing whitespace otherwise
+func outputOffset(output string, line int) (int, bool) {
+	leadingWhitespace := output[:len(output)-len(strings.TrimLeftFunc(output, unicode.IsSpace))]
+	line += strings.Count(leadingWhitespace, "\n")
+
+	offset := 0
+	for current := 1; current < line; current++ {
+		next := strings.IndexByte(output[offset:], '\n')
+		if next < 0 {
+			return 0, false
+		}
+		offset += next + 1
+	}
+
+	lineEnd := strings.IndexByte(output[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(output) - offset
+	}
+	content := output[offset : offset+lineEnd]
+	if trimmed := strings.TrimLeft(content, " \t"); trimmed != "" {
+		offset += len(content) - len(trimmed)
+	}
+
+	return offset, true
+}
+
+// Returns the template line with the supplied line number, with a marker under the column if known
+func excerpt(source string, line int, column int) string {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	prefix := fmt.Sprintf("%4d | ", line)
+	output := prefix + lines[line-1]
+	if column > 0 {
+		output += "\n" + strings.Repeat(" ", len(prefix)-2) + "| " + strings.Repeat(" ", column-1) + "^"
+	}
+
+	return output
+}
@@ -0,0 +1,206 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDiagnostics(test *testing.T) {
+	cases := []struct {
+		template     string
+		templateType string
+		parameters   interface{}
+		expected     []TemplateDiagnostic
+	}{
+		{
+			"steps:\n  - name: {{ .step.name }}\n    image: {{ .image | upper }}",
+			"",
+			map[string]interface{}{
+				"step": "build",
+			},
+			[]TemplateDiagnostic{
+				{
+					Stage:   "execute",
+					Line:    2,
+					Column:  19,
+					Action:  ".step.name",
+					Message: "can't evaluate field name in type interface {}",
+					Excerpt: "   2 |   - name: {{ .step.name }}\n     |                   ^",
+				},
+			},
+		},
+		{
+			"steps:\n  - name: {{ .name | shout }}",
+			"",
+			nil,
+			[]TemplateDiagnostic{
+				{
+					Stage:   "parse",
+					Line:    2,
+					Message: "function \"shout\" not defined",
+					Excerpt: "   2 |   - name: {{ .name | shout }}",
+				},
+			},
+		},
+		{
+			"def main(ctx):\n  return {\n    'steps': [\n  }",
+			"starlark",
+			nil,
+			[]TemplateDiagnostic{
+				{
+					Stage:   "parse",
+					Line:    4,
+					Column:  3,
+					Message: "got '}', want primary expression",
+					Excerpt: "   4 |   }\n     |   ^",
+				},
+			},
+		},
+		{
+			"def main(ctx):\n  return {\n    'image': ctx['vars']['image'],\n  }",
+			"starlark",
+			map[string]interface{}{},
+			[]TemplateDiagnostic{
+				{
+					Stage:   "execute",
+					Line:    3,
+					Column:  25,
+					Action:  "main",
+					Message: "key \"image\" not in dict",
+					Excerpt: "   3 |     'image': ctx['vars']['image'],\n     |                         ^",
+				},
+			},
+		},
+		{
+			"def build(ctx):\n  return {}",
+			"starlark",
+			map[string]interface{}{},
+			nil,
+		},
+		{
+			"steps: [ {{ .image }}",
+			"",
+			map[string]interface{}{
+				"image": "alpine",
+			},
+			[]TemplateDiagnostic{
+				{
+					Stage:         "yaml",
+					Line:          1,
+					Column:        1,
+					Message:       "did not find expected ',' or ']'",
+					Excerpt:       "   1 | steps: [ {{ .image }}\n     | ^",
+					OutputLine:    1,
+					OutputExcerpt: "   1 | steps: [ alpine\n     | ^",
+				},
+			},
+		},
+		{
+			// Lines of the output are located at the text of the 'range' that produced them
+			"steps:\n{{- range .steps }}\n  - name: {{ .name }}\n    image: {{ .image }}\n{{- end }}",
+			"",
+			map[string]interface{}{
+				"steps": []interface{}{
+					map[string]interface{}{"name": "build", "image": "golang:1.23"},
+					map[string]interface{}{"name": "lint", "image": "golangci: v1"},
+				},
+			},
+			[]TemplateDiagnostic{
+				{
+					Stage:         "yaml",
+					Line:          4,
+					Column:        5,
+					Message:       "mapping values are not allowed in this context",
+					Excerpt:       "   4 |     image: {{ .image }}\n     |     ^",
+					OutputLine:    5,
+					OutputExcerpt: "   5 |     image: golangci: v1\n     |     ^",
+				},
+			},
+		},
+		{
+			// Trimming joins the line after the action to the one before it
+			"steps:\n  - name: build\n    image: golang:1.23\n    pull: always\n{{- if .debug -}}\n    environment:\n      DEBUG: true\n{{- end }}",
+			"",
+			map[string]interface{}{
+				"debug": true,
+			},
+			[]TemplateDiagnostic{
+				{
+					Stage:         "yaml",
+					Line:          4,
+					Column:        5,
+					Message:       "mapping values are not allowed in this context",
+					Excerpt:       "   4 |     pull: always\n     |     ^",
+					OutputLine:    4,
+					OutputExcerpt: "   4 |     pull: alwaysenvironment:\n     |     ^",
+				},
+			},
+		},
+		{
+			// Output of an action is located at the action
+			"steps:\n  - name: build\n    image: golang:1.23\n{{ .commands }}",
+			"",
+			map[string]interface{}{
+				"commands": "    commands: make: all",
+			},
+			[]TemplateDiagnostic{
+				{
+					Stage:         "yaml",
+					Line:          4,
+					Column:        1,
+					Message:       "mapping values are not allowed in this context",
+					Excerpt:       "   4 | {{ .commands }}\n     | ^",
+					OutputLine:    4,
+					OutputExcerpt: "   4 |     commands: make: all\n     |     ^",
+				},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		validationResponse := Validate(context.Background(), ValidationRequest{
+			Template:   data.template,
+			Type:       data.templateType,
+			Parameters: data.parameters,
+		})
+
+		assert.Equal(test, data.expected, validationResponse.Diagnostics)
+	}
+}
+
+func TestExcerpt(test *testing.T) {
+	source := "steps:\n  - name: build"
+
+	assert.Equal(test, "   2 |   - name: build", excerpt(source, 2, 0))
+	assert.Equal(test, "   1 | steps:\n     | ^", excerpt(source, 1, 1))
+	assert.Equal(test, "", excerpt(source, 3, 1))
+	assert.Equal(test, "", excerpt(source, 0, 0))
+}
+
+func TestOutputOffset(test *testing.T) {
+	output := "\n\n  steps:  \n  - name: build\n\n"
+
+	cases := []struct {
+		line          int
+		expected      int
+		expectedFound bool
+	}{
+		{1, 4, true},
+		{2, 15, true},
+		{5, 0, false},
+	}
+
+	for _, data := range cases {
+		offset, found := outputOffset(output, data.line)
+
+		assert.Equal(test, data.expectedFound, found, data.line)
+		if found {
+			assert.Equal(test, data.expected, offset, data.line)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Types of differences between the expected and the actual output of a template
+const (
+	DifferenceAdded   = "added"
+	DifferenceRemoved = "removed"
+	DifferenceChanged = "changed"
+)
+
+// Number of unchanged lines shown around the changes in a unified diff
+const diffContextLines = 3
+
+// A key or list element that differs between the expected and the actual output of a template
+type OutputDifference struct {
+	Path     string      `yaml:"path" json:"path"`
+	Type     string      `yaml:"type" json:"type"`
+	Expected interface{} `yaml:"expected,omitempty" json:"expected,omitempty"`
+	Actual   interface{} `yaml:"actual,omitempty" json:"actual,omitempty"`
+}
+
+func (difference OutputDifference) String() string {
+	path := difference.Path
+	if path == "" {
+		path = "(document)"
+	}
+
+	switch difference.Type {
+	case DifferenceAdded:
+		return fmt.Sprintf("%s: added %s", path, formatDiffValue(difference.Actual))
+	case DifferenceRemoved:
+		return fmt.Sprintf("%s: removed %s", path, formatDiffValue(difference.Expected))
+	default:
+		return fmt.Sprintf("%s: changed from %s to %s", path, formatDiffValue(difference.Expected), formatDiffValue(difference.Actual))
+	}
+}
+
+// Differences between the expected and the actual output of a template, both as the keys
+// that differ and as a unified diff of the normalized yaml
+type OutputDiff struct {
+	Differences []OutputDifference `yaml:"differences" json:"differences"`
+	UnifiedDiff string             `yaml:"unified_diff,omitempty" json:"unified_diff,omitempty"`
+}
+
+// Indicates if the expected and the actual output are the same
+func (diff OutputDiff) Matches() bool {
+	return len(diff.Differences) == 0
+}
+
+// Compares the expected output of a template with the actual output, after parsing both as yaml
+func DiffOutput(expectedOutput string, actualOutput string) (OutputDiff, error) {
+	expected, err := ParseOrderedYaml(expectedOutput)
+	if err != nil {
+		return OutputDiff{}, fmt.Errorf("expected output is not a valid yaml: %s", err.Error())
+	}
+
+	actual, err := ParseOrderedYaml(actualOutput)
+	if err != nil {
+		return OutputDiff{}, fmt.Errorf("output is not a valid yaml: %s", err.Error())
+	}
+
+	diff := OutputDiff{Differences: diffValues("", expected, actual)}
+	if !diff.Matches() {
+		diff.UnifiedDiff = UnifiedDiff("expected", "actual", NormalizeYaml(expected), NormalizeYaml(actual))
+	}
+
+	return diff, nil
+}
+
+// Marshals a parsed yaml document into yaml. Ordered maps keep the order of their keys, while the keys of other maps are sorted
+func NormalizeYaml(document interface{}) string {
+	if document == nil {
+		return ""
+	}
+
+	output, err := yaml.Marshal(document)
+	if err != nil {
+		return fmt.Sprint(document)
+	}
+
+	return string(output)
+}
+
+func diffValues(path string, expected interface{}, actual interface{}) []OutputDifference {
+	expectedMap, expectedIsMap := toStringMap(expected)
+	actualMap, actualIsMap := toStringMap(actual)
+	if expectedIsMap && actualIsMap {
+		differences := []OutputDifference{}
+		keys := map[string]interface{}{}
+		for key := range expectedMap {
+			keys[key] = nil
+		}
+		for key := range actualMap {
+			keys[key] = nil
+		}
+
+		for _, key := range sortedStringKeys(keys) {
+			expectedValue, inExpected := expectedMap[key]
+			actualValue, inActual := actualMap[key]
+			keyPath := joinPath(path, key)
+
+			if !inActual {
+				differences = append(differences, OutputDifference{keyPath, DifferenceRemoved, NormalizeValue(expectedValue), nil})
+			} else if !inExpected {
+				differences = append(differences, OutputDifference{keyPath, DifferenceAdded, nil, NormalizeValue(actualValue)})
+			} else {
+				differences = append(differences, diffValues(keyPath, expectedValue, actualValue)...)
+			}
+		}
+		return differences
+	}
+
+	expectedList, expectedIsList := expected.([]interface{})
+	actualList, actualIsList := actual.([]interface{})
+	if expectedIsList && actualIsList {
+		differences := []OutputDifference{}
+		for index := 0; index < len(expectedList) || index < len(actualList); index++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, index)
+
+			if index >= len(actualList) {
+				differences = append(differences, OutputDifference{itemPath, DifferenceRemoved, NormalizeValue(expectedList[index]), nil})
+			} else if index >= len(expectedList) {
+				differences = append(differences, OutputDifference{itemPath, DifferenceAdded, nil, NormalizeValue(actualList[index])})
+			} else {
+				differences = append(differences, diffValues(itemPath, expectedList[index], actualList[index])...)
+			}
+		}
+		return differences
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return []OutputDifference{{path, DifferenceChanged, NormalizeValue(expected), NormalizeValue(actual)}}
+	}
+
+	return nil
+}
+
+// Converts the maps parsed from yaml, at any depth, into maps with string keys, so that they can be marshalled into json
+func NormalizeValue(value interface{}) interface{} {
+	if entries, ok := value.(OrderedMap); ok {
+		normalized := make(OrderedMap, len(entries))
+		for index, entry := range entries {
+			normalized[index] = yaml.MapItem{Key: fmt.Sprint(entry.Key), Value: NormalizeValue(entry.Value)}
+		}
+		return normalized
+	}
+
+	if entries, ok := toStringMap(value); ok {
+		normalized := make(map[string]interface{}, len(entries))
+		for key, entry := range entries {
+			normalized[key] = NormalizeValue(entry)
+		}
+		return normalized
+	}
+
+	if items, ok := value.([]interface{}); ok {
+		normalized := make([]interface{}, len(items))
+		for index, item := range items {
+			normalized[index] = NormalizeValue(item)
+		}
+		return normalized
+	}
+
+	return value
+}
+
+func formatDiffValue(value interface{}) string {
+	output, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(output)
+}
+
+// A line of a line based diff. Operation is ' ' for an unchanged line, '-' for a removed line and '+' for an added line
+type lineEdit struct {
+	operation byte
+	line      string
+}
+
+// Returns a unified diff of two texts with 3 lines of context, or an empty string if they are the same
+func UnifiedDiff(fromLabel string, toLabel string, from string, to string) string {
+	edits := diffLines(splitLines(from), splitLines(to))
+
+	changes := []int{}
+	for index, edit := range edits {
+		if edit.operation != ' ' {
+			changes = append(changes, index)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "--- %s\n+++ %s\n", fromLabel, toLabel)
+
+	// Changes closer than twice the context are shown in the same hunk
+	for start := 0; start < len(changes); {
+		end := start
+		for end+1 < len(changes) && changes[end+1]-changes[end] <= 2*diffContextLines {
+			end++
+		}
+
+		writeHunk(builder, edits, max(changes[start]-diffContextLines, 0), min(changes[end]+diffContextLines+1, len(edits)))
+		start = end + 1
+	}
+
+	return builder.String()
+}
+
+// Writes the edits from the first index up to the last index as a hunk
+func writeHunk(builder *strings.Builder, edits []lineEdit, first int, last int) {
+	fromStart, toStart := 1, 1
+	for _, edit := range edits[:first] {
+		if edit.operation != '+' {
+			fromStart++
+		}
+		if edit.operation != '-' {
+			toStart++
+		}
+	}
+
+	fromCount, toCount := 0, 0
+	for _, edit := range edits[first:last] {
+		if edit.operation != '+' {
+			fromCount++
+		}
+		if edit.operation != '-' {
+			toCount++
+		}
+	}
+
+	// Empty ranges start at the line before the hunk
+	if fromCount == 0 {
+		fromStart--
+	}
+	if toCount == 0 {
+		toStart--
+	}
+
+	fmt.Fprintf(builder, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+	for _, edit := range edits[first:last] {
+		builder.WriteByte(edit.operation)
+		builder.WriteString(edit.line)
+		builder.WriteByte('\n')
+	}
+}
+
+// Computes the edits that turn the first list of lines into the second, using the longest common subsequence
+func diffLines(from []string, to []string) []lineEdit {
+	// common[i][j] is the length of the longest common subsequence of from[i:] and to[j:]
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	edits := []lineEdit{}
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		if from[i] == to[j] {
+			edits = append(edits, lineEdit{' ', from[i]})
+			i++
+			j++
+		} else if common[i+1][j] >= common[i][j+1] {
+			edits = append(edits, lineEdit{'-', from[i]})
+			i++
+		} else {
+			edits = append(edits, lineEdit{'+', to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		edits = append(edits, lineEdit{'-', from[i]})
+	}
+	for ; j < len(to); j++ {
+		edits = append(edits, lineEdit{'+', to[j]})
+	}
+
+	return edits
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
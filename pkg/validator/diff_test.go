@@ -0,0 +1,96 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffOutput(test *testing.T) {
+	cases := []struct {
+		expected            string
+		actual              string
+		expectedDifferences []OutputDifference
+		expectedUnifiedDiff string
+	}{
+		{
+			"version: '1'\nsteps:\n  - name: build\n    image: golang:1.23\n",
+			"steps: [ { image: 'golang:1.23', name: build } ]\nversion: \"1\"",
+			[]OutputDifference{},
+			"",
+		},
+		{
+			"version: '1'\nsteps:\n  - name: build\n    image: golang:1.22\n    commands: [ go build ]\n  - name: test\n    image: golang:1.22\n",
+			"version: '1'\nsteps:\n  - name: build\n    image: golang:1.23\n    pull: always\nservices:\n  - name: redis\n",
+			[]OutputDifference{
+				{"services", DifferenceAdded, nil, []interface{}{OrderedMap{{Key: "name", Value: "redis"}}}},
+				{"steps[0].commands", DifferenceRemoved, []interface{}{"go build"}, nil},
+				{"steps[0].image", DifferenceChanged, "golang:1.22", "golang:1.23"},
+				{"steps[0].pull", DifferenceAdded, nil, "always"},
+				{"steps[1]", DifferenceRemoved, OrderedMap{{Key: "name", Value: "test"}, {Key: "image", Value: "golang:1.22"}}, nil},
+			},
+			// Keys are in the order of the documents
+			"--- expected\n+++ actual\n@@ -1,8 +1,7 @@\n version: \"1\"\n steps:\n - name: build\n" +
+				"-  image: golang:1.22\n-  commands:\n-  - go build\n-- name: test\n-  image: golang:1.22\n" +
+				"+  image: golang:1.23\n+  pull: always\n+services:\n+- name: redis\n",
+		},
+		{
+			"1",
+			"2",
+			[]OutputDifference{{"", DifferenceChanged, 1, 2}},
+			"--- expected\n+++ actual\n@@ -1,1 +1,1 @@\n-1\n+2\n",
+		},
+	}
+
+	for _, data := range cases {
+		diff, err := DiffOutput(data.expected, data.actual)
+
+		assert.Nil(test, err)
+		if len(data.expectedDifferences) == 0 {
+			assert.Empty(test, diff.Differences)
+			assert.True(test, diff.Matches())
+		} else {
+			assert.Equal(test, data.expectedDifferences, diff.Differences)
+			assert.False(test, diff.Matches())
+		}
+		assert.Equal(test, data.expectedUnifiedDiff, diff.UnifiedDiff)
+	}
+}
+
+func TestDiffOutputInvalidYaml(test *testing.T) {
+	_, err := DiffOutput("steps: [", "steps: []")
+	assert.Equal(test, "expected output is not a valid yaml: yaml: line 1: did not find expected node content", err.Error())
+
+	_, err = DiffOutput("steps: []", "steps: [")
+	assert.Equal(test, "output is not a valid yaml: yaml: line 1: did not find expected node content", err.Error())
+}
+
+func TestOutputDifferenceString(test *testing.T) {
+	cases := []struct {
+		difference OutputDifference
+		expected   string
+	}{
+		{OutputDifference{"steps[0].pull", DifferenceAdded, nil, "always"}, `steps[0].pull: added "always"`},
+		{OutputDifference{"steps[0].commands", DifferenceRemoved, []interface{}{"go build"}, nil}, `steps[0].commands: removed ["go build"]`},
+		{OutputDifference{"version", DifferenceChanged, "1", 1}, `version: changed from "1" to 1`},
+		{OutputDifference{"", DifferenceChanged, 1, 2}, `(document): changed from 1 to 2`},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, data.difference.String())
+	}
+}
+
+func TestUnifiedDiff(test *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\n"
+
+	assert.Equal(test, "--- from\n+++ to\n"+
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n"+
+		"@@ -12,3 +12,4 @@\n l\n m\n n\n+o\n", UnifiedDiff("from", "to", from, to))
+	assert.Equal(test, "--- from\n+++ to\n@@ -0,0 +1,1 @@\n+a\n", UnifiedDiff("from", "to", "", "a\n"))
+	assert.Equal(test, "", UnifiedDiff("from", "to", from, from))
+}
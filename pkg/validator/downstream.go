@@ -0,0 +1,81 @@
+package validator
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// An expression of a go template that is probably meant for a downstream plugin, like '{{.BuildLink}}' in the text
+// of a slack notification, but is processed along with the template as it is not escaped
+type UnescapedExpression struct {
+	Variable   string `yaml:"variable" json:"variable"`
+	Expression string `yaml:"expression" json:"expression"` // Text of the expression, like '.build.BuildLink'
+	Lines      []int  `yaml:"lines" json:"lines"`
+	Suggestion string `yaml:"suggestion" json:"suggestion"`                 // Escaped form of the expression, which outputs it as is
+	Template   string `yaml:"template,omitempty" json:"template,omitempty"` // Name of the template of a pipeline the expression is in
+}
+
+func (expression UnescapedExpression) String() string {
+	lines := make([]string, len(expression.Lines))
+	for index, line := range expression.Lines {
+		lines[index] = fmt.Sprint(line)
+	}
+
+	location := "line " + lines[0]
+	if len(lines) > 1 {
+		location = "lines " + strings.Join(lines, ", ")
+	}
+
+	return fmt.Sprintf("'{{%s}}' at %s is not a template variable, but probably one of a downstream plugin. Escape it as '%s'",
+		expression.Expression, location, expression.Suggestion)
+}
+
+// Returns the variables that plugins like slack and email replace in their own parameters, which
+// templates pass on by escaping them, like '{{"{{.BuildLink}}"}}'
+func DefaultDownstreamVariables() []string {
+	return []string{
+		"BuildAuthor", "BuildAuthorEmail", "BuildBranch", "BuildCommit", "BuildCreated", "BuildEnqueued", "BuildEvent",
+		"BuildFinished", "BuildHost", "BuildLink", "BuildMessage", "BuildNumber", "BuildRef", "BuildStarted",
+		"BuildStatus", "BuildTag", "BuildTitle", "BuildURL", "RepoBranch", "RepoFullName", "RepoLink", "RepoName",
+		"RepoOrg", "RepositoryBranch", "RepositoryFullName", "RepositoryName", "RepositoryOrg",
+	}
+}
+
+// Finds the variables of a parsed go template that are not supplied in the parameters, but are named like the
+// variables of downstream plugins. Such expressions render as '<no value>' or fail in strict mode
+func findUnescapedExpressions(validationRequest *ValidationRequest, root parse.Node) []UnescapedExpression {
+	collector := newVariableCollector(validationRequest.Template)
+	collector.walkGoTemplate(root, "", true, map[string]string{})
+	downstreamVariables := append(DefaultDownstreamVariables(), validationRequest.DownstreamVariables...)
+
+	var expressions []UnescapedExpression
+	for _, variable := range collector.variables {
+		// Variables within a 'range' can't be looked up in the parameters
+		if strings.Contains(variable.Name, "[]") {
+			continue
+		}
+
+		fields := strings.Split(variable.Name, ".")
+		name := fields[len(fields)-1]
+		if !slices.Contains(downstreamVariables, name) {
+			continue
+		}
+		if _, supplied := lookupFields(validationRequest.Parameters, fields); supplied {
+			continue
+		}
+
+		// The downstream plugin receives the expression as written, like '{{.build.BuildLink}}'
+		expression := collector.expressions[variable.Name]
+		expressions = append(expressions, UnescapedExpression{
+			Variable:   name,
+			Expression: expression,
+			Lines:      variable.Lines,
+			Suggestion: "{{" + strconv.Quote("{{"+expression+"}}") + "}}",
+		})
+	}
+
+	return expressions
+}
@@ -0,0 +1,105 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindUnescapedExpressions(test *testing.T) {
+	cases := []struct {
+		template            string
+		parameters          interface{}
+		downstreamVariables []string
+		expected            []UnescapedExpression
+	}{
+		{
+			`text: Success: {{"{{.BuildLink}}"}} ({{"{{.BuildRef}}"}})`,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"text: |-\n  Success: {{.BuildLink}} ({{ .BuildRef }})\n  {{ .BuildLink }} by {{ .author }}",
+			map[string]interface{}{"author": "devatherock"},
+			nil,
+			[]UnescapedExpression{
+				{Variable: "BuildLink", Expression: ".BuildLink", Lines: []int{2, 3}, Suggestion: `{{"{{.BuildLink}}"}}`},
+				{Variable: "BuildRef", Expression: ".BuildRef", Lines: []int{2}, Suggestion: `{{"{{.BuildRef}}"}}`},
+			},
+		},
+		{
+			// Supplied variables are template variables, even if named like those of plugins
+			"text: {{ .BuildLink }}\nchannel: {{ with .slack }}{{ .BuildNumber }}{{ end }}",
+			map[interface{}]interface{}{"BuildLink": "https://vela.io", "slack": map[interface{}]interface{}{"BuildNumber": 1}},
+			nil,
+			nil,
+		},
+		{
+			"text: {{ with .slack }}{{ .BuildNumber }}{{ end }} {{ .DeployLink }}{{ range .items }}{{ .BuildLink }}{{ end }}",
+			map[string]interface{}{},
+			[]string{"DeployLink"},
+			[]UnescapedExpression{
+				{Variable: "BuildNumber", Expression: ".BuildNumber", Lines: []int{1}, Suggestion: `{{"{{.BuildNumber}}"}}`},
+				{Variable: "DeployLink", Expression: ".DeployLink", Lines: []int{1}, Suggestion: `{{"{{.DeployLink}}"}}`},
+			},
+		},
+		{
+			// The suggestion keeps the expression as written, which is what the downstream plugin receives
+			"text: {{ .build.BuildLink | upper }} {{ index $.repo \"RepoName\" }}",
+			map[string]interface{}{"build": map[string]interface{}{}},
+			nil,
+			[]UnescapedExpression{
+				{Variable: "BuildLink", Expression: ".build.BuildLink", Lines: []int{1}, Suggestion: `{{"{{.build.BuildLink}}"}}`},
+				{Variable: "RepoName", Expression: `index $.repo "RepoName"`, Lines: []int{1}, Suggestion: `{{"{{index $.repo \"RepoName\"}}"}}`},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		parsedTemplate, _ := template.New("test").Funcs(sprig.TxtFuncMap()).Parse(data.template)
+		actual := findUnescapedExpressions(&ValidationRequest{
+			Template:            data.template,
+			Parameters:          data.parameters,
+			DownstreamVariables: data.downstreamVariables,
+		}, parsedTemplate.Tree.Root)
+
+		assert.Equal(test, data.expected, actual, data.template)
+	}
+}
+
+func TestUnescapedExpressionString(test *testing.T) {
+	assert.Equal(test, `'{{.BuildLink}}' at line 3 is not a template variable, but probably one of a downstream plugin. Escape it as '{{"{{.BuildLink}}"}}'`,
+		UnescapedExpression{Variable: "BuildLink", Expression: ".BuildLink", Lines: []int{3}, Suggestion: `{{"{{.BuildLink}}"}}`}.String())
+	assert.Equal(test, `'{{.build.BuildRef}}' at lines 3, 5 is not a template variable, but probably one of a downstream plugin. Escape it as '{{"{{.build.BuildRef}}"}}'`,
+		UnescapedExpression{Variable: "BuildRef", Expression: ".build.BuildRef", Lines: []int{3, 5}, Suggestion: `{{"{{.build.BuildRef}}"}}`}.String())
+}
+
+func TestValidateWithUnescapedExpressions(test *testing.T) {
+	template := "steps:\n  - name: notify\n    image: devatherock/simple-slack:0.2.0\n    parameters:\n      text: Success {{.BuildLink}}"
+
+	validationResponse := Validate(context.Background(), ValidationRequest{Template: template})
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Equal(test, "steps:\n  - name: notify\n    image: devatherock/simple-slack:0.2.0\n    parameters:\n      text: Success <no value>", validationResponse.Template)
+	assert.Equal(test, []UnescapedExpression{
+		{Variable: "BuildLink", Expression: ".BuildLink", Lines: []int{5}, Suggestion: `{{"{{.BuildLink}}"}}`},
+	}, validationResponse.UnescapedExpressions)
+
+	// Reported along with the failure in strict mode
+	validationResponse = Validate(context.Background(), ValidationRequest{Template: template, Strict: true})
+	assert.Equal(test, "undefined variables: 'BuildLink' at line 5, column 23", validationResponse.Error)
+	assert.Equal(test, 1, len(validationResponse.UnescapedExpressions))
+
+	// Not applied to starlark templates
+	validationResponse = Validate(context.Background(), ValidationRequest{
+		Template: "def main(ctx):\n  return {'steps': [{'name': 'notify', 'image': 'alpine:3.20', 'parameters': {'text': '{{.BuildLink}}'}}]}",
+		Type:     "starlark",
+	})
+	assert.Nil(test, validationResponse.UnescapedExpressions)
+}
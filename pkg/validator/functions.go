@@ -0,0 +1,127 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// Functions available to go templates in a range of vela versions
+type velaFunctionProfile struct {
+	version   *semver.Version // First vela version with this set of functions
+	functions []string        // Functions added by vela on top of sprig
+}
+
+// Function maps of the vela compiler, oldest first. All versions remove the os functions of sprig
+var velaFunctionProfiles = []velaFunctionProfile{
+	{semver.MustParse("0.7.0"), []string{}},
+	{semver.MustParse("0.8.0"), []string{"vela"}},
+	{semver.MustParse("0.17.0"), []string{"vela", "toYaml"}},
+}
+
+// Sprig functions that vela removes to prevent access to the environment of the server
+var velaRemovedFunctions = []string{"env", "expandenv"}
+
+// Matches parse errors like 'template: test:3: function "env" not defined'
+var undefinedFunctionRegex = regexp.MustCompile(`function "([^"]+)" not defined$`)
+
+// Returns the function map for go templates. Without a vela version, all sprig functions are
+// available along with a simulated 'vela' function. With a vela version, the function map of that
+// version of the vela compiler is returned
+func GoTemplateFuncMap(velaVersion string) (template.FuncMap, error) {
+	if velaVersion == "" {
+		functions := sprig.TxtFuncMap()
+		functions["vela"] = vela
+		return functions, nil
+	}
+
+	profile, err := findVelaFunctionProfile(velaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	functions := sprig.TxtFuncMap()
+	for _, removedFunction := range velaRemovedFunctions {
+		delete(functions, removedFunction)
+	}
+
+	velaFunctions := template.FuncMap{
+		"vela":   vela,
+		"toYaml": toYaml,
+	}
+	for _, function := range profile.functions {
+		functions[function] = velaFunctions[function]
+	}
+
+	return functions, nil
+}
+
+// Parses a go template with the function map of a vela version, to walk it without processing it
+func parseGoTemplate(source string, velaVersion string) (*template.Template, error) {
+	functions, err := GoTemplateFuncMap(velaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New("test").Funcs(functions).Parse(source)
+}
+
+func findVelaFunctionProfile(velaVersion string) (velaFunctionProfile, error) {
+	if velaVersion == "latest" {
+		return velaFunctionProfiles[len(velaFunctionProfiles)-1], nil
+	}
+
+	version, err := semver.NewVersion(velaVersion)
+	if err != nil {
+		return velaFunctionProfile{}, fmt.Errorf("invalid vela version '%s'", velaVersion)
+	}
+
+	for index := len(velaFunctionProfiles) - 1; index >= 0; index-- {
+		if !version.LessThan(velaFunctionProfiles[index].version) {
+			return velaFunctionProfiles[index], nil
+		}
+	}
+
+	return velaFunctionProfile{}, fmt.Errorf("vela versions older than %s are not supported", velaFunctionProfiles[0].version)
+}
+
+// Rewrites the error for a function that exists in sprig, but is not allowed by the sandbox
+// or not provided by the selected vela version
+func explainUndefinedFunction(err error, validationRequest *ValidationRequest) error {
+	matches := undefinedFunctionRegex.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return err
+	}
+
+	function := matches[1]
+	if _, known := sprig.TxtFuncMap()[function]; !known && function != "vela" && function != "toYaml" {
+		return err
+	}
+
+	var explanation string
+	if validationRequest.Sandbox != nil && !validationRequest.Sandbox.allows(function) {
+		explanation = fmt.Sprintf("function \"%s\" is not allowed", function)
+	} else if validationRequest.VelaVersion != "" {
+		explanation = fmt.Sprintf("function \"%s\" is not provided by vela %s", function, validationRequest.VelaVersion)
+	} else {
+		return err
+	}
+
+	return errors.New(strings.TrimSuffix(err.Error(), matches[0]) + explanation)
+}
+
+// Simulates the 'toYaml' function of vela, which converts a value into a yaml string
+func toYaml(value interface{}) string {
+	output, err := yaml.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(string(output), "\n")
+}
@@ -0,0 +1,137 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoTemplateFuncMap(test *testing.T) {
+	cases := []struct {
+		velaVersion string
+		present     []string
+		absent      []string
+	}{
+		{"", []string{"env", "expandenv", "vela", "quote"}, []string{"toYaml"}},
+		{"0.7.3", []string{"quote"}, []string{"env", "expandenv", "vela", "toYaml"}},
+		{"0.16.2", []string{"vela", "quote"}, []string{"env", "toYaml"}},
+		{"v0.17.0", []string{"vela", "toYaml"}, []string{"env"}},
+		{"latest", []string{"vela", "toYaml"}, []string{"expandenv"}},
+	}
+
+	for _, data := range cases {
+		functions, err := GoTemplateFuncMap(data.velaVersion)
+
+		assert.Nil(test, err)
+		for _, function := range data.present {
+			assert.Contains(test, functions, function, data.velaVersion)
+		}
+		for _, function := range data.absent {
+			assert.NotContains(test, functions, function, data.velaVersion)
+		}
+	}
+}
+
+func TestGoTemplateFuncMapInvalidVersion(test *testing.T) {
+	cases := []struct {
+		velaVersion string
+		expected    string
+	}{
+		{"next", "invalid vela version 'next'"},
+		{"0.6.1", "vela versions older than 0.7.0 are not supported"},
+	}
+
+	for _, data := range cases {
+		_, err := GoTemplateFuncMap(data.velaVersion)
+
+		assert.Equal(test, data.expected, err.Error())
+	}
+}
+
+func TestExplainUndefinedFunction(test *testing.T) {
+	cases := []struct {
+		err         error
+		velaVersion string
+		expected    string
+	}{
+		{
+			fmt.Errorf(`template: test:5: function "env" not defined`),
+			"0.16.0",
+			`template: test:5: function "env" is not provided by vela 0.16.0`,
+		},
+		{
+			fmt.Errorf(`template: test:2: function "toYaml" not defined`),
+			"0.8.0",
+			`template: test:2: function "toYaml" is not provided by vela 0.8.0`,
+		},
+		{
+			fmt.Errorf(`template: test:2: function "unknown" not defined`),
+			"0.8.0",
+			`template: test:2: function "unknown" not defined`,
+		},
+		{
+			fmt.Errorf(`template: test:5: function "env" not defined`),
+			"",
+			`template: test:5: function "env" not defined`,
+		},
+	}
+
+	for _, data := range cases {
+		validationRequest := &ValidationRequest{VelaVersion: data.velaVersion}
+		assert.Equal(test, data.expected, explainUndefinedFunction(data.err, validationRequest).Error())
+	}
+}
+
+func TestValidateWithVelaVersion(test *testing.T) {
+	cases := []struct {
+		template    string
+		velaVersion string
+		expected    ValidationResponse
+	}{
+		{
+			"image: {{ .image | quote }}\nargs: {{ toYaml .args | nindent 2 }}\n",
+			"0.17.0",
+			ValidationResponse{
+				Message:     "template is a valid yaml",
+				Template:    "image: \"alpine\"\nargs:\n  - one\n  - two",
+				FailedStage: StageSchema,
+			},
+		},
+		{
+			"home: {{ env \"HOME\" }}\n",
+			"0.16.0",
+			ValidationResponse{
+				Message: "Invalid template",
+				Error:   `template: test:1: function "env" is not provided by vela 0.16.0`,
+				Diagnostics: []TemplateDiagnostic{
+					{
+						Stage:   StageParse,
+						Line:    1,
+						Message: `function "env" is not provided by vela 0.16.0`,
+						Excerpt: "   1 | home: {{ env \"HOME\" }}",
+					},
+				},
+				FailedStage: StageParse,
+			},
+		},
+	}
+
+	for _, data := range cases {
+		actual := Validate(context.Background(), ValidationRequest{
+			Template:    data.template,
+			VelaVersion: data.velaVersion,
+			Parameters: map[string]interface{}{
+				"image": "alpine",
+				"args":  []interface{}{"one", "two"},
+			},
+		})
+
+		data.expected.SchemaErrors = actual.SchemaErrors
+		assert.Equal(test, data.expected, actual)
+	}
+}
@@ -0,0 +1,244 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Names of the limits that can be exceeded while processing a template
+const (
+	LimitStarlarkSteps   = "starlark_steps"
+	LimitRangeIterations = "range_iterations"
+	LimitOutputBytes     = "output_bytes"
+	LimitTimeout         = "timeout"
+)
+
+// Budgets for processing a template. Zero values mean no limit
+type Limits struct {
+	MaxStarlarkSteps   uint64        // Maximum execution steps of a starlark template
+	MaxRangeIterations int           // Maximum iterations of all 'range' actions in a go template
+	MaxOutputBytes     int           // Maximum size of the processed template
+	Timeout            time.Duration // Maximum time to process a template
+}
+
+// Error returned when processing a template exceeds one of its limits
+type LimitExceededError struct {
+	Limit   string // Name of the exceeded limit
+	Message string
+	Cause   error // Error from the template engine, if any
+}
+
+func (limitError *LimitExceededError) Error() string {
+	return limitError.Message
+}
+
+func (limitError *LimitExceededError) Unwrap() error {
+	return limitError.Cause
+}
+
+// Returns the limits applied to the templates supplied to the API
+func DefaultLimits() Limits {
+	return Limits{
+		MaxStarlarkSteps:   1000000,
+		MaxRangeIterations: 10000,
+		MaxOutputBytes:     1024 * 1024,
+		Timeout:            10 * time.Second,
+	}
+}
+
+// Function injected into the pipeline of 'range' actions to count the iterations
+const rangeLimitFunction = "limitRange"
+
+// Returns a function that stops a go template once it is cancelled or the collections
+// ranged over exceed the iteration limit in total
+func (limits Limits) rangeLimit(ctx context.Context) func(collection interface{}) (interface{}, error) {
+	iterations := 0
+
+	return func(collection interface{}) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, limits.contextError(err)
+		}
+
+		value := reflect.ValueOf(collection)
+		switch value.Kind() {
+		case reflect.Array, reflect.Slice, reflect.Map:
+			iterations += value.Len()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			iterations += int(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			iterations += int(value.Uint())
+		case reflect.Chan, reflect.Func:
+			if limits.MaxRangeIterations > 0 {
+				return nil, fmt.Errorf("range over %s is not allowed", value.Kind())
+			}
+		}
+
+		if limits.MaxRangeIterations > 0 && iterations > limits.MaxRangeIterations {
+			return nil, &LimitExceededError{
+				Limit:   LimitRangeIterations,
+				Message: fmt.Sprintf("range iterations exceed the limit of %d", limits.MaxRangeIterations),
+			}
+		}
+
+		return collection, nil
+	}
+}
+
+// Builtin functions of go templates that build strings, which are wrapped along with the functions of the function map
+var stringBuiltins = template.FuncMap{
+	"html":     template.HTMLEscaper,
+	"js":       template.JSEscaper,
+	"print":    fmt.Sprint,
+	"printf":   fmt.Sprintf,
+	"println":  fmt.Sprintln,
+	"urlquery": template.URLQueryEscaper,
+}
+
+// Wraps the functions of a go template, along with the builtins that build strings, so that they fail once the
+// template is cancelled or a string they return exceeds the output limit. Strings built in variables, like
+// '{{ $text = print $text $text }}', are otherwise not limited, as the output limit only applies when they are written
+func (limits Limits) limitFunctions(ctx context.Context, functions template.FuncMap) template.FuncMap {
+	limitedFunctions := template.FuncMap{}
+	for name, function := range stringBuiltins {
+		limitedFunctions[name] = limits.limitFunction(ctx, function)
+	}
+	for name, function := range functions {
+		limitedFunctions[name] = limits.limitFunction(ctx, function)
+	}
+
+	return limitedFunctions
+}
+
+// Returns a function of the same type that fails when the template is cancelled before the function is called,
+// or when the function returns a string above the output limit
+func (limits Limits) limitFunction(ctx context.Context, function interface{}) interface{} {
+	functionValue := reflect.ValueOf(function)
+	functionType := functionValue.Type()
+	if functionType.Kind() != reflect.Func || functionType.NumOut() == 0 {
+		return function
+	}
+
+	// Functions without an error result fail by panicking, which the template engine reports as an error
+	fail := func(err error) []reflect.Value {
+		if functionType.NumOut() == 1 {
+			panic(err)
+		}
+		return []reflect.Value{reflect.Zero(functionType.Out(0)), reflect.ValueOf(&err).Elem()}
+	}
+
+	return reflect.MakeFunc(functionType, func(arguments []reflect.Value) []reflect.Value {
+		if err := ctx.Err(); err != nil {
+			return fail(limits.contextError(err))
+		}
+
+		var results []reflect.Value
+		if functionType.IsVariadic() {
+			results = functionValue.CallSlice(arguments)
+		} else {
+			results = functionValue.Call(arguments)
+		}
+
+		result := results[0]
+		if result.Kind() == reflect.Interface && !result.IsNil() {
+			result = result.Elem()
+		}
+		if limits.MaxOutputBytes > 0 && result.Kind() == reflect.String && result.Len() > limits.MaxOutputBytes {
+			return fail(limits.valueError())
+		}
+
+		return results
+	}).Interface()
+}
+
+func (limits Limits) valueError() error {
+	return &LimitExceededError{
+		Limit:   LimitOutputBytes,
+		Message: fmt.Sprintf("template value exceeds the limit of %d bytes", limits.MaxOutputBytes),
+	}
+}
+
+// Converts the error of a finished context into a limit exceeded error, if the deadline was exceeded
+func (limits Limits) contextError(err error) error {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	message := "template processing exceeded the deadline"
+	if limits.Timeout > 0 {
+		message = fmt.Sprintf("template processing exceeded the timeout of %s", limits.Timeout)
+	}
+
+	return &LimitExceededError{Limit: LimitTimeout, Message: message, Cause: err}
+}
+
+// Returns an error if the processed template exceeds the output limit
+func (limits Limits) checkOutput(output []byte) error {
+	if limits.MaxOutputBytes > 0 && len(output) > limits.MaxOutputBytes {
+		return limits.outputError()
+	}
+
+	return nil
+}
+
+func (limits Limits) outputError() error {
+	return &LimitExceededError{
+		Limit:   LimitOutputBytes,
+		Message: fmt.Sprintf("template output exceeds the limit of %d bytes", limits.MaxOutputBytes),
+	}
+}
+
+// Pipes the collection of each 'range' action in the template into the range limit function
+func limitRanges(node parse.Node) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for _, child := range typedNode.Nodes {
+			limitRanges(child)
+		}
+	case *parse.IfNode:
+		limitRanges(typedNode.List)
+		limitRanges(typedNode.ElseList)
+	case *parse.WithNode:
+		limitRanges(typedNode.List)
+		limitRanges(typedNode.ElseList)
+	case *parse.RangeNode:
+		typedNode.Pipe.Cmds = append(typedNode.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      typedNode.Pipe.Position(),
+			Args:     []parse.Node{parse.NewIdentifier(rangeLimitFunction).SetPos(typedNode.Pipe.Position())},
+		})
+		limitRanges(typedNode.List)
+		limitRanges(typedNode.ElseList)
+	}
+}
+
+// Writer that fails once the output exceeds the limit or the template is cancelled
+type limitedWriter struct {
+	ctx       context.Context
+	writer    io.Writer
+	limits    Limits
+	remaining int
+}
+
+func (writer *limitedWriter) Write(content []byte) (int, error) {
+	if err := writer.ctx.Err(); err != nil {
+		return 0, writer.limits.contextError(err)
+	}
+
+	if writer.limits.MaxOutputBytes > 0 {
+		if len(content) > writer.remaining {
+			return 0, writer.limits.outputError()
+		}
+		writer.remaining -= len(content)
+	}
+
+	return writer.writer.Write(content)
+}
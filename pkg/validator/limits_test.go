@@ -0,0 +1,188 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWithLimits(test *testing.T) {
+	cases := []struct {
+		template      string
+		templateType  string
+		limits        Limits
+		expected      string
+		expectedLimit string
+	}{
+		{
+			"{{ range .items }}{{ range $.items }}- {{ . }}\n{{ end }}{{ end }}",
+			"",
+			Limits{MaxRangeIterations: 5},
+			"range iterations exceed the limit of 5",
+			LimitRangeIterations,
+		},
+		{
+			"{{ define \"item\" }}{{ range until 10 }}- {{ . }}\n{{ end }}{{ end }}items:\n{{ template \"item\" }}",
+			"",
+			Limits{MaxRangeIterations: 5},
+			"range iterations exceed the limit of 5",
+			LimitRangeIterations,
+		},
+		{
+			"name: {{ .name }}\nitems: {{ range .items }}{{ .items }}{{ end }}",
+			"",
+			Limits{MaxOutputBytes: 20},
+			"template output exceeds the limit of 20 bytes",
+			LimitOutputBytes,
+		},
+		{
+			// Strings built in variables are limited before they are written
+			"{{ $text := \"ab\" }}{{ range until 5 }}{{ $text = print $text $text }}{{ end }}{{ $text | len }}",
+			"",
+			Limits{MaxOutputBytes: 20},
+			"template value exceeds the limit of 20 bytes",
+			LimitOutputBytes,
+		},
+		{
+			"{{ range until 2000 }}{{ range until 2000 }}{{ range until 2000 }}{{ end }}{{ end }}{{ end }}steps: []",
+			"",
+			Limits{Timeout: 10 * time.Millisecond},
+			"template processing exceeded the timeout of 10ms",
+			LimitTimeout,
+		},
+		{
+			"def main(ctx):\n  total = 0\n  for index in range(1000000):\n    total += index\n  return {'total': total}",
+			"starlark",
+			Limits{MaxStarlarkSteps: 1000},
+			"starlark execution exceeded the limit of 1000 steps",
+			LimitStarlarkSteps,
+		},
+		{
+			"def main(ctx):\n  total = 0\n  for index in range(100000000):\n    total += index\n  return {'total': total}",
+			"starlark",
+			Limits{Timeout: 10 * time.Millisecond},
+			"template processing exceeded the timeout of 10ms",
+			LimitTimeout,
+		},
+		{
+			"def main(ctx):\n  return {'steps': [{'name': 'step%d' % index} for index in range(10)]}",
+			"starlark",
+			Limits{MaxOutputBytes: 50},
+			"template output exceeds the limit of 50 bytes",
+			LimitOutputBytes,
+		},
+	}
+
+	for _, data := range cases {
+		actual := Validate(context.Background(), ValidationRequest{
+			Template: data.template,
+			Type:     data.templateType,
+			Limits:   data.limits,
+			Parameters: map[string]interface{}{
+				"name": "build",
+				"items": []interface{}{
+					map[string]interface{}{"items": []interface{}{1, 2}},
+					map[string]interface{}{"items": []interface{}{3, 4}},
+				},
+			},
+		})
+
+		assert.Equal(test, "Invalid template", actual.Message)
+		assert.Equal(test, data.expected, actual.Error)
+		assert.Equal(test, data.expectedLimit, actual.LimitExceeded)
+	}
+}
+
+func TestValidateWithinLimits(test *testing.T) {
+	actual := Validate(context.Background(), ValidationRequest{
+		Template:   "name: {{ .name | quote }}\nitems:\n{{- range $index, $item := .items }}\n  - {{ $item }}\n{{- end }}",
+		Limits:     DefaultLimits(),
+		Parameters: map[string]interface{}{"name": "build", "items": []interface{}{"one", "two"}},
+	})
+
+	assert.Equal(test, "template is a valid yaml", actual.Message)
+	assert.Equal(test, "name: \"build\"\nitems:\n  - one\n  - two", actual.Template)
+}
+
+func TestValidateCancelled(test *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		template     string
+		templateType string
+	}{
+		{"steps: []", ""},
+		{"def main(ctx):\n  return {'steps': []}", "starlark"},
+	}
+
+	for _, data := range cases {
+		actual := Validate(ctx, ValidationRequest{
+			Template: data.template,
+			Type:     data.templateType,
+		})
+
+		assert.Equal(test, "Invalid template", actual.Message)
+		assert.Contains(test, actual.Error, "context canceled")
+		assert.Equal(test, "", actual.LimitExceeded)
+	}
+}
+
+func TestValidateWithRangeLimitDiagnostics(test *testing.T) {
+	actual := Validate(context.Background(), ValidationRequest{
+		Template:   "steps:\n{{ range .items }}{{ range $.items }}- {{ . }}\n{{ end }}{{ end }}",
+		Limits:     Limits{MaxRangeIterations: 2},
+		Parameters: map[string]interface{}{"items": []interface{}{1, 2}},
+	})
+
+	assert.Equal(test, "range iterations exceed the limit of 2", actual.Error)
+	assert.Equal(test, []TemplateDiagnostic{
+		{
+			Stage:   StageExecute,
+			Line:    2,
+			Column:  28,
+			Message: "range iterations exceed the limit of 2",
+			Excerpt: "   2 | {{ range .items }}{{ range $.items }}- {{ . }}\n     |                            ^",
+		},
+	}, actual.Diagnostics)
+}
+
+func TestLimitFunctionsCancelled(test *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	functions := Limits{}.limitFunctions(ctx, template.FuncMap{
+		"upper": strings.ToUpper,
+		"check": func(text string) (string, error) { return text, nil },
+	})
+
+	assert.Equal(test, "BUILD", functions["upper"].(func(string) string)("build"))
+	cancel()
+
+	assert.PanicsWithError(test, "context canceled", func() {
+		functions["upper"].(func(string) string)("build")
+	})
+	_, err := functions["check"].(func(string) (string, error))("build")
+	assert.Equal(test, context.Canceled, err)
+}
+
+func TestLimitExceededError(test *testing.T) {
+	cause := errors.New("Starlark computation cancelled: too many steps")
+	var err error = &LimitExceededError{
+		Limit:   LimitStarlarkSteps,
+		Message: "starlark execution exceeded the limit of 10 steps",
+		Cause:   cause,
+	}
+
+	var limitError *LimitExceededError
+	assert.True(test, errors.As(err, &limitError))
+	assert.Equal(test, LimitStarlarkSteps, limitError.Limit)
+	assert.Equal(test, "starlark execution exceeded the limit of 10 steps", err.Error())
+	assert.True(test, errors.Is(err, cause))
+}
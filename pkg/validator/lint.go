@@ -0,0 +1,350 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Severities of lint rules, from the highest to the lowest. Rules with the severity 'off' are not applied
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+	SeverityOff     = "off"
+)
+
+// Names of the default lint rules
+const (
+	RuleLatestTag         = "latest-tag"
+	RulePullAlways        = "pull-always"
+	RuleMissingRuleset    = "missing-ruleset"
+	RuleUndeclaredSecret  = "undeclared-secret"
+	RuleDuplicateStepName = "duplicate-step-name"
+)
+
+var severities = []string{SeverityError, SeverityWarning, SeverityInfo, SeverityOff}
+
+// A finding of a lint rule in a processed template, along with the yaml path at which it occurred
+type LintFinding struct {
+	Rule     string `yaml:"rule" json:"rule"`
+	Severity string `yaml:"severity" json:"severity"`
+	Path     string `yaml:"path" json:"path"`
+	Message  string `yaml:"message" json:"message"`
+}
+
+func (finding LintFinding) String() string {
+	location := ""
+	if finding.Path != "" {
+		location = finding.Path + ": "
+	}
+
+	return fmt.Sprintf("%s: %s%s (%s)", finding.Severity, location, finding.Message, finding.Rule)
+}
+
+// A rule that checks a processed template that is a valid yaml. The severity of the findings is set from the rule
+type LintRule struct {
+	Name        string
+	Description string
+	Severity    string // Default severity of the rule
+	Check       func(pipeline map[interface{}]interface{}) []LintFinding
+}
+
+// Lint rules to apply to a processed template
+type LintConfig struct {
+	Severities map[string]string `yaml:"severities,omitempty"` // Severities of rules by name, overriding the defaults. 'off' disables a rule
+	Rules      []LintRule        `yaml:"-"`                    // Rules in addition to the default rules, which replace default rules of the same name
+}
+
+// Returns the rules applied to every processed template that is linted
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		{
+			Name:        RuleLatestTag,
+			Description: "Images without a tag or with the 'latest' tag",
+			Severity:    SeverityWarning,
+			Check:       checkLatestTags,
+		},
+		{
+			Name:        RulePullAlways,
+			Description: "Images with a mutable tag, like 'latest' or 'edge', that are not pulled always",
+			Severity:    SeverityWarning,
+			Check:       checkPullPolicies,
+		},
+		{
+			Name:        RuleMissingRuleset,
+			Description: "Steps without a ruleset, which run for every build",
+			Severity:    SeverityInfo,
+			Check:       checkRulesets,
+		},
+		{
+			Name:        RuleUndeclaredSecret,
+			Description: "Secrets used by steps that the pipeline does not declare. Not applied to templates, whose secrets are declared by the pipelines using them",
+			Severity:    SeverityWarning,
+			Check:       checkSecrets,
+		},
+		{
+			Name:        RuleDuplicateStepName,
+			Description: "Steps with the same name as another step of the pipeline or stage",
+			Severity:    SeverityError,
+			Check:       checkStepNames,
+		},
+	}
+}
+
+// Returns the rules of the configuration, with their configured severities
+func (config *LintConfig) rules() ([]LintRule, error) {
+	rules := DefaultLintRules()
+	for _, customRule := range config.Rules {
+		index := indexOfRule(rules, customRule.Name)
+		if index < 0 {
+			rules = append(rules, customRule)
+		} else {
+			rules[index] = customRule
+		}
+	}
+
+	for _, name := range sortedStringKeys(config.Severities) {
+		index := indexOfRule(rules, name)
+		if index < 0 {
+			return nil, fmt.Errorf("unknown lint rule '%s'", name)
+		}
+
+		severity := config.Severities[name]
+		if !contains(severities, severity) {
+			return nil, fmt.Errorf("invalid severity '%s' of lint rule '%s', expected one of %s", severity, name, strings.Join(severities, ", "))
+		}
+		rules[index].Severity = severity
+	}
+
+	return rules, nil
+}
+
+func indexOfRule(rules []LintRule, name string) int {
+	for index, rule := range rules {
+		if rule.Name == name {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Applies the configured lint rules to a processed template and returns the findings of each rule in turn
+func LintPipeline(pipeline interface{}, config LintConfig) ([]LintFinding, error) {
+	rules, err := config.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	pipelineMap, ok := pipeline.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	findings := []LintFinding{}
+	for _, rule := range rules {
+		if rule.Severity == SeverityOff || rule.Check == nil {
+			continue
+		}
+
+		for _, finding := range rule.Check(pipelineMap) {
+			finding.Rule = rule.Name
+			finding.Severity = rule.Severity
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings, nil
+}
+
+// Indicates if a severity is the same as or higher than another. Nothing is at or above 'off'
+func SeverityAtLeast(severity string, threshold string) bool {
+	if threshold == SeverityOff || severity == SeverityOff {
+		return false
+	}
+
+	severityIndex, thresholdIndex := slices.Index(severities, severity), slices.Index(severities, threshold)
+	return severityIndex >= 0 && thresholdIndex >= 0 && severityIndex <= thresholdIndex
+}
+
+// A map within the pipeline, along with its yaml path
+type pipelineEntry struct {
+	path  string
+	value map[interface{}]interface{}
+}
+
+// Returns the lists of steps of a pipeline, the top level steps and the steps of each stage, with their paths
+func stepLists(pipeline map[interface{}]interface{}) [][]pipelineEntry {
+	stepLists := [][]pipelineEntry{entries(pipeline["steps"], "steps")}
+	if stages, ok := pipeline["stages"].(map[interface{}]interface{}); ok {
+		for _, stageName := range sortedKeys(stages) {
+			if stage, ok := stages[stageName].(map[interface{}]interface{}); ok {
+				stepLists = append(stepLists, entries(stage["steps"], joinPath(joinPath("stages", stageName), "steps")))
+			}
+		}
+	}
+
+	return stepLists
+}
+
+// Returns the steps of a pipeline, excluding those that reference templates
+func steps(pipeline map[interface{}]interface{}) []pipelineEntry {
+	steps := []pipelineEntry{}
+	for _, stepList := range stepLists(pipeline) {
+		for _, step := range stepList {
+			if step.value["template"] == nil {
+				steps = append(steps, step)
+			}
+		}
+	}
+
+	return steps
+}
+
+// Returns the steps and the services of a pipeline, which run images
+func containers(pipeline map[interface{}]interface{}) []pipelineEntry {
+	return append(steps(pipeline), entries(pipeline["services"], "services")...)
+}
+
+// Returns the maps in a list, along with their paths
+func entries(list interface{}, path string) []pipelineEntry {
+	items, _ := list.([]interface{})
+
+	entries := []pipelineEntry{}
+	for index, item := range items {
+		if itemMap, ok := item.(map[interface{}]interface{}); ok {
+			entries = append(entries, pipelineEntry{fmt.Sprintf("%s[%d]", path, index), itemMap})
+		}
+	}
+
+	return entries
+}
+
+// Matches images like 'registry:5000/org/image:tag@sha256:digest'
+var imageRegex = regexp.MustCompile(`^(.*?)(?::([\w][\w.-]*))?(@[\w+.-]+:[0-9a-fA-F]+)?$`)
+
+// Returns the tag of an image and whether it is pinned to a digest
+func imageTag(image string) (string, bool) {
+	matches := imageRegex.FindStringSubmatch(image)
+	if matches == nil {
+		return "", false
+	}
+
+	return matches[2], matches[3] != ""
+}
+
+// Indicates if the tag of an image can point to different images over time. Tags without a digit,
+// like 'latest', 'edge' or 'alpine' are considered mutable, unless the image is pinned to a digest
+func mutableTag(image string) bool {
+	tag, pinned := imageTag(image)
+	return !pinned && !strings.ContainsAny(tag, "0123456789")
+}
+
+func checkLatestTags(pipeline map[interface{}]interface{}) []LintFinding {
+	findings := []LintFinding{}
+	for _, container := range containers(pipeline) {
+		image, ok := container.value["image"].(string)
+		if !ok {
+			continue
+		}
+
+		tag, pinned := imageTag(image)
+		if pinned {
+			continue
+		}
+
+		if tag == "" {
+			findings = append(findings, LintFinding{Path: joinPath(container.path, "image"), Message: fmt.Sprintf("image '%s' has no tag", image)})
+		} else if tag == "latest" {
+			findings = append(findings, LintFinding{Path: joinPath(container.path, "image"), Message: fmt.Sprintf("image '%s' uses the latest tag", image)})
+		}
+	}
+
+	return findings
+}
+
+func checkPullPolicies(pipeline map[interface{}]interface{}) []LintFinding {
+	findings := []LintFinding{}
+	for _, container := range containers(pipeline) {
+		image, ok := container.value["image"].(string)
+		if !ok || !mutableTag(image) || container.value["pull"] == "always" {
+			continue
+		}
+
+		findings = append(findings, LintFinding{
+			Path:    container.path,
+			Message: fmt.Sprintf("image '%s' has a mutable tag, but 'pull' is not 'always'", image),
+		})
+	}
+
+	return findings
+}
+
+func checkRulesets(pipeline map[interface{}]interface{}) []LintFinding {
+	findings := []LintFinding{}
+	for _, step := range steps(pipeline) {
+		if step.value["ruleset"] == nil {
+			findings = append(findings, LintFinding{Path: step.path, Message: fmt.Sprintf("step '%v' has no ruleset", step.value["name"])})
+		}
+	}
+
+	return findings
+}
+
+func checkSecrets(pipeline map[interface{}]interface{}) []LintFinding {
+	if metadata, ok := pipeline["metadata"].(map[interface{}]interface{}); ok && metadata["template"] == true {
+		return nil
+	}
+
+	declaredSecrets := []string{}
+	for _, secret := range entries(pipeline["secrets"], "secrets") {
+		declaredSecrets = append(declaredSecrets, fmt.Sprint(secret.value["name"]))
+	}
+
+	findings := []LintFinding{}
+	for _, step := range steps(pipeline) {
+		secrets, _ := step.value["secrets"].([]interface{})
+		for index, secret := range secrets {
+			// Secrets are referenced by name or as a map with the name in 'source'
+			name := fmt.Sprint(secret)
+			if secretMap, ok := secret.(map[interface{}]interface{}); ok {
+				name = fmt.Sprint(secretMap["source"])
+			}
+
+			if !contains(declaredSecrets, name) {
+				findings = append(findings, LintFinding{
+					Path:    fmt.Sprintf("%s[%d]", joinPath(step.path, "secrets"), index),
+					Message: fmt.Sprintf("secret '%s' is not declared in the pipeline", name),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+func checkStepNames(pipeline map[interface{}]interface{}) []LintFinding {
+	findings := []LintFinding{}
+	for _, stepList := range stepLists(pipeline) {
+		pathsByName := map[string]string{}
+		for _, step := range stepList {
+			name, ok := step.value["name"].(string)
+			if !ok {
+				continue
+			}
+
+			if path, exists := pathsByName[name]; exists {
+				findings = append(findings, LintFinding{
+					Path:    joinPath(step.path, "name"),
+					Message: fmt.Sprintf("step name '%s' is already used by %s", name, path),
+				})
+			} else {
+				pathsByName[name] = step.path
+			}
+		}
+	}
+
+	return findings
+}
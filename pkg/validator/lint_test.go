@@ -0,0 +1,220 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLintPipeline(test *testing.T) {
+	cases := []struct {
+		pipeline string
+		config   LintConfig
+		expected []LintFinding
+	}{
+		{
+			`
+steps:
+  - name: build
+    image: golang
+    ruleset:
+      event: push
+    commands: [ go build ]
+  - name: test
+    image: golang:latest
+    pull: always
+    ruleset:
+      event: push
+    commands: [ go test ]
+  - name: publish
+    image: registry:5000/plugins/docker:edge
+    ruleset:
+      event: tag
+    secrets: [ docker_username, { source: docker_token, target: docker_password } ]
+services:
+  - name: postgres
+    image: postgres:16@sha256:4aea012537edfad80f98d870a36e6b90b4c09b27be7f4b4759d72db863baeebb
+secrets:
+  - name: docker_username
+    key: org/docker_username
+`,
+			LintConfig{},
+			[]LintFinding{
+				{RuleLatestTag, SeverityWarning, "steps[0].image", "image 'golang' has no tag"},
+				{RuleLatestTag, SeverityWarning, "steps[1].image", "image 'golang:latest' uses the latest tag"},
+				{RulePullAlways, SeverityWarning, "steps[0]", "image 'golang' has a mutable tag, but 'pull' is not 'always'"},
+				{RulePullAlways, SeverityWarning, "steps[2]", "image 'registry:5000/plugins/docker:edge' has a mutable tag, but 'pull' is not 'always'"},
+				{RuleUndeclaredSecret, SeverityWarning, "steps[2].secrets[1]", "secret 'docker_token' is not declared in the pipeline"},
+			},
+		},
+		{
+			`
+stages:
+  test:
+    steps:
+      - name: test
+        image: golang:1.23
+        commands: [ go test ]
+      - name: test
+        image: golang:1.23
+        commands: [ go vet ]
+      - name: notify
+        template:
+          name: slack
+steps: []
+`,
+			LintConfig{Severities: map[string]string{RuleMissingRuleset: SeverityWarning, RuleDuplicateStepName: SeverityOff}},
+			[]LintFinding{
+				{RuleMissingRuleset, SeverityWarning, "stages.test.steps[0]", "step 'test' has no ruleset"},
+				{RuleMissingRuleset, SeverityWarning, "stages.test.steps[1]", "step 'test' has no ruleset"},
+			},
+		},
+		{
+			`
+metadata:
+  template: true
+steps:
+  - name: notify
+    image: devatherock/simple-slack:0.2.0
+    ruleset:
+      event: push
+    secrets: [ slack_webhook ]
+  - name: notify
+    image: devatherock/simple-slack:0.2.0
+    ruleset:
+      event: tag
+    secrets: [ slack_webhook ]
+`,
+			LintConfig{},
+			[]LintFinding{
+				{RuleDuplicateStepName, SeverityError, "steps[1].name", "step name 'notify' is already used by steps[0]"},
+			},
+		},
+		{
+			`
+steps:
+  - name: build
+    image: golang:1.23
+    commands: [ go build ]
+`,
+			LintConfig{
+				Severities: map[string]string{RuleMissingRuleset: SeverityOff, "step-count": SeverityError},
+				Rules: []LintRule{{
+					Name:     "step-count",
+					Severity: SeverityInfo,
+					Check: func(pipeline map[interface{}]interface{}) []LintFinding {
+						return []LintFinding{{Path: "steps", Message: "pipeline has 1 step"}}
+					},
+				}},
+			},
+			[]LintFinding{
+				{"step-count", SeverityError, "steps", "pipeline has 1 step"},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		pipeline := make(map[interface{}]interface{})
+		yaml.Unmarshal([]byte(data.pipeline), &pipeline)
+
+		actual, err := LintPipeline(pipeline, data.config)
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual, data.pipeline)
+	}
+}
+
+func TestLintPipelineInvalidConfig(test *testing.T) {
+	cases := []struct {
+		config   LintConfig
+		expected string
+	}{
+		{
+			LintConfig{Severities: map[string]string{"latest": SeverityError}},
+			"unknown lint rule 'latest'",
+		},
+		{
+			LintConfig{Severities: map[string]string{RuleLatestTag: "fatal"}},
+			"invalid severity 'fatal' of lint rule 'latest-tag', expected one of error, warning, info, off",
+		},
+	}
+
+	for _, data := range cases {
+		actual, err := LintPipeline(map[interface{}]interface{}{}, data.config)
+
+		assert.Nil(test, actual)
+		assert.EqualError(test, err, data.expected)
+	}
+}
+
+func TestImageTag(test *testing.T) {
+	cases := []struct {
+		image           string
+		expectedTag     string
+		expectedPinned  bool
+		expectedMutable bool
+	}{
+		{"alpine", "", false, true},
+		{"alpine:3.20", "3.20", false, false},
+		{"node:lts-alpine", "lts-alpine", false, true},
+		{"registry:5000/org/image", "", false, true},
+		{"registry:5000/org/image:v1", "v1", false, false},
+		{"alpine@sha256:0a4eaa0eecf5f8c050e5bba433f58c052be7587ee8af3e8b3910ef9ab5fbe9f5", "", true, false},
+		{"alpine:latest@sha256:0a4eaa0eecf5f8c050e5bba433f58c052be7587ee8af3e8b3910ef9ab5fbe9f5", "latest", true, false},
+	}
+
+	for _, data := range cases {
+		tag, pinned := imageTag(data.image)
+
+		assert.Equal(test, data.expectedTag, tag, data.image)
+		assert.Equal(test, data.expectedPinned, pinned, data.image)
+		assert.Equal(test, data.expectedMutable, mutableTag(data.image), data.image)
+	}
+}
+
+func TestSeverityAtLeast(test *testing.T) {
+	assert.True(test, SeverityAtLeast(SeverityError, SeverityWarning))
+	assert.True(test, SeverityAtLeast(SeverityWarning, SeverityWarning))
+	assert.False(test, SeverityAtLeast(SeverityInfo, SeverityWarning))
+	assert.False(test, SeverityAtLeast(SeverityError, SeverityOff))
+	assert.False(test, SeverityAtLeast(SeverityOff, SeverityInfo))
+	assert.False(test, SeverityAtLeast(SeverityError, "fatal"))
+}
+
+func TestValidateWithLint(test *testing.T) {
+	template := "steps:\n  - name: build\n    image: golang:{{ .version }}\n    ruleset:\n      event: push\n    commands: [ go build ]"
+
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template:   template,
+		Parameters: map[string]interface{}{"version": "latest"},
+		Lint:       &LintConfig{Severities: map[string]string{RulePullAlways: SeverityOff}},
+	})
+	assert.Equal(test, ValidationResponse{
+		Message:      "template is a valid yaml",
+		Template:     "steps:\n  - name: build\n    image: golang:latest\n    ruleset:\n      event: push\n    commands: [ go build ]",
+		SchemaErrors: []SchemaViolation{},
+		Warnings:     []LintFinding{{RuleLatestTag, SeverityWarning, "steps[0].image", "image 'golang:latest' uses the latest tag"}},
+	}, validationResponse)
+
+	validationResponse = Validate(context.Background(), ValidationRequest{
+		Template:   template,
+		Parameters: map[string]interface{}{"version": "1.23"},
+		Lint:       &LintConfig{},
+	})
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Equal(test, []LintFinding{}, validationResponse.Warnings)
+
+	validationResponse = Validate(context.Background(), ValidationRequest{
+		Template: template,
+		Lint:     &LintConfig{Severities: map[string]string{"latest": SeverityError}},
+	})
+	assert.Equal(test, ValidationResponse{
+		Message:     "Invalid lint configuration",
+		Error:       "unknown lint rule 'latest'",
+		FailedStage: StageLint,
+	}, validationResponse)
+}
@@ -0,0 +1,154 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A map that keeps the order of its keys when marshalled into yaml or json, like the dicts returned by
+// starlark templates or the maps of a parsed yaml document
+type OrderedMap yaml.MapSlice
+
+func (entries OrderedMap) MarshalYAML() (interface{}, error) {
+	return yaml.MapSlice(entries), nil
+}
+
+func (entries OrderedMap) MarshalJSON() ([]byte, error) {
+	output := bytes.Buffer{}
+	output.WriteByte('{')
+	for index, entry := range entries {
+		if index > 0 {
+			output.WriteByte(',')
+		}
+
+		key, err := json.Marshal(fmt.Sprint(entry.Key))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		output.Write(key)
+		output.WriteByte(':')
+		output.Write(value)
+	}
+	output.WriteByte('}')
+
+	return output.Bytes(), nil
+}
+
+// Parses a yaml document into values whose maps are ordered maps, in the order of the document
+func ParseOrderedYaml(document string) (interface{}, error) {
+	value := orderedValue{}
+	err := yaml.Unmarshal([]byte(document), &value)
+
+	return value.value, err
+}
+
+// A yaml value of any kind whose maps keep the order of their keys
+type orderedValue struct {
+	value interface{}
+}
+
+func (value *orderedValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var parsed interface{}
+	if err := unmarshal(&parsed); err != nil {
+		return err
+	}
+
+	switch typedParsed := parsed.(type) {
+	case map[interface{}]interface{}:
+		entries := map[interface{}]orderedValue{}
+		if err := unmarshal(&entries); err != nil {
+			return err
+		}
+
+		// yaml.MapSlice has the order of the keys, but not the keys merged with '<<', which follow the other keys
+		keys := yaml.MapSlice{}
+		if err := unmarshal(&keys); err != nil {
+			return err
+		}
+		ordered := make(OrderedMap, 0, len(entries))
+		for _, key := range keys {
+			if entry, ok := entries[key.Key]; ok {
+				ordered = append(ordered, yaml.MapItem{Key: key.Key, Value: entry.value})
+				delete(entries, key.Key)
+			}
+		}
+		for _, key := range sortedKeys(typedParsed) {
+			if entry, ok := entries[key]; ok {
+				ordered = append(ordered, yaml.MapItem{Key: key, Value: entry.value})
+			}
+		}
+		value.value = ordered
+	case []interface{}:
+		items := []orderedValue{}
+		if err := unmarshal(&items); err != nil {
+			return err
+		}
+		values := make([]interface{}, len(items))
+		for index, item := range items {
+			values[index] = item.value
+		}
+		value.value = values
+	default:
+		value.value = parsed
+	}
+
+	return nil
+}
+
+// Returns the value of a key, or nil if the map doesn't have the key
+func (entries OrderedMap) get(key interface{}) interface{} {
+	if index := entries.indexOf(key); index != -1 {
+		return entries[index].Value
+	}
+
+	return nil
+}
+
+// Returns the index of a key, or -1 if the map doesn't have the key
+func (entries OrderedMap) indexOf(key interface{}) int {
+	for index, entry := range entries {
+		if entry.Key == key {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Returns the map with a key set to a value, in place of the existing value or at the end
+func (entries OrderedMap) set(key interface{}, value interface{}) OrderedMap {
+	if index := entries.indexOf(key); index != -1 {
+		entries[index].Value = value
+		return entries
+	}
+
+	return append(entries, yaml.MapItem{Key: key, Value: value})
+}
+
+// Converts the ordered maps of a value into the maps that yaml parses into, so that templates can access their keys
+func unorderedValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case OrderedMap:
+		converted := make(map[interface{}]interface{}, len(typedValue))
+		for _, entry := range typedValue {
+			converted[entry.Key] = unorderedValue(entry.Value)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(typedValue))
+		for index, item := range typedValue {
+			converted[index] = unorderedValue(item)
+		}
+		return converted
+	}
+
+	return value
+}
@@ -0,0 +1,89 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseOrderedYaml(test *testing.T) {
+	cases := []struct {
+		document string
+		expected interface{}
+	}{
+		{
+			"version: '1'\nsteps:\n  - name: build\n    image: golang:1.23\n    commands: [ go build ]\n",
+			OrderedMap{
+				{Key: "version", Value: "1"},
+				{Key: "steps", Value: []interface{}{OrderedMap{
+					{Key: "name", Value: "build"},
+					{Key: "image", Value: "golang:1.23"},
+					{Key: "commands", Value: []interface{}{"go build"}},
+				}}},
+			},
+		},
+		{
+			"- name: build\n  image: golang:1.23\n- [ 1, { b: 2, a: 3 } ]\n",
+			[]interface{}{
+				OrderedMap{{Key: "name", Value: "build"}, {Key: "image", Value: "golang:1.23"}},
+				[]interface{}{1, OrderedMap{{Key: "b", Value: 2}, {Key: "a", Value: 3}}},
+			},
+		},
+		{
+			// Merged keys follow the other keys, unless overridden
+			"base: &base\n  pull: always\n  image: alpine\nstep:\n  name: test\n  <<: *base\n  image: golang\nempty: {}\nnone:\n",
+			OrderedMap{
+				{Key: "base", Value: OrderedMap{{Key: "pull", Value: "always"}, {Key: "image", Value: "alpine"}}},
+				{Key: "step", Value: OrderedMap{{Key: "name", Value: "test"}, {Key: "image", Value: "golang"}, {Key: "pull", Value: "always"}}},
+				{Key: "empty", Value: OrderedMap{}},
+				{Key: "none", Value: nil},
+			},
+		},
+		{
+			"1.5",
+			1.5,
+		},
+		{
+			"",
+			nil,
+		},
+	}
+
+	for _, data := range cases {
+		actual, err := ParseOrderedYaml(data.document)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, actual, data.document)
+	}
+
+	_, err := ParseOrderedYaml("steps: [")
+	assert.NotNil(test, err)
+}
+
+func TestOrderedMapMarshal(test *testing.T) {
+	document := OrderedMap{
+		{Key: "version", Value: "1"},
+		{Key: "steps", Value: []interface{}{OrderedMap{{Key: "name", Value: "build"}, {Key: 1, Value: nil}}}},
+		{Key: "empty", Value: OrderedMap{}},
+	}
+
+	output, err := yaml.Marshal(document)
+	assert.Nil(test, err)
+	assert.Equal(test, "version: \"1\"\nsteps:\n- name: build\n  1: null\nempty: {}\n", string(output))
+
+	output, err = json.Marshal(document)
+	assert.Nil(test, err)
+	assert.Equal(test, `{"version":"1","steps":[{"name":"build","1":null}],"empty":{}}`, string(output))
+
+	output, err = json.MarshalIndent(map[string]interface{}{"document": OrderedMap{{Key: "b", Value: 1}, {Key: "a", Value: 2}}}, "", "  ")
+	assert.Nil(test, err)
+	assert.Equal(test, "{\n  \"document\": {\n    \"b\": 1,\n    \"a\": 2\n  }\n}", string(output))
+
+	_, err = json.Marshal(OrderedMap{{Key: "invalid", Value: make(chan int)}})
+	assert.NotNil(test, err)
+}
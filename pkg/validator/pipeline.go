@@ -0,0 +1,290 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A template declared in the 'templates' section of a vela pipeline
+type PipelineTemplate struct {
+	Name   string
+	Source string
+	Format string
+	Type   string
+}
+
+// Services, secrets and environment contributed by the expanded templates
+type templateAdditions struct {
+	Services    []interface{}
+	Secrets     []interface{}
+	Environment OrderedMap
+}
+
+// Reads the templates declared in a vela pipeline
+func ReadPipelineTemplates(pipeline string) ([]PipelineTemplate, error) {
+	declarations := struct {
+		Templates []PipelineTemplate
+	}{}
+	err := yaml.Unmarshal([]byte(pipeline), &declarations)
+
+	return declarations.Templates, err
+}
+
+// Expands the template references in a vela pipeline, the same way the vela compiler does. The order of
+// the keys of the pipeline and of the expanded templates is kept
+func expandPipeline(ctx context.Context, validationRequest *ValidationRequest, validationResponse *ValidationResponse) (string, error) {
+	parsedPipeline, err := ParseOrderedYaml(validationRequest.Template)
+	if err != nil {
+		return "", err
+	}
+
+	pipeline, ok := parsedPipeline.(OrderedMap)
+	if !ok && parsedPipeline != nil {
+		return "", fmt.Errorf("pipeline is not a yaml map")
+	}
+
+	declaredTemplates, err := ReadPipelineTemplates(validationRequest.Template)
+	if err != nil {
+		return "", err
+	}
+
+	templates := make(map[string]PipelineTemplate)
+	for _, declaredTemplate := range declaredTemplates {
+		templates[declaredTemplate.Name] = declaredTemplate
+	}
+
+	additions := &templateAdditions{}
+	expandedPipeline := OrderedMap{}
+	for _, item := range pipeline {
+		switch item.Key {
+		case "templates":
+			// Not part of the compiled pipeline
+			continue
+		case "steps":
+			item.Value, err = expandSteps(ctx, item.Value, templates, validationRequest, validationResponse, additions)
+		case "stages":
+			item.Value, err = expandStages(ctx, item.Value, templates, validationRequest, validationResponse, additions)
+		}
+
+		if err != nil {
+			return "", err
+		}
+		expandedPipeline = append(expandedPipeline, item)
+	}
+
+	expandedPipeline = spliceAdditions(expandedPipeline, additions)
+	output, err := yaml.Marshal(expandedPipeline)
+
+	return string(output), err
+}
+
+// Expands the template references in the steps of each stage
+func expandStages(ctx context.Context, stages interface{}, templates map[string]PipelineTemplate,
+	validationRequest *ValidationRequest, validationResponse *ValidationResponse, additions *templateAdditions) (interface{}, error) {
+	stageMap, ok := stages.(OrderedMap)
+	if !ok {
+		return stages, nil
+	}
+
+	for _, stageEntry := range stageMap {
+		stage, ok := stageEntry.Value.(OrderedMap)
+		if !ok || stage.indexOf("steps") == -1 {
+			continue
+		}
+
+		steps, err := expandSteps(ctx, stage.get("steps"), templates, validationRequest, validationResponse, additions)
+		if err != nil {
+			return nil, err
+		}
+		stage.set("steps", steps)
+	}
+
+	return stageMap, nil
+}
+
+// Replaces each step that references a template with the steps of the expanded template
+func expandSteps(ctx context.Context, steps interface{}, templates map[string]PipelineTemplate,
+	validationRequest *ValidationRequest, validationResponse *ValidationResponse, additions *templateAdditions) (interface{}, error) {
+	stepList, ok := steps.([]interface{})
+	if !ok {
+		return steps, nil
+	}
+
+	expandedSteps := []interface{}{}
+	for _, step := range stepList {
+		stepMap, ok := step.(OrderedMap)
+		if !ok || stepMap.get("template") == nil {
+			expandedSteps = append(expandedSteps, step)
+			continue
+		}
+
+		templateReference, _ := stepMap.get("template").(OrderedMap)
+		templateName := fmt.Sprint(templateReference.get("name"))
+		stepName := stepMap.get("name")
+		pipelineTemplate, ok := templates[templateName]
+		if !ok {
+			return nil, fmt.Errorf("template '%s' referenced by step '%v' is not declared", templateName, stepName)
+		}
+
+		content, ok := validationRequest.Templates[templateName]
+		if !ok {
+			return nil, fmt.Errorf("content of template '%s' from '%s' was not supplied", templateName, pipelineTemplate.Source)
+		}
+
+		parameters := unorderedValue(templateReference.get("vars"))
+		if variableSchema, ok := validationRequest.VariableSchemas[templateName]; ok {
+			variableErrors, err := ValidateVariables(variableSchema, parameters)
+			if err != nil {
+				return nil, fmt.Errorf("template '%s': %s", templateName, err.Error())
+			} else if len(variableErrors) > 0 {
+				return nil, fmt.Errorf("vars of step '%v' do not match the variable schema of template '%s': %s",
+					stepName, templateName, joinVariableErrors(variableErrors))
+			}
+			parameters = applyVariableDefaults(variableSchema, parameters)
+		}
+
+		templateRequest := &ValidationRequest{
+			Template:            content,
+			Parameters:          parameters,
+			Strict:              validationRequest.Strict,
+			VelaVersion:         validationRequest.VelaVersion,
+			BuildContext:        validationRequest.BuildContext,
+			Coverage:            validationRequest.Coverage,
+			DownstreamVariables: validationRequest.DownstreamVariables,
+			Sandbox:             validationRequest.Sandbox,
+			Limits:              validationRequest.Limits,
+		}
+		if pipelineTemplate.Format == "starlark" {
+			templateRequest.Type = "starlark"
+		}
+
+		expandedTemplate, err := expandTemplate(ctx, templateName, templateRequest, validationResponse)
+		if err != nil {
+			return nil, fmt.Errorf("unable to expand template '%s' for step '%v': %s", templateName, stepName, err.Error())
+		}
+
+		templateOutput, err := readTemplateOutput(expandedTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("template '%s' for step '%v' is not a valid yaml: %s", templateName, stepName, err.Error())
+		}
+
+		templateSteps, _ := templateOutput.get("steps").([]interface{})
+		for _, templateStep := range templateSteps {
+			if templateStepMap, ok := templateStep.(OrderedMap); ok && templateStepMap.get("template") != nil {
+				return nil, fmt.Errorf("step '%v' of template '%s' references a template, which is not supported within templates",
+					templateStepMap.get("name"), templateName)
+			}
+		}
+
+		expandedSteps = append(expandedSteps, templateSteps...)
+		templateServices, _ := templateOutput.get("services").([]interface{})
+		additions.Services = append(additions.Services, templateServices...)
+		templateSecrets, _ := templateOutput.get("secrets").([]interface{})
+		additions.Secrets = append(additions.Secrets, templateSecrets...)
+		templateEnvironment, _ := templateOutput.get("environment").(OrderedMap)
+		for _, entry := range templateEnvironment {
+			additions.Environment = additions.Environment.set(entry.Key, entry.Value)
+		}
+	}
+
+	return expandedSteps, nil
+}
+
+// Processes a template referenced by a pipeline. The branches, undefined variables and unescaped expressions
+// of the template are added to the response of the pipeline along with the name of the template
+func expandTemplate(ctx context.Context, templateName string, templateRequest *ValidationRequest, validationResponse *ValidationResponse) (string, error) {
+	templateResponse := &ValidationResponse{}
+	expandedTemplate, err := renderTemplate(ctx, templateRequest, templateResponse)
+	if err == nil && templateRequest.Strict {
+		checkUndefinedVariables(templateRequest, templateResponse)
+		if templateResponse.Error != "" {
+			err = errors.New(templateResponse.Error)
+		}
+	}
+
+	validationResponse.DebugLog = append(validationResponse.DebugLog, templateResponse.DebugLog...)
+	for _, branch := range templateResponse.Coverage {
+		branch.Template = templateName
+		validationResponse.Coverage = append(validationResponse.Coverage, branch)
+	}
+	for _, undefinedVariable := range templateResponse.UndefinedVariables {
+		undefinedVariable.Template = templateName
+		validationResponse.UndefinedVariables = append(validationResponse.UndefinedVariables, undefinedVariable)
+	}
+	for _, expression := range templateResponse.UnescapedExpressions {
+		expression.Template = templateName
+		validationResponse.UnescapedExpressions = append(validationResponse.UnescapedExpressions, expression)
+	}
+
+	return expandedTemplate, err
+}
+
+// Parses the output of a template referenced by a pipeline, whose steps, services and secrets are lists
+// and whose environment is a map
+func readTemplateOutput(expandedTemplate string) (OrderedMap, error) {
+	parsedOutput, err := ParseOrderedYaml(expandedTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	templateOutput, ok := parsedOutput.(OrderedMap)
+	if !ok && parsedOutput != nil {
+		return nil, fmt.Errorf("expected a map, found %s", kindOf(parsedOutput))
+	}
+
+	for _, key := range []string{"steps", "services", "secrets"} {
+		if value := templateOutput.get(key); value != nil {
+			if _, ok := value.([]interface{}); !ok {
+				return nil, fmt.Errorf("expected '%s' to be a list, found %s", key, kindOf(value))
+			}
+		}
+	}
+	if value := templateOutput.get("environment"); value != nil {
+		if _, ok := value.(OrderedMap); !ok {
+			return nil, fmt.Errorf("expected 'environment' to be a map, found %s", kindOf(value))
+		}
+	}
+
+	return templateOutput, nil
+}
+
+// Adds the services, secrets and environment of the expanded templates to the pipeline
+func spliceAdditions(pipeline OrderedMap, additions *templateAdditions) OrderedMap {
+	pipeline = appendToList(pipeline, "services", additions.Services)
+	pipeline = appendToList(pipeline, "secrets", additions.Secrets)
+
+	if len(additions.Environment) > 0 {
+		index := pipeline.indexOf("environment")
+		if index == -1 {
+			pipeline = append(pipeline, yaml.MapItem{Key: "environment", Value: additions.Environment})
+		} else if environment, ok := pipeline[index].Value.(OrderedMap); ok {
+			// Variables declared in the pipeline take precedence over those from templates
+			for _, entry := range additions.Environment {
+				if environment.indexOf(entry.Key) == -1 {
+					environment = append(environment, entry)
+				}
+			}
+			pipeline[index].Value = environment
+		}
+	}
+
+	return pipeline
+}
+
+func appendToList(pipeline OrderedMap, key string, values []interface{}) OrderedMap {
+	if len(values) == 0 {
+		return pipeline
+	}
+
+	index := pipeline.indexOf(key)
+	if index == -1 {
+		return append(pipeline, yaml.MapItem{Key: key, Value: values})
+	}
+
+	existingValues, _ := pipeline[index].Value.([]interface{})
+	pipeline[index].Value = append(existingValues, values...)
+	return pipeline
+}
@@ -0,0 +1,257 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidatePipelineWithTemplates(test *testing.T) {
+	validationRequest := ValidationRequest{}
+
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_pipeline.yml"))
+	validationRequest.Template = string(input)
+	validationRequest.Type = "pipeline"
+
+	goTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_template.yml"))
+	starlarkTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_starlark_template.py"))
+	validationRequest.Templates = map[string]string{
+		"notify": string(goTemplate),
+		"build":  string(starlarkTemplate),
+	}
+
+	validationResponse := Validate(context.Background(), validationRequest)
+	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Empty(test, validationResponse.SchemaErrors)
+
+	expectedOutput, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/output_pipeline.yml"))
+	expectedOutputMap := make(map[interface{}]interface{})
+	yaml.Unmarshal([]byte(expectedOutput), &expectedOutputMap)
+
+	processedTemplateMap := make(map[interface{}]interface{})
+	yaml.Unmarshal([]byte(validationResponse.Template), &processedTemplateMap)
+
+	assert.Equal(test, expectedOutputMap, processedTemplateMap)
+}
+
+func TestExpandPipelineStagesAndAdditions(test *testing.T) {
+	validationRequest := &ValidationRequest{
+		Template: `
+version: "1"
+templates:
+  - name: db
+    source: github.com/org/templates/db.yml
+environment:
+  REGION: us-east-1
+stages:
+  test:
+    steps:
+      - name: test
+        template:
+          name: db
+          vars:
+            image: postgres:15
+  deploy:
+    steps:
+      - name: deploy
+        image: alpine
+        commands: [ ./deploy.sh ]
+`,
+		Templates: map[string]string{
+			"db": `
+environment:
+  REGION: eu-west-1
+  DB_HOST: postgres
+services:
+  - name: postgres
+    image: {{ .image }}
+secrets:
+  - name: db_password
+    key: org/db_password
+steps:
+  - name: migrate
+    image: flyway/flyway
+    commands: [ flyway migrate ]
+`,
+		},
+	}
+
+	output, err := expandPipeline(context.Background(), validationRequest, &ValidationResponse{})
+	assert.Nil(test, err)
+	assert.Equal(test, `version: "1"
+environment:
+  REGION: us-east-1
+  DB_HOST: postgres
+stages:
+  test:
+    steps:
+    - name: migrate
+      image: flyway/flyway
+      commands:
+      - flyway migrate
+  deploy:
+    steps:
+    - name: deploy
+      image: alpine
+      commands:
+      - ./deploy.sh
+services:
+- name: postgres
+  image: postgres:15
+secrets:
+- name: db_password
+  key: org/db_password
+`, output)
+}
+
+func TestExpandPipelineError(test *testing.T) {
+	cases := []struct {
+		pipeline      string
+		templates     map[string]string
+		expectedError string
+	}{
+		{
+			`
+steps:
+  - name: build
+    template:
+      name: go
+`,
+			map[string]string{},
+			"template 'go' referenced by step 'build' is not declared",
+		},
+		{
+			`
+templates:
+  - name: go
+    source: templates/go.yml
+steps:
+  - name: build
+    template:
+      name: go
+`,
+			map[string]string{},
+			"content of template 'go' from 'templates/go.yml' was not supplied",
+		},
+		{
+			`
+templates:
+  - name: go
+    source: templates/go.yml
+steps:
+  - name: build
+    template:
+      name: go
+`,
+			map[string]string{
+				"go": "steps: [ {{ .image }}",
+			},
+			"template 'go' for step 'build' is not a valid yaml: yaml: line 1: did not find expected ',' or ']'",
+		},
+		{
+			`
+templates:
+  - name: go
+    source: templates/go.yml
+steps:
+  - name: build
+    template:
+      name: go
+`,
+			map[string]string{
+				"go": `steps: {{ vela "" }}`,
+			},
+			"unable to expand template 'go' for step 'build': template: test:1:10: executing \"test\" at <vela \"\">: " +
+				"error calling vela: environment variable name cannot be empty in 'vela' function",
+		},
+		{
+			`
+templates:
+  - name: go
+    source: templates/go.yml
+steps:
+  - name: build
+    template:
+      name: go
+`,
+			map[string]string{
+				"go": "steps:\n  - name: test\n    template:\n      name: test",
+			},
+			"step 'test' of template 'go' references a template, which is not supported within templates",
+		},
+		{
+			`
+templates:
+  - name: go
+    source: templates/go.yml
+steps:
+  - name: build
+    template:
+      name: go
+`,
+			map[string]string{
+				"go": "steps:\n  name: test",
+			},
+			"template 'go' for step 'build' is not a valid yaml: expected 'steps' to be a list, found map",
+		},
+	}
+
+	for _, data := range cases {
+		_, err := expandPipeline(context.Background(), &ValidationRequest{
+			Template:  data.pipeline,
+			Templates: data.templates,
+		}, &ValidationResponse{})
+
+		assert.Equal(test, data.expectedError, err.Error())
+	}
+}
+
+func TestExpandPipelineWithOptions(test *testing.T) {
+	validationRequest := &ValidationRequest{
+		Template: `
+templates:
+  - name: notify
+    source: templates/notify.yml
+steps:
+  - name: notify
+    template:
+      name: notify
+      vars:
+        channel: builds
+`,
+		Templates: map[string]string{
+			"notify": "steps:\n  - name: notify\n    image: {{ .image | default \"alpine\" }}\n" +
+				"    commands: [ \"echo {{ if .channel }}{{ .channel }}{{ end }} {{.BuildLink}}\" ]",
+		},
+		Coverage: true,
+	}
+
+	validationResponse := &ValidationResponse{}
+	_, err := expandPipeline(context.Background(), validationRequest, validationResponse)
+	assert.Nil(test, err)
+	assert.Equal(test, []BranchCoverage{
+		{Statement: StatementIf, Branch: BranchThen, Line: 4, Column: 29, Hits: 1, Template: "notify"},
+		{Statement: StatementIf, Branch: BranchElse, Line: 4, Column: 29, Hits: 0, Template: "notify"},
+	}, validationResponse.Coverage)
+	assert.Equal(test, []UnescapedExpression{
+		{Variable: "BuildLink", Expression: ".BuildLink", Lines: []int{4}, Suggestion: `{{"{{.BuildLink}}"}}`, Template: "notify"},
+	}, validationResponse.UnescapedExpressions)
+
+	// Strict mode applies to each template
+	validationRequest.Strict = true
+	validationResponse = &ValidationResponse{}
+	_, err = expandPipeline(context.Background(), validationRequest, validationResponse)
+	assert.Equal(test, "unable to expand template 'notify' for step 'notify': undefined variables: 'BuildLink' at line 4, column 66", err.Error())
+	assert.Equal(test, []UndefinedVariable{
+		{Name: "image", Line: 3, Column: 15, RescuedBy: "default", Template: "notify"},
+		{Name: "BuildLink", Line: 4, Column: 66, Template: "notify"},
+	}, validationResponse.UndefinedVariables)
+}
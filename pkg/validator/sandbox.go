@@ -0,0 +1,68 @@
+package validator
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/qri-io/starlib"
+	"go.starlark.net/starlark"
+)
+
+// Functions available to go templates and modules available to starlark templates from untrusted sources, like the requests to the API
+type Sandbox struct {
+	AllowedFunctions []string // Only these functions are available to templates, if specified
+	DeniedFunctions  []string // Functions that are not available to templates
+	AllowedModules   []string // Starlark modules that templates can load. None can be loaded if not specified
+}
+
+// Sprig functions that disclose the environment of the server, or whose arguments set the size of the strings and lists
+// they build, which are allocated before the limits apply. 'genPrivateKey' takes seconds of CPU for a single key
+var defaultDeniedFunctions = []string{
+	"env", "expandenv", "getHostByName", "repeat", "seq", "until", "untilStep", "indent", "nindent",
+	"randAlpha", "randAlphaNum", "randAscii", "randNumeric", "randBytes", "genPrivateKey",
+}
+
+// Starlark modules that don't access the network or the file system
+var defaultAllowedModules = []string{
+	"encoding/base64.star", "encoding/csv.star", "encoding/json.star", "encoding/yaml.star", "hash.star", "math.star", "re.star", "time.star",
+}
+
+// Returns a sandbox that denies access to the environment of the server
+func DefaultSandbox() *Sandbox {
+	return &Sandbox{
+		DeniedFunctions: defaultDeniedFunctions,
+		AllowedModules:  defaultAllowedModules,
+	}
+}
+
+// Indicates if a template is allowed to call a function
+func (sandbox *Sandbox) allows(function string) bool {
+	if len(sandbox.AllowedFunctions) > 0 && !contains(sandbox.AllowedFunctions, function) {
+		return false
+	}
+
+	return !contains(sandbox.DeniedFunctions, function)
+}
+
+// Removes the functions that are not allowed from a function map
+func (sandbox *Sandbox) restrictFunctions(functions template.FuncMap) template.FuncMap {
+	restrictedFunctions := template.FuncMap{}
+	for name, function := range functions {
+		if sandbox.allows(name) {
+			restrictedFunctions[name] = function
+		}
+	}
+
+	return restrictedFunctions
+}
+
+// Returns a starlark loader that loads only the allowed modules
+func (sandbox *Sandbox) loader() func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+	return func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+		if !contains(sandbox.AllowedModules, module) {
+			return nil, fmt.Errorf("module '%s' is not allowed", module)
+		}
+
+		return starlib.Loader(thread, module)
+	}
+}
@@ -0,0 +1,104 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSandboxed(test *testing.T) {
+	cases := []struct {
+		template string
+		sandbox  *Sandbox
+		expected string
+	}{
+		{
+			"home: {{ env \"HOME\" }}",
+			DefaultSandbox(),
+			"template: test:1: function \"env\" is not allowed",
+		},
+		{
+			"home: {{ expandenv \"$HOME\" | quote }}",
+			&Sandbox{AllowedFunctions: []string{"quote"}},
+			"template: test:1: function \"expandenv\" is not allowed",
+		},
+		{
+			"name: {{ .name | upper }}",
+			&Sandbox{AllowedFunctions: []string{"quote"}},
+			"template: test:1: function \"upper\" is not allowed",
+		},
+		{
+			"content: {{ repeat 1000000000 \"a\" }}",
+			DefaultSandbox(),
+			"template: test:1: function \"repeat\" is not allowed",
+		},
+		{
+			"content: {{ indent 200000000 \"a\" }}",
+			DefaultSandbox(),
+			"template: test:1: function \"indent\" is not allowed",
+		},
+		{
+			"content: {{ randAlphaNum 50000000 }}",
+			DefaultSandbox(),
+			"template: test:1: function \"randAlphaNum\" is not allowed",
+		},
+		{
+			"name: {{ .name | shout }}",
+			DefaultSandbox(),
+			"template: test:1: function \"shout\" not defined",
+		},
+	}
+
+	for _, data := range cases {
+		actual := Validate(context.Background(), ValidationRequest{
+			Template:   data.template,
+			Sandbox:    data.sandbox,
+			Parameters: map[string]interface{}{"name": "build"},
+		})
+
+		assert.Equal(test, "Invalid template", actual.Message)
+		assert.Equal(test, data.expected, actual.Error)
+	}
+}
+
+func TestValidateSandboxedWithAllowedFunctions(test *testing.T) {
+	actual := Validate(context.Background(), ValidationRequest{
+		Template:   "name: {{ .name | quote }}\nitems:\n{{- range $index, $item := .items }}\n  - {{ $item }}\n{{- end }}",
+		Sandbox:    DefaultSandbox(),
+		Parameters: map[string]interface{}{"name": "build", "items": []interface{}{"one", "two"}},
+	})
+
+	assert.Equal(test, "template is a valid yaml", actual.Message)
+	assert.Equal(test, "name: \"build\"\nitems:\n  - one\n  - two", actual.Template)
+}
+
+func TestValidateSandboxedStarlarkModules(test *testing.T) {
+	template := "load('encoding/json.star', 'json')\nload('%s', '%s')\n\ndef main(ctx):\n  return json.decode('{\"steps\": []}')"
+
+	actual := Validate(context.Background(), ValidationRequest{
+		Template: fmt.Sprintf(template, "math.star", "math"),
+		Type:     "starlark",
+		Sandbox:  DefaultSandbox(),
+	})
+	assert.Equal(test, "", actual.Error)
+	assert.Equal(test, "steps: []", actual.Template)
+
+	actual = Validate(context.Background(), ValidationRequest{
+		Template: fmt.Sprintf(template, "http.star", "http"),
+		Type:     "starlark",
+		Sandbox:  DefaultSandbox(),
+	})
+	assert.Equal(test, "cannot load http.star: module 'http.star' is not allowed", actual.Error)
+
+	actual = Validate(context.Background(), ValidationRequest{
+		Template: fmt.Sprintf(template, "math.star", "math"),
+		Type:     "starlark",
+		Sandbox:  &Sandbox{},
+	})
+	assert.Equal(test, "cannot load encoding/json.star: module 'encoding/json.star' is not allowed", actual.Error)
+}
@@ -0,0 +1,437 @@
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Message of the violations for keys that are not part of the schema, like misspelled keys
+const unknownKeyMessage = "is not a known key"
+
+// A violation of the vela pipeline schema, along with the yaml path at which it occurred
+type SchemaViolation struct {
+	Path    string `yaml:"path"`
+	Message string `yaml:"message"`
+}
+
+func (violation SchemaViolation) String() string {
+	if violation.Path == "" {
+		return violation.Message
+	}
+
+	return violation.Path + ": " + violation.Message
+}
+
+// Describes the expected shape of a node in a vela pipeline
+type schemaNode struct {
+	kinds    []string               // Accepted kinds of values. Any kind is accepted if empty
+	fields   map[string]*schemaNode // Known keys of a map. Other keys are not allowed, unless values is specified
+	values   *schemaNode            // Schema of values of a map with arbitrary keys
+	items    *schemaNode            // Schema of items of a list
+	required []string               // Keys that must be present in a map
+	check    func(value map[interface{}]interface{}, path string) []SchemaViolation
+}
+
+var (
+	stringSchema  = &schemaNode{kinds: []string{"string"}}
+	booleanSchema = &schemaNode{kinds: []string{"boolean"}}
+	integerSchema = &schemaNode{kinds: []string{"integer"}}
+	scalarSchema  = &schemaNode{kinds: []string{"string", "boolean", "integer", "number"}}
+
+	stringListSchema   = &schemaNode{kinds: []string{"list"}, items: stringSchema}
+	stringOrListSchema = &schemaNode{kinds: []string{"string", "list"}, items: stringSchema}
+
+	// Can be specified either as a map or as a list of KEY=VALUE strings
+	environmentSchema = &schemaNode{
+		kinds:  []string{"map", "list"},
+		values: scalarSchema,
+		items:  stringSchema,
+	}
+
+	rulesSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"branch":   stringOrListSchema,
+			"comment":  stringOrListSchema,
+			"event":    stringOrListSchema,
+			"path":     stringOrListSchema,
+			"repo":     stringOrListSchema,
+			"status":   stringOrListSchema,
+			"tag":      stringOrListSchema,
+			"target":   stringOrListSchema,
+			"label":    stringOrListSchema,
+			"instance": stringOrListSchema,
+			"sender":   stringOrListSchema,
+		},
+	}
+
+	rulesetSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: mergeFields(rulesSchema.fields, map[string]*schemaNode{
+			"if":       rulesSchema,
+			"unless":   rulesSchema,
+			"matcher":  stringSchema,
+			"operator": stringSchema,
+			"continue": booleanSchema,
+		}),
+	}
+
+	secretReferenceSchema = &schemaNode{
+		kinds: []string{"list"},
+		items: &schemaNode{
+			kinds: []string{"string", "map"},
+			fields: map[string]*schemaNode{
+				"source": stringSchema,
+				"target": stringSchema,
+			},
+			required: []string{"source", "target"},
+		},
+	}
+
+	ulimitsSchema = &schemaNode{
+		kinds: []string{"list"},
+		items: &schemaNode{
+			kinds: []string{"string", "map"},
+			fields: map[string]*schemaNode{
+				"name": stringSchema,
+				"soft": integerSchema,
+				"hard": integerSchema,
+			},
+			required: []string{"name"},
+		},
+	}
+
+	volumesSchema = &schemaNode{
+		kinds: []string{"list"},
+		items: &schemaNode{
+			kinds: []string{"string", "map"},
+			fields: map[string]*schemaNode{
+				"source":      stringSchema,
+				"destination": stringSchema,
+				"access_mode": stringSchema,
+			},
+			required: []string{"source", "destination"},
+		},
+	}
+
+	stepTemplateSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"name": stringSchema,
+			"vars": {kinds: []string{"map"}},
+		},
+		required: []string{"name"},
+	}
+
+	stepSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"name":        stringSchema,
+			"image":       stringSchema,
+			"pull":        stringSchema,
+			"commands":    stringOrListSchema,
+			"entrypoint":  stringOrListSchema,
+			"environment": environmentSchema,
+			"parameters":  {kinds: []string{"map"}},
+			"secrets":     secretReferenceSchema,
+			"ruleset":     rulesetSchema,
+			"template":    stepTemplateSchema,
+			"detach":      booleanSchema,
+			"privileged":  booleanSchema,
+			"user":        stringSchema,
+			"ulimits":     ulimitsSchema,
+			"volumes":     volumesSchema,
+			"report_as":   stringSchema,
+			"id_request":  stringSchema,
+		},
+		required: []string{"name"},
+		check:    checkStep,
+	}
+
+	serviceSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"name":        stringSchema,
+			"image":       stringSchema,
+			"pull":        stringSchema,
+			"entrypoint":  stringOrListSchema,
+			"environment": environmentSchema,
+			"ports":       stringListSchema,
+			"ulimits":     ulimitsSchema,
+			"user":        stringSchema,
+		},
+		required: []string{"name", "image"},
+	}
+
+	stageSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"name":        stringSchema,
+			"needs":       stringOrListSchema,
+			"independent": booleanSchema,
+			"environment": environmentSchema,
+			"steps":       {kinds: []string{"list"}, items: stepSchema},
+		},
+		required: []string{"steps"},
+	}
+
+	secretSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"name":   stringSchema,
+			"key":    stringSchema,
+			"engine": stringSchema,
+			"type":   stringSchema,
+			"origin": stepSchema,
+		},
+		required: []string{"name"},
+	}
+
+	pipelineSchema = &schemaNode{
+		kinds: []string{"map"},
+		fields: map[string]*schemaNode{
+			"version": scalarSchema, // Read into a string by vela, so 'version: 1' is valid
+			"metadata": {
+				kinds: []string{"map"},
+				fields: map[string]*schemaNode{
+					"template":      booleanSchema,
+					"clone":         booleanSchema,
+					"render_inline": booleanSchema,
+					"environment":   stringListSchema,
+					"auto_cancel":   {kinds: []string{"map"}, values: booleanSchema},
+				},
+			},
+			"environment": environmentSchema,
+			"worker": {
+				kinds: []string{"map"},
+				fields: map[string]*schemaNode{
+					"flavor":   stringSchema,
+					"platform": stringSchema,
+				},
+			},
+			"templates": {
+				kinds: []string{"list"},
+				items: &schemaNode{
+					kinds: []string{"map"},
+					fields: map[string]*schemaNode{
+						"name":   stringSchema,
+						"source": stringSchema,
+						"format": stringSchema,
+						"type":   stringSchema,
+						"vars":   {kinds: []string{"map"}},
+					},
+					required: []string{"name", "source"},
+				},
+			},
+			"steps":    {kinds: []string{"list"}, items: stepSchema},
+			"stages":   {kinds: []string{"map"}, values: stageSchema},
+			"services": {kinds: []string{"list"}, items: serviceSchema},
+			"secrets":  {kinds: []string{"list"}, items: secretSchema},
+			"ruleset":  rulesetSchema,
+		},
+		check: checkPipeline,
+	}
+)
+
+// Validates a processed template against the vela pipeline schema and returns all violations
+func ValidatePipeline(pipeline interface{}) []SchemaViolation {
+	return pipelineSchema.validate(pipeline, "")
+}
+
+// Validates a processed template against the vela pipeline schema, like ValidatePipeline. Top-level keys
+// that define yaml anchors, like 'slack_image: &slack_image', are ignored by vela and are allowed
+func validatePipelineDocument(document string, pipeline interface{}) []SchemaViolation {
+	anchorKeys := findAnchorKeys(document)
+
+	violations := []SchemaViolation{}
+	for _, violation := range ValidatePipeline(pipeline) {
+		if violation.Message == unknownKeyMessage && contains(anchorKeys, violation.Path) {
+			continue
+		}
+		violations = append(violations, violation)
+	}
+
+	return violations
+}
+
+// Returns the top-level keys of a yaml document whose values define anchors
+func findAnchorKeys(document string) []string {
+	root := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal([]byte(document), root); err != nil || len(root.Content) == 0 {
+		return nil
+	}
+
+	var keys []string
+	mapping := root.Content[0]
+	for index := 0; mapping.Kind == yamlv3.MappingNode && index+1 < len(mapping.Content); index += 2 {
+		if mapping.Content[index+1].Anchor != "" {
+			keys = append(keys, joinPath("", mapping.Content[index].Value))
+		}
+	}
+
+	return keys
+}
+
+func (schema *schemaNode) validate(value interface{}, path string) []SchemaViolation {
+	// A key with an empty value is treated as absent by vela
+	if value == nil {
+		return nil
+	}
+
+	kind := kindOf(value)
+	if len(schema.kinds) > 0 && !contains(schema.kinds, kind) {
+		return []SchemaViolation{{
+			Path:    path,
+			Message: fmt.Sprintf("expected %s, found %s", strings.Join(schema.kinds, " or "), kind),
+		}}
+	}
+
+	violations := []SchemaViolation{}
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		for _, requiredKey := range schema.required {
+			if typedValue[requiredKey] == nil {
+				violations = append(violations, SchemaViolation{
+					Path:    joinPath(path, requiredKey),
+					Message: "is required",
+				})
+			}
+		}
+
+		for _, key := range sortedKeys(typedValue) {
+			childSchema := schema.values
+			if fieldSchema, ok := schema.fields[fmt.Sprint(key)]; ok {
+				childSchema = fieldSchema
+			} else if schema.fields != nil && schema.values == nil {
+				violations = append(violations, SchemaViolation{
+					Path:    joinPath(path, key),
+					Message: unknownKeyMessage,
+				})
+				continue
+			}
+
+			if childSchema != nil {
+				violations = append(violations, childSchema.validate(typedValue[key], joinPath(path, key))...)
+			}
+		}
+
+		if schema.check != nil {
+			violations = append(violations, schema.check(typedValue, path)...)
+		}
+	case []interface{}:
+		if schema.items != nil {
+			for index, item := range typedValue {
+				violations = append(violations, schema.items.validate(item, fmt.Sprintf("%s[%d]", path, index))...)
+			}
+		}
+	}
+
+	return violations
+}
+
+// Checks rules of a step that cannot be expressed through the schema alone
+func checkStep(step map[interface{}]interface{}, path string) []SchemaViolation {
+	// A step referencing a template is replaced by the template's steps
+	if step["template"] != nil {
+		return nil
+	}
+
+	violations := []SchemaViolation{}
+	if step["image"] == nil {
+		violations = append(violations, SchemaViolation{
+			Path:    joinPath(path, "image"),
+			Message: "is required",
+		})
+	}
+
+	if step["commands"] == nil && step["environment"] == nil && step["parameters"] == nil && step["secrets"] == nil {
+		violations = append(violations, SchemaViolation{
+			Path:    path,
+			Message: "no commands, environment, parameters, secrets or template provided",
+		})
+	}
+
+	return violations
+}
+
+// Checks rules of the pipeline that cannot be expressed through the schema alone
+func checkPipeline(pipeline map[interface{}]interface{}, path string) []SchemaViolation {
+	if pipeline["steps"] == nil && pipeline["stages"] == nil {
+		return []SchemaViolation{{
+			Path:    joinPath(path, "steps"),
+			Message: "no stages or steps provided",
+		}}
+	} else if pipeline["steps"] != nil && pipeline["stages"] != nil {
+		return []SchemaViolation{{
+			Path:    path,
+			Message: "cannot have both stages and steps at the top level",
+		}}
+	}
+
+	return nil
+}
+
+// Returns the schema kind of a value parsed by the yaml library
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case map[interface{}]interface{}, OrderedMap:
+		return "map"
+	case []interface{}:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path string, key interface{}) string {
+	if path == "" {
+		return fmt.Sprint(key)
+	}
+
+	return path + "." + fmt.Sprint(key)
+}
+
+func sortedKeys(value map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(first, second int) bool {
+		return fmt.Sprint(keys[first]) < fmt.Sprint(keys[second])
+	})
+	return keys
+}
+
+func mergeFields(first map[string]*schemaNode, second map[string]*schemaNode) map[string]*schemaNode {
+	merged := make(map[string]*schemaNode, len(first)+len(second))
+	for key, value := range first {
+		merged[key] = value
+	}
+	for key, value := range second {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
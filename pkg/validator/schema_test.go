@@ -0,0 +1,149 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidatePipeline(test *testing.T) {
+	cases := []struct {
+		pipeline string
+		expected []SchemaViolation
+	}{
+		{
+			`
+version: "1"
+steps:
+  - name: build
+    image: golang:1.23
+    commands: [ go build ]
+    ruleset:
+      branch: master
+      event: [ push, tag ]
+`,
+			[]SchemaViolation{},
+		},
+		{
+			`
+step:
+  - name: build
+    image: golang:1.23
+`,
+			[]SchemaViolation{
+				{"step", "is not a known key"},
+				{"steps", "no stages or steps provided"},
+			},
+		},
+		{
+			`
+steps:
+  - name: build
+    image: golang:1.23
+    comands: [ go build ]
+    environment:
+      GOOS: linux
+    ruleset:
+      branches: master
+    parameters:
+      any: value
+`,
+			[]SchemaViolation{
+				{"steps[0].comands", "is not a known key"},
+				{"steps[0].ruleset.branches", "is not a known key"},
+			},
+		},
+		{
+			`
+steps:
+  - name: build
+    image: 123
+    commands: go build
+  - commands: [ go test ]
+  - name: notify
+    image: alpine
+`,
+			[]SchemaViolation{
+				{"steps[0].image", "expected string, found integer"},
+				{"steps[1].name", "is required"},
+				{"steps[1].image", "is required"},
+				{"steps[2]", "no commands, environment, parameters, secrets or template provided"},
+			},
+		},
+		{
+			`
+version: 1
+metadata:
+  template: yes please
+secrets:
+  - key: org/repo/token
+services:
+  - name: postgres
+stages:
+  build:
+    steps:
+      - name: build
+        image: golang:1.23
+        commands: [ go build ]
+        secrets: [ { source: token } ]
+  test:
+    needs: [ build ]
+`,
+			[]SchemaViolation{
+				{"metadata.template", "expected boolean, found string"},
+				{"secrets[0].name", "is required"},
+				{"services[0].image", "is required"},
+				{"stages.build.steps[0].secrets[0].target", "is required"},
+				{"stages.test.steps", "is required"},
+			},
+		},
+		{
+			`
+steps:
+  - name: build
+    template:
+      name: go
+stages:
+  build:
+    steps: []
+`,
+			[]SchemaViolation{
+				{"", "cannot have both stages and steps at the top level"},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		pipeline := make(map[interface{}]interface{})
+		yaml.Unmarshal([]byte(data.pipeline), &pipeline)
+
+		assert.Equal(test, data.expected, ValidatePipeline(pipeline))
+	}
+}
+
+func TestSchemaViolationString(test *testing.T) {
+	assert.Equal(test, "steps[0].image: is required", SchemaViolation{"steps[0].image", "is required"}.String())
+	assert.Equal(test, "no stages or steps provided", SchemaViolation{"", "no stages or steps provided"}.String())
+}
+
+func TestValidatePipelineDocument(test *testing.T) {
+	document := `
+slack_image: &slack_image
+  image: devatherock/simple-slack:0.2.0
+notify: { name: notify }
+steps:
+  - name: notify
+    <<: *slack_image
+    parameters: { text: Success }
+`
+	pipeline := make(map[interface{}]interface{})
+	yaml.Unmarshal([]byte(document), &pipeline)
+
+	// Keys that only hold anchors are ignored by vela
+	assert.Equal(test, []SchemaViolation{{"notify", "is not a known key"}}, validatePipelineDocument(document, pipeline))
+	assert.Equal(test, []SchemaViolation{{"notify", "is not a known key"}, {"slack_image", "is not a known key"}}, ValidatePipeline(pipeline))
+}
@@ -0,0 +1,96 @@
+package validator
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Function injected before each text and action of a go template to record where its output starts
+const sourceMarkFunction = "sourceMark"
+
+// A text or action of a go template
+type sourceNode struct {
+	position int    // Offset of the text or of the '{{' of the action in the template
+	text     string // Content of a text, which is output as is
+}
+
+// Output of a node of the template starts at an offset of the output
+type outputMark struct {
+	offset int
+	node   int
+}
+
+// Records the nodes of a go template that produced each part of the output, to locate errors
+// in the output in the template
+type sourceMap struct {
+	nodes []sourceNode
+	marks []outputMark // In the order of the output
+}
+
+// Returns the function that records the start of the output of a node
+func (sourceMap *sourceMap) goFunction(output *bytes.Buffer) func(node int) string {
+	return func(node int) string {
+		sourceMap.marks = append(sourceMap.marks, outputMark{output.Len(), node})
+		return ""
+	}
+}
+
+// Inserts an action that records the start of the output before each text and action of a go template
+func (sourceMap *sourceMap) instrumentGoTemplate(node parse.Node, source string) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+
+		nodes := make([]parse.Node, 0, 2*len(typedNode.Nodes))
+		for _, child := range typedNode.Nodes {
+			switch typedChild := child.(type) {
+			case *parse.TextNode:
+				nodes = append(nodes, sourceMap.markAction(int(typedChild.Pos), string(typedChild.Text)))
+			case *parse.ActionNode:
+				// The position of an action is that of its first token, after the delimiter and any '-'
+				nodes = append(nodes, sourceMap.markAction(strings.LastIndex(source[:typedChild.Pos], "{{"), ""))
+			default:
+				sourceMap.instrumentGoTemplate(child, source)
+			}
+			nodes = append(nodes, child)
+		}
+		typedNode.Nodes = nodes
+	case *parse.IfNode:
+		sourceMap.instrumentGoTemplate(typedNode.List, source)
+		sourceMap.instrumentGoTemplate(typedNode.ElseList, source)
+	case *parse.RangeNode:
+		sourceMap.instrumentGoTemplate(typedNode.List, source)
+		sourceMap.instrumentGoTemplate(typedNode.ElseList, source)
+	case *parse.WithNode:
+		sourceMap.instrumentGoTemplate(typedNode.List, source)
+		sourceMap.instrumentGoTemplate(typedNode.ElseList, source)
+	}
+}
+
+func (sourceMap *sourceMap) markAction(position int, text string) *parse.ActionNode {
+	sourceMap.nodes = append(sourceMap.nodes, sourceNode{max(position, 0), text})
+	return indexedAction(sourceMarkFunction, len(sourceMap.nodes)-1, parse.Pos(max(position, 0)))
+}
+
+// Returns the offset in the template of the node that produced the output at an offset. The offset
+// is exact within texts. Output of actions is located at the start of the action
+func (sourceMap *sourceMap) locate(offset int) (int, bool) {
+	index := sort.Search(len(sourceMap.marks), func(index int) bool {
+		return sourceMap.marks[index].offset > offset
+	}) - 1
+	if index < 0 {
+		return 0, false
+	}
+
+	mark := sourceMap.marks[index]
+	node := sourceMap.nodes[mark.node]
+	if distance := offset - mark.offset; distance < len(node.text) {
+		return node.position + distance, true
+	}
+
+	return node.position, true
+}
@@ -0,0 +1,46 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceMapLocate(test *testing.T) {
+	source := "steps:\n{{- range . }}\n  - name: {{ . }}\n{{- end }}"
+
+	mapping := &sourceMap{}
+	output := new(bytes.Buffer)
+	parsedTemplate := template.Must(template.New("test").Funcs(template.FuncMap{sourceMarkFunction: mapping.goFunction(output)}).Parse(source))
+	mapping.instrumentGoTemplate(parsedTemplate.Tree.Root, source)
+	assert.Nil(test, parsedTemplate.Execute(output, []string{"build", "test"}))
+	assert.Equal(test, "steps:\n  - name: build\n  - name: test", output.String())
+
+	cases := []struct {
+		output   int
+		expected int
+	}{
+		{0, 0},   // 's' of 'steps'
+		{5, 5},   // ':' of 'steps:'
+		{9, 24},  // '-' of the first step
+		{17, 32}, // 'build', output by the action
+		{19, 32},
+		{22, 21}, // Newline before the second step
+		{25, 24}, // '-' of the second step
+	}
+
+	for _, data := range cases {
+		actual, found := mapping.locate(data.output)
+
+		assert.True(test, found)
+		assert.Equal(test, data.expected, actual, data.output)
+	}
+
+	_, found := (&sourceMap{}).locate(0)
+	assert.False(test, found)
+}
@@ -0,0 +1,282 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/qri-io/starlib"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+	"gopkg.in/yaml.v2"
+)
+
+// Name of the starlark template in error messages
+const starlarkFileName = "template.star"
+
+// Functions available to starlark templates in addition to the built-in ones, as in vela
+var starlarkPredeclared = starlark.StringDict{
+	"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+}
+
+// Executes a starlark template and converts the value returned by its 'main' function into yaml.
+// Messages printed by the template are added to the debug log of the response
+func validateStarlarkTemplate(ctx context.Context, validationRequest *ValidationRequest, validationResponse *ValidationResponse) (string, error) {
+	thread := &starlark.Thread{
+		Name: "template",
+		Print: func(thread *starlark.Thread, message string) {
+			validationResponse.DebugLog = append(validationResponse.DebugLog, message)
+		},
+		Load: starlib.Loader,
+	}
+	if validationRequest.Sandbox != nil {
+		thread.Load = validationRequest.Sandbox.loader()
+	}
+
+	limits := validationRequest.Limits
+	if limits.MaxStarlarkSteps > 0 {
+		thread.SetMaxExecutionSteps(limits.MaxStarlarkSteps)
+	}
+	stopCancellation := context.AfterFunc(ctx, func() {
+		thread.Cancel(ctx.Err().Error())
+	})
+	defer stopCancellation()
+
+	var globals starlark.StringDict
+	var err error
+	if validationRequest.Coverage {
+		recorder := &branchRecorder{}
+		defer func() {
+			validationResponse.Coverage = recorder.coverage()
+		}()
+		globals, err = execCoveredStarlarkTemplate(thread, validationRequest.Template, recorder)
+	} else {
+		globals, err = starlark.ExecFile(thread, starlarkFileName, validationRequest.Template, starlarkPredeclared)
+	}
+	if err != nil {
+		return "", starlarkLimitError(ctx, thread, limits, err)
+	}
+
+	main, ok := globals["main"].(starlark.Callable)
+	if !ok {
+		return "", fmt.Errorf("%s: no 'main' function defined", starlarkFileName)
+	}
+
+	templateContext, err := starlarkContext(validationRequest)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := starlark.Call(thread, main, starlark.Tuple{templateContext}, nil)
+	if err != nil {
+		return "", starlarkLimitError(ctx, thread, limits, err)
+	}
+
+	if _, ok := result.(*starlark.Dict); !ok {
+		return "", fmt.Errorf("%s: 'main' returned %s instead of a dict", starlarkFileName, result.Type())
+	}
+
+	output, err := fromStarlarkValue(result)
+	if err != nil {
+		return "", err
+	}
+
+	outputTemplate, err := yaml.Marshal(output)
+	if err != nil {
+		return "", err
+	}
+
+	return string(outputTemplate), limits.checkOutput(outputTemplate)
+}
+
+// Executes a starlark template like starlark.ExecFile, after instrumenting it to record the branches it takes
+func execCoveredStarlarkTemplate(thread *starlark.Thread, template string, recorder *branchRecorder) (starlark.StringDict, error) {
+	file, err := syntax.LegacyFileOptions().Parse(starlarkFileName, template, 0)
+	if err != nil {
+		return nil, err
+	}
+	recorder.instrumentStarlark(file)
+
+	predeclared := starlark.StringDict{starlarkCoverBranchFunction: recorder.starlarkFunction()}
+	for name, value := range starlarkPredeclared {
+		predeclared[name] = value
+	}
+
+	program, err := starlark.FileProgram(file, predeclared.Has)
+	if err != nil {
+		return nil, err
+	}
+
+	globals, err := program.Init(thread, predeclared)
+	globals.Freeze()
+
+	return globals, err
+}
+
+// Converts the error of a starlark thread that was cancelled or ran out of steps into a limit exceeded error
+func starlarkLimitError(ctx context.Context, thread *starlark.Thread, limits Limits, err error) error {
+	if ctx.Err() != nil {
+		contextError := limits.contextError(ctx.Err())
+		if limitError, ok := contextError.(*LimitExceededError); ok {
+			limitError.Cause = err
+		}
+		return contextError
+	}
+
+	if limits.MaxStarlarkSteps > 0 && thread.ExecutionSteps() >= limits.MaxStarlarkSteps {
+		return &LimitExceededError{
+			Limit:   LimitStarlarkSteps,
+			Message: fmt.Sprintf("starlark execution exceeded the limit of %d steps", limits.MaxStarlarkSteps),
+			Cause:   err,
+		}
+	}
+
+	return err
+}
+
+// Builds the 'ctx' dict that vela passes to the 'main' function of a template, with the variables and build context
+func starlarkContext(validationRequest *ValidationRequest) (*starlark.Dict, error) {
+	parameters := validationRequest.Parameters
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+
+	variables, err := toStarlarkValue(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	templateContext := starlark.NewDict(4)
+	templateContext.SetKey(starlark.String("vars"), variables)
+
+	buildContext := BuildContext{}
+	if validationRequest.BuildContext != nil {
+		buildContext = *validationRequest.BuildContext
+	}
+	buildContext.addToStarlarkContext(templateContext)
+
+	return templateContext, nil
+}
+
+// Converts a value parsed from yaml or json into a starlark value
+func toStarlarkValue(value interface{}) (starlark.Value, error) {
+	switch typedValue := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(typedValue), nil
+	case string:
+		return starlark.String(typedValue), nil
+	case int:
+		return starlark.MakeInt(typedValue), nil
+	case int64:
+		return starlark.MakeInt64(typedValue), nil
+	case uint64:
+		return starlark.MakeUint64(typedValue), nil
+	case float64:
+		// Numbers from json are always parsed as float64
+		if typedValue == math.Trunc(typedValue) && math.Abs(typedValue) < math.MaxInt64 {
+			return starlark.MakeInt64(int64(typedValue)), nil
+		}
+		return starlark.Float(typedValue), nil
+	case []interface{}:
+		elements := make([]starlark.Value, len(typedValue))
+		for index, element := range typedValue {
+			convertedElement, err := toStarlarkValue(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[index] = convertedElement
+		}
+		return starlark.NewList(elements), nil
+	case map[string]interface{}, map[interface{}]interface{}:
+		// Keys are sorted to make iteration over the dict deterministic
+		entries, _ := toStringMap(typedValue)
+		dict := starlark.NewDict(len(entries))
+		for _, key := range sortedStringKeys(entries) {
+			convertedEntry, err := toStarlarkValue(entries[key])
+			if err != nil {
+				return nil, err
+			}
+			dict.SetKey(starlark.String(key), convertedEntry)
+		}
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("unsupported variable of type %T", value)
+}
+
+// Converts a value returned by a starlark template into a value that can be marshalled into yaml or json. Dicts
+// are converted into ordered maps, so that the output keeps their insertion order
+func fromStarlarkValue(value starlark.Value) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(typedValue), nil
+	case starlark.String:
+		return string(typedValue), nil
+	case starlark.Int:
+		if intValue, ok := typedValue.Int64(); ok {
+			return intValue, nil
+		}
+		return typedValue.String(), nil
+	case starlark.Float:
+		return float64(typedValue), nil
+	case starlark.Indexable: // Lists and tuples
+		elements := make([]interface{}, typedValue.Len())
+		for index := range elements {
+			element, err := fromStarlarkValue(typedValue.Index(index))
+			if err != nil {
+				return nil, err
+			}
+			elements[index] = element
+		}
+		return elements, nil
+	case *starlark.Dict:
+		entries := make(OrderedMap, 0, typedValue.Len())
+		for _, item := range typedValue.Items() {
+			entry, err := fromStarlarkValue(item[1])
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, yaml.MapItem{Key: starlarkKey(item[0]), Value: entry})
+		}
+		return entries, nil
+	case *starlarkstruct.Struct:
+		// Structs don't keep the order of their fields, which are in lexical order
+		entries := OrderedMap{}
+		for _, name := range typedValue.AttrNames() {
+			attribute, _ := typedValue.Attr(name)
+			entry, err := fromStarlarkValue(attribute)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, yaml.MapItem{Key: name, Value: entry})
+		}
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("unable to convert value of type %s into yaml", value.Type())
+}
+
+// Returns a dict key as a string, without quotes for strings
+func starlarkKey(key starlark.Value) string {
+	if stringKey, ok := key.(starlark.String); ok {
+		return string(stringKey)
+	}
+
+	return key.String()
+}
+
+func sortedStringKeys[Value any](entries map[string]Value) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
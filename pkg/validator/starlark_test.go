@@ -0,0 +1,125 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestValidateStarlarkTemplateInMemory(test *testing.T) {
+	cases := []struct {
+		template   string
+		parameters interface{}
+		expected   ValidationResponse
+	}{
+		{
+			"ctx = {'vars': {}}\n\ndef main(ctx):\n  print('image:', ctx['vars']['image'])\n  print('retries:', ctx['vars']['retries'])\n" +
+				"  return {'steps': [{'name': 'build', 'image': ctx['vars']['image'], 'commands': ['go build' for _ in range(ctx['vars']['retries'])]}]}",
+			map[string]interface{}{"image": "golang:1.23", "retries": float64(2)},
+			ValidationResponse{
+				Message:  "template is a valid yaml",
+				Template: "steps:\n- name: build\n  image: golang:1.23\n  commands:\n  - go build\n  - go build",
+				DebugLog: []string{"image: golang:1.23", "retries: 2"},
+			},
+		},
+		{
+			"def main(ctx):\n  return {'version': '1', 'steps': [struct(name = 'test', image = 'alpine', pull = ctx['vars']['pull'], commands = ('ls',))]}",
+			map[interface{}]interface{}{"pull": true},
+			ValidationResponse{
+				Message:     "template is a valid yaml",
+				Template:    "version: \"1\"\nsteps:\n- commands:\n  - ls\n  image: alpine\n  name: test\n  pull: true",
+				FailedStage: StageSchema,
+			},
+		},
+		{
+			"def main(ctx):\n  print('vars:', ctx['vars'])\n  return ctx['vars']['steps']",
+			nil,
+			ValidationResponse{
+				Message:  "Invalid template",
+				Error:    "key \"steps\" not in dict",
+				DebugLog: []string{"vars: {}"},
+				Diagnostics: []TemplateDiagnostic{
+					{
+						Stage:   StageExecute,
+						Line:    3,
+						Column:  21,
+						Action:  "main",
+						Message: "key \"steps\" not in dict",
+						Excerpt: "   3 |   return ctx['vars']['steps']\n     |                     ^",
+					},
+				},
+				FailedStage: StageExecute,
+			},
+		},
+		{
+			"def main(ctx):\n  return [{'name': 'build'}]",
+			nil,
+			ValidationResponse{
+				Message:     "Invalid template",
+				Error:       "template.star: 'main' returned list instead of a dict",
+				FailedStage: StageExecute,
+			},
+		},
+		{
+			"def build(ctx):\n  return {}",
+			nil,
+			ValidationResponse{
+				Message:     "Invalid template",
+				Error:       "template.star: no 'main' function defined",
+				FailedStage: StageExecute,
+			},
+		},
+	}
+
+	for _, data := range cases {
+		actual := Validate(context.Background(), ValidationRequest{
+			Template:   data.template,
+			Type:       "starlark",
+			Parameters: data.parameters,
+		})
+
+		data.expected.SchemaErrors = actual.SchemaErrors
+		assert.Equal(test, data.expected, actual)
+	}
+}
+
+func TestFromStarlarkValue(test *testing.T) {
+	inner := starlark.NewDict(2)
+	inner.SetKey(starlark.String("repo"), starlark.String("octocat/hello-world"))
+	inner.SetKey(starlark.MakeInt(1), starlark.True)
+	dict := starlark.NewDict(3)
+	dict.SetKey(starlark.String("version"), starlark.String("1"))
+	dict.SetKey(starlark.String("steps"), starlark.NewList([]starlark.Value{inner}))
+	dict.SetKey(starlark.String("environment"), starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"b": starlark.Float(1.5),
+		"a": starlark.None,
+	}))
+
+	// Dicts keep their insertion order, while structs are in lexical order
+	actual, err := fromStarlarkValue(dict)
+	assert.Nil(test, err)
+	assert.Equal(test, OrderedMap{
+		{Key: "version", Value: "1"},
+		{Key: "steps", Value: []interface{}{OrderedMap{
+			{Key: "repo", Value: "octocat/hello-world"},
+			{Key: "1", Value: true},
+		}}},
+		{Key: "environment", Value: OrderedMap{
+			{Key: "a", Value: nil},
+			{Key: "b", Value: 1.5},
+		}},
+	}, actual)
+
+	output, _ := json.Marshal(actual)
+	assert.Equal(test, `{"version":"1","steps":[{"repo":"octocat/hello-world","1":true}],"environment":{"a":null,"b":1.5}}`, string(output))
+
+	_, err = fromStarlarkValue(starlark.NewSet(0))
+	assert.Equal(test, "unable to convert value of type set into yaml", err.Error())
+}
@@ -0,0 +1,286 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"text/template/parse"
+)
+
+// A variable accessed by a template that was not supplied in the parameters
+type UndefinedVariable struct {
+	Name      string `yaml:"name"`
+	Line      int    `yaml:"line"`
+	Column    int    `yaml:"column"`
+	RescuedBy string `yaml:"rescued_by,omitempty"` // The function or action that handles the missing variable, if any
+	Template  string `yaml:"template,omitempty"`   // Name of the template of a pipeline that accesses the variable
+}
+
+// Functions that fall back to another value when a variable is not defined
+var fallbackFunctions = map[string]bool{
+	"default":  true,
+	"coalesce": true,
+}
+
+// Walks a go template and finds the variable accesses that can't be resolved from the parameters
+type undefinedVariableFinder struct {
+	source    string
+	variables []UndefinedVariable
+	reported  map[string]bool
+	guarded   map[string]int // Field chains checked by the enclosing 'if' actions
+}
+
+// Finds the variables accessed by a go template that are not present in the supplied parameters
+func findUndefinedVariables(validationRequest *ValidationRequest) ([]UndefinedVariable, error) {
+	parsedTemplate, err := parseGoTemplate(validationRequest.Template, validationRequest.VelaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	finder := &undefinedVariableFinder{
+		source:   validationRequest.Template,
+		reported: make(map[string]bool),
+		guarded:  make(map[string]int),
+	}
+	finder.walk(parsedTemplate.Tree.Root, []interface{}{validationRequest.Parameters}, validationRequest.Parameters)
+
+	return finder.variables, nil
+}
+
+// Walks a node with the possible values of dot. Accesses relative to dot are not checked when dot is unknown
+func (finder *undefinedVariableFinder) walk(node parse.Node, dots []interface{}, root interface{}) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for _, child := range typedNode.Nodes {
+			finder.walk(child, dots, root)
+		}
+	case *parse.ActionNode:
+		finder.walkPipe(typedNode.Pipe, dots, root, "")
+	case *parse.IfNode:
+		finder.walkPipe(typedNode.Pipe, dots, root, "if")
+
+		// Variables checked by the condition are known to be defined within the 'if' block
+		conditionFields := pipeFields(typedNode.Pipe)
+		for _, field := range conditionFields {
+			finder.guarded[field]++
+		}
+		finder.walk(typedNode.List, dots, root)
+		for _, field := range conditionFields {
+			finder.guarded[field]--
+		}
+
+		finder.walk(typedNode.ElseList, dots, root)
+	case *parse.WithNode:
+		finder.walkPipe(typedNode.Pipe, dots, root, "with")
+		finder.walk(typedNode.List, finder.resolvePipe(typedNode.Pipe, dots, root), root)
+		finder.walk(typedNode.ElseList, dots, root)
+	case *parse.RangeNode:
+		finder.walkPipe(typedNode.Pipe, dots, root, "")
+
+		elements := []interface{}{}
+		for _, collection := range finder.resolvePipe(typedNode.Pipe, dots, root) {
+			elements = append(elements, collectionElements(collection)...)
+		}
+		finder.walk(typedNode.List, elements, root)
+		finder.walk(typedNode.ElseList, dots, root)
+	case *parse.TemplateNode:
+		finder.walkPipe(typedNode.Pipe, dots, root, "")
+	}
+}
+
+// Checks the variable accesses in a pipeline. Accesses in the condition of 'if' or 'with' are handled by the action
+func (finder *undefinedVariableFinder) walkPipe(pipe *parse.PipeNode, dots []interface{}, root interface{}, action string) {
+	if pipe == nil {
+		return
+	}
+
+	for index, command := range pipe.Cmds {
+		rescuedBy := action
+
+		// A variable piped into a fallback function, like '.name | default "build"'
+		if index+1 < len(pipe.Cmds) && len(command.Args) == 1 {
+			if function := functionName(pipe.Cmds[index+1]); fallbackFunctions[function] {
+				rescuedBy = function
+			}
+		}
+
+		function := functionName(command)
+		for _, argument := range command.Args {
+			argumentRescuedBy := rescuedBy
+			if fallbackFunctions[function] {
+				argumentRescuedBy = function
+			}
+
+			switch typedArgument := argument.(type) {
+			case *parse.FieldNode:
+				finder.check(typedArgument.Ident, typedArgument.Position(), dots, argumentRescuedBy)
+			case *parse.VariableNode:
+				// Only accesses through the root variable '$' can be resolved
+				if typedArgument.Ident[0] == "$" && len(typedArgument.Ident) > 1 {
+					finder.check(typedArgument.Ident[1:], typedArgument.Position(), []interface{}{root}, argumentRescuedBy)
+				}
+			case *parse.PipeNode:
+				finder.walkPipe(typedArgument, dots, root, argumentRescuedBy)
+			}
+		}
+	}
+}
+
+// Records a field chain that is missing in any of the possible values of dot
+func (finder *undefinedVariableFinder) check(fields []string, position parse.Pos, dots []interface{}, rescuedBy string) {
+	for index := range fields {
+		if finder.guarded[strings.Join(fields[:index+1], ".")] > 0 {
+			return
+		}
+	}
+
+	for _, dot := range dots {
+		missingFields := missingFieldChain(dot, fields)
+		if missingFields == nil {
+			continue
+		}
+
+		name := strings.Join(missingFields, ".")
+		line, column := lineAndColumn(finder.source, int(position))
+		key := fmt.Sprintf("%s:%d:%d", name, line, column)
+		if !finder.reported[key] {
+			finder.reported[key] = true
+			finder.variables = append(finder.variables, UndefinedVariable{
+				Name:      name,
+				Line:      line,
+				Column:    column,
+				RescuedBy: rescuedBy,
+			})
+		}
+		return
+	}
+}
+
+// Returns the possible values of a pipeline if it is a simple variable access, to be used as dot
+func (finder *undefinedVariableFinder) resolvePipe(pipe *parse.PipeNode, dots []interface{}, root interface{}) []interface{} {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return nil
+	}
+
+	values := []interface{}{}
+	switch argument := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return dots
+	case *parse.FieldNode:
+		for _, dot := range dots {
+			if value, ok := lookupFields(dot, argument.Ident); ok && value != nil {
+				values = append(values, value)
+			}
+		}
+	case *parse.VariableNode:
+		if argument.Ident[0] == "$" {
+			if value, ok := lookupFields(root, argument.Ident[1:]); ok && value != nil {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+// Returns the field chain up to and including the first field missing in the value, or nil if all are present.
+// Fields of values other than maps are not checked
+func missingFieldChain(value interface{}, fields []string) []string {
+	for index, field := range fields {
+		var present bool
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			value, present = typedValue[field]
+		case map[interface{}]interface{}:
+			value, present = typedValue[field]
+		case nil:
+			present = false
+		default:
+			return nil
+		}
+
+		if !present {
+			return fields[:index+1]
+		}
+	}
+
+	return nil
+}
+
+// Returns the value at the end of a field chain
+func lookupFields(value interface{}, fields []string) (interface{}, bool) {
+	for _, field := range fields {
+		var present bool
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			value, present = typedValue[field]
+		case map[interface{}]interface{}:
+			value, present = typedValue[field]
+		}
+
+		if !present {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// Returns the elements of a list or the values of a map
+func collectionElements(collection interface{}) []interface{} {
+	switch typedCollection := collection.(type) {
+	case []interface{}:
+		return typedCollection
+	case map[string]interface{}:
+		elements := []interface{}{}
+		for _, element := range typedCollection {
+			elements = append(elements, element)
+		}
+		return elements
+	case map[interface{}]interface{}:
+		elements := []interface{}{}
+		for _, element := range typedCollection {
+			elements = append(elements, element)
+		}
+		return elements
+	}
+
+	return nil
+}
+
+// Returns the field chains accessed directly in a pipeline
+func pipeFields(pipe *parse.PipeNode) []string {
+	fields := []string{}
+	for _, command := range pipe.Cmds {
+		for _, argument := range command.Args {
+			if field, ok := argument.(*parse.FieldNode); ok {
+				fields = append(fields, strings.Join(field.Ident, "."))
+			}
+		}
+	}
+
+	return fields
+}
+
+// Returns the name of the function invoked by a command, if any
+func functionName(command *parse.CommandNode) string {
+	if len(command.Args) > 0 {
+		if identifier, ok := command.Args[0].(*parse.IdentifierNode); ok {
+			return identifier.Ident
+		}
+	}
+
+	return ""
+}
+
+// Converts a byte offset in the source into a one based line and column. Offsets past the end of
+// the source resolve to its end
+func lineAndColumn(source string, offset int) (int, int) {
+	text := source[:min(offset, len(source))]
+	line := 1 + strings.Count(text, "\n")
+	column := len(text) - strings.LastIndex(text, "\n")
+
+	return line, column
+}
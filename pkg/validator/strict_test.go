@@ -0,0 +1,166 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindUndefinedVariables(test *testing.T) {
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_strict_template.yml"))
+
+	cases := []struct {
+		parameters interface{}
+		expected   []UndefinedVariable
+	}{
+		{
+			map[string]interface{}{
+				"name":  "notify",
+				"image": "alpine",
+				"slack": map[string]interface{}{
+					"channel": "builds",
+				},
+				"notifcation_branch": "develop",
+				"secrets":            "slack_webhook",
+				"recipients": []interface{}{
+					map[string]interface{}{
+						"name":  "admin",
+						"email": "admin@example.com",
+					},
+				},
+			},
+			nil,
+		},
+		{
+			map[string]interface{}{
+				"slack": map[string]interface{}{},
+				"recipients": []interface{}{
+					map[string]interface{}{
+						"name": "admin",
+					},
+				},
+			},
+			[]UndefinedVariable{
+				{Name: "name", Line: 2, Column: 14, RescuedBy: "default"},
+				{Name: "image", Line: 3, Column: 15, RescuedBy: ""},
+				{Name: "notifcation_branch", Line: 5, Column: 35, RescuedBy: "default"},
+				{Name: "secrets", Line: 6, Column: 12, RescuedBy: "if"},
+				{Name: "slack.channel", Line: 10, Column: 20, RescuedBy: ""},
+				{Name: "email", Line: 12, Column: 23, RescuedBy: ""},
+			},
+		},
+		{
+			map[interface{}]interface{}{
+				"image": "alpine",
+			},
+			[]UndefinedVariable{
+				{Name: "name", Line: 2, Column: 14, RescuedBy: "default"},
+				{Name: "notifcation_branch", Line: 5, Column: 35, RescuedBy: "default"},
+				{Name: "secrets", Line: 6, Column: 12, RescuedBy: "if"},
+				{Name: "slack", Line: 10, Column: 20, RescuedBy: ""},
+				{Name: "recipients", Line: 11, Column: 17, RescuedBy: ""},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		undefinedVariables, err := findUndefinedVariables(&ValidationRequest{
+			Template:   string(input),
+			Parameters: data.parameters,
+		})
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, undefinedVariables)
+	}
+}
+
+func TestValidateStrict(test *testing.T) {
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_template.yml"))
+
+	cases := []struct {
+		parameters         map[string]interface{}
+		message            string
+		expectedError      string
+		undefinedVariables []UndefinedVariable
+	}{
+		{
+			map[string]interface{}{
+				"notification_branch": "develop",
+				"notification_event":  "push",
+			},
+			"template is a valid yaml",
+			"",
+			nil,
+		},
+		{
+			map[string]interface{}{
+				"notification_branch": "develop",
+			},
+			"template is a valid yaml",
+			"",
+			[]UndefinedVariable{
+				{Name: "notification_event", Line: 11, Column: 41, RescuedBy: "default"},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		validationResponse := Validate(context.Background(), ValidationRequest{
+			Template:   string(input),
+			Parameters: data.parameters,
+			Strict:     true,
+		})
+
+		assert.Equal(test, data.message, validationResponse.Message)
+		assert.Equal(test, data.expectedError, validationResponse.Error)
+		assert.Equal(test, data.undefinedVariables, validationResponse.UndefinedVariables)
+	}
+}
+
+func TestValidateStrictFailure(test *testing.T) {
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template: "steps:\n  - name: build\n    image: {{ .image }}\n    commands: [ {{ .comand }} ]",
+		Parameters: map[string]interface{}{
+			"command": "go build",
+		},
+		Strict: true,
+	})
+
+	assert.Equal(test, "template accesses undefined variables", validationResponse.Message)
+	assert.Equal(test, "undefined variables: 'image' at line 3, column 15, 'comand' at line 4, column 20", validationResponse.Error)
+	assert.Equal(test, []UndefinedVariable{
+		{Name: "image", Line: 3, Column: 15, RescuedBy: ""},
+		{Name: "comand", Line: 4, Column: 20, RescuedBy: ""},
+	}, validationResponse.UndefinedVariables)
+}
+
+func TestValidateStrictWithVelaVersion(test *testing.T) {
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template:    "steps:\n  - name: build\n    image: alpine\n    environment:\n      {{- .environment | toYaml | nindent 6 }}",
+		Parameters:  map[string]interface{}{"environment": map[string]interface{}{"GOOS": "linux"}},
+		VelaVersion: "0.17.0",
+		Strict:      true,
+	})
+
+	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Nil(test, validationResponse.UndefinedVariables)
+}
+
+func TestValidateStrictIgnoredForStarlark(test *testing.T) {
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template:   "def main(ctx):\n  return {'steps': [{'name': 'build', 'image': ctx['vars'].get('image', 'alpine'), 'commands': ['ls']}]}",
+		Type:       "starlark",
+		Parameters: map[string]interface{}{},
+		Strict:     true,
+	})
+
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Nil(test, validationResponse.UndefinedVariables)
+}
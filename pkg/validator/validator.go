@@ -2,52 +2,131 @@ package validator
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"os"
+	"fmt"
 	"regexp"
 	"strings"
 	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
-	jsoniter "github.com/json-iterator/go"
-	"github.com/qri-io/starlib"
 	log "github.com/sirupsen/logrus"
-	"go.starlark.net/starlark"
 	"gopkg.in/yaml.v2"
 )
 
 type ValidationResponse struct {
-	Message  string
-	Error    string `yaml:",omitempty"`
-	Template string `yaml:",omitempty"`
+	Message            string
+	Error              string               `yaml:",omitempty"`
+	Template           string               `yaml:",omitempty"`
+	SchemaErrors       []SchemaViolation    `yaml:"schema_errors,omitempty"`
+	Diagnostics        []TemplateDiagnostic `yaml:",omitempty"`
+	UndefinedVariables []UndefinedVariable  `yaml:"undefined_variables,omitempty"`
+	VariableErrors     []VariableError      `yaml:"variable_errors,omitempty"`
+	DebugLog           []string             `yaml:"debug_log,omitempty"`      // Messages printed by starlark templates
+	LimitExceeded      string               `yaml:"limit_exceeded,omitempty"` // Name of the limit exceeded while processing the template
+	FailedStage        string               `yaml:"failed_stage,omitempty"`   // Stage at which the validation failed, like 'parse' or 'schema'
+	Coverage           []BranchCoverage     `yaml:"coverage,omitempty"`       // Branches of the template and the number of times each was taken
+	Warnings           []LintFinding        `yaml:"warnings,omitempty"`       // Findings of the lint rules in the processed template
+	// Expressions of a go template that are probably meant for a downstream plugin and need to be escaped
+	UnescapedExpressions []UnescapedExpression `yaml:"unescaped_expressions,omitempty"`
+	// Plain scalars of the processed template that yaml 1.1 parsers read as booleans or numbers, like 'no' or '1.10'
+	AmbiguousScalars []AmbiguousScalar `yaml:"ambiguous_scalars,omitempty"`
 }
 
 type ValidationRequest struct {
 	Parameters interface{}
 	Template   string
 	Type       string
+	Templates  map[string]string `yaml:",omitempty"` // Contents of the templates referenced by a pipeline, by name
+	Strict     bool              `yaml:",omitempty"` // Fails go templates that access variables not present in the parameters
+	// Vela version whose template functions are to be used. All sprig functions are available if not specified
+	VelaVersion string `yaml:"vela_version,omitempty"`
+	// Build that processes the template. Resolves the 'vela' function in go templates and populates
+	// 'build', 'repo' and 'system' in the 'ctx' of starlark templates
+	BuildContext *BuildContext `yaml:"build_context,omitempty"`
+	// Records the branches of a go or starlark template taken while processing it, in the coverage of the response
+	Coverage bool `yaml:",omitempty"`
+	// Lint rules to apply to the processed template. Not linted if not specified
+	Lint *LintConfig `yaml:",omitempty"`
+	// Variables of downstream plugins in addition to the defaults, which a go template is expected to escape
+	DownstreamVariables []string `yaml:"downstream_variables,omitempty"`
+	// Restrictions for templates from untrusted sources and budgets for processing them. Set by the application, not by the requester
+	Sandbox *Sandbox `yaml:"-"`
+	Limits  Limits   `yaml:"-"`
+
+	sourceMap *sourceMap // Set by Validate, to locate the yaml errors of the output of a go template in the template
+
+	// Variable schema of the template and of the templates referenced by a pipeline, by name
+	VariableSchema  string            `yaml:"variable_schema,omitempty"`
+	VariableSchemas map[string]string `yaml:"variable_schemas,omitempty"`
 }
 
-func Validate(validationRequest ValidationRequest) (validationResponse ValidationResponse) {
+// Processes the template in the request and validates the output. Processing is stopped when
+// the context is done or the template exceeds the limits of the request
+func Validate(ctx context.Context, validationRequest ValidationRequest) (validationResponse ValidationResponse) {
 	validationResponse = ValidationResponse{}
 
 	// Error response in case of a panic
 	validationResponse.Message = "Invalid template"
 	validationResponse.Error = "Unable to parse template"
+	validationResponse.FailedStage = StageParse
 	defer handlePanic()
 
-	// Process template
-	var outputTemplate string
-	var err error
-	if validationRequest.Type == "starlark" {
-		outputTemplate, err = validateStarlarkTemplate(&validationRequest)
-	} else {
-		outputTemplate, err = validateGoTemplate(&validationRequest)
+	// Check variables against the schema before processing the template
+	if validationRequest.VariableSchema != "" {
+		variableErrors, err := ValidateVariables(validationRequest.VariableSchema, validationRequest.Parameters)
+		if err != nil {
+			validationResponse.Error = err.Error()
+			validationResponse.FailedStage = StageVariables
+			return validationResponse
+		} else if len(variableErrors) > 0 {
+			validationResponse.Message = "Invalid variables"
+			validationResponse.Error = "variables do not match the variable schema: " + joinVariableErrors(variableErrors)
+			validationResponse.VariableErrors = variableErrors
+			validationResponse.FailedStage = StageVariables
+			return validationResponse
+		}
+		validationRequest.Parameters = applyVariableDefaults(validationRequest.VariableSchema, validationRequest.Parameters)
+	}
+
+	if validationRequest.Lint != nil {
+		if _, err := validationRequest.Lint.rules(); err != nil {
+			validationResponse.Message = "Invalid lint configuration"
+			validationResponse.Error = err.Error()
+			validationResponse.FailedStage = StageLint
+			return validationResponse
+		}
+	}
+
+	if validationRequest.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, validationRequest.Limits.Timeout)
+		defer cancel()
+	}
+
+	if validationRequest.Type != "starlark" && validationRequest.Type != "pipeline" {
+		validationRequest.sourceMap = &sourceMap{}
 	}
 
+	// Process template
+	outputTemplate, err := renderTemplate(ctx, &validationRequest, &validationResponse)
 	if err != nil {
 		validationResponse.Error = err.Error()
+
+		// Reported without the location, which is in the diagnostics, as the errors of go templates
+		// name the functions that enforce the limits
+		var limitError *LimitExceededError
+		if errors.As(err, &limitError) {
+			validationResponse.Error = limitError.Error()
+			validationResponse.LimitExceeded = limitError.Limit
+		}
+
+		// Errors that cannot be located in the template, like exceeded limits, occur while executing it
+		validationResponse.FailedStage = StageExecute
+		diagnostic := diagnose(err, validationRequest.Template, validationRequest.Type)
+		if diagnostic != nil {
+			validationResponse.Diagnostics = []TemplateDiagnostic{*diagnostic}
+			validationResponse.FailedStage = diagnostic.Stage
+		}
 	} else {
 		processedTemplate := make(map[interface{}]interface{})
 
@@ -59,52 +138,161 @@ func Validate(validationRequest ValidationRequest) (validationResponse Validatio
 		if err != nil {
 			validationResponse.Error = err.Error()
 			validationResponse.Message = "template is not a valid yaml"
+			validationResponse.FailedStage = StageYaml
+			diagnostic := diagnoseYamlError(err, &validationRequest, outputTemplate, validationResponse.Template)
+			if diagnostic != nil {
+				validationResponse.Diagnostics = []TemplateDiagnostic{*diagnostic}
+			}
 		} else {
 			validationResponse.Message = "template is a valid yaml"
 			validationResponse.Error = ""
+			validationResponse.FailedStage = ""
+			validationResponse.AmbiguousScalars = findAmbiguousScalars(validationResponse.Template)
+			validationResponse.SchemaErrors = validatePipelineDocument(validationResponse.Template, processedTemplate)
+			if len(validationResponse.SchemaErrors) > 0 {
+				validationResponse.FailedStage = StageSchema
+			}
+			if validationRequest.Lint != nil {
+				validationResponse.Warnings, _ = LintPipeline(processedTemplate, *validationRequest.Lint)
+			}
 		}
 		log.Debug("Output template: \n", outputTemplate)
+
+		if validationRequest.Strict && validationResponse.Error == "" {
+			checkUndefinedVariables(&validationRequest, &validationResponse)
+		}
 	}
 
 	return validationResponse
 }
 
-func validateGoTemplate(validationRequest *ValidationRequest) (string, error) {
-	buffer := new(bytes.Buffer)
-	parsedTemplate, _ := template.New("test").Funcs(VelaFuncMap()).Funcs(sprig.TxtFuncMap()).Parse(validationRequest.Template)
-	err := parsedTemplate.Execute(buffer, validationRequest.Parameters)
+// Reports the variables accessed by a go template that were not supplied and fails if any of them is not rescued
+func checkUndefinedVariables(validationRequest *ValidationRequest, validationResponse *ValidationResponse) {
+	if validationRequest.Type == "starlark" || validationRequest.Type == "pipeline" {
+		return
+	}
+
+	undefinedVariables, err := findUndefinedVariables(validationRequest)
+	if err != nil {
+		validationResponse.Message = "Invalid template"
+		validationResponse.Error = err.Error()
+		validationResponse.FailedStage = StageExecute
+		return
+	}
+	validationResponse.UndefinedVariables = undefinedVariables
+
+	unrescuedVariables := []string{}
+	for _, undefinedVariable := range undefinedVariables {
+		if undefinedVariable.RescuedBy == "" {
+			unrescuedVariables = append(unrescuedVariables,
+				fmt.Sprintf("'%s' at line %d, column %d", undefinedVariable.Name, undefinedVariable.Line, undefinedVariable.Column))
+		}
+	}
 
-	outputTemplate := buffer.String()
-	return outputTemplate, err
+	if len(unrescuedVariables) > 0 {
+		validationResponse.Message = "template accesses undefined variables"
+		validationResponse.Error = "undefined variables: " + strings.Join(unrescuedVariables, ", ")
+		validationResponse.FailedStage = StageExecute
+	}
 }
 
-func validateStarlarkTemplate(validationRequest *ValidationRequest) (string, error) {
-	context := map[string]interface{}{
-		"vars": validationRequest.Parameters,
+// Processes the template using the engine matching its type
+func renderTemplate(ctx context.Context, validationRequest *ValidationRequest, validationResponse *ValidationResponse) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", validationRequest.Limits.contextError(err)
+	}
+
+	switch validationRequest.Type {
+	case "starlark":
+		return validateStarlarkTemplate(ctx, validationRequest, validationResponse)
+	case "pipeline":
+		return expandPipeline(ctx, validationRequest, validationResponse)
+	default:
+		return validateGoTemplate(ctx, validationRequest, validationResponse)
 	}
-	contextJson, _ := jsoniter.Marshal(context)
+}
 
-	requestBody := validationRequest.Template + "\nctx = " + string(contextJson) + "\nprint(main(ctx))"
-	file, _ := os.CreateTemp("", "exec_starlark")
-	os.WriteFile(file.Name(), []byte(requestBody), 0755)
+func validateGoTemplate(ctx context.Context, validationRequest *ValidationRequest, validationResponse *ValidationResponse) (string, error) {
+	functions, err := GoTemplateFuncMap(validationRequest.VelaVersion)
+	if err != nil {
+		return "", err
+	}
 
-	output := ""
-	thread := &starlark.Thread{
-		Print: func(thread *starlark.Thread, msg string) {
-			output = msg
-		},
-		Load: starlib.Loader,
+	if validationRequest.Sandbox != nil {
+		functions = validationRequest.Sandbox.restrictFunctions(functions)
 	}
+	if _, ok := functions["vela"]; ok && validationRequest.BuildContext != nil {
+		functions["vela"] = validationRequest.BuildContext.velaFunction()
+	}
+	limits := validationRequest.Limits
+	functions[rangeLimitFunction] = limits.rangeLimit(ctx)
+
+	buffer := new(bytes.Buffer)
+	if validationRequest.sourceMap != nil {
+		functions[sourceMarkFunction] = validationRequest.sourceMap.goFunction(buffer)
+	}
+
+	var recorder *branchRecorder
+	if validationRequest.Coverage {
+		recorder = &branchRecorder{}
+		functions[coverBranchFunction] = recorder.goFunction()
+	}
+
+	functions = limits.limitFunctions(ctx, functions)
+
+	parsedTemplate, err := template.New("test").Funcs(functions).Parse(validationRequest.Template)
+	if err != nil {
+		return "", explainUndefinedFunction(err, validationRequest)
+	}
+
+	// Found before the template is instrumented
+	validationResponse.UnescapedExpressions = findUnescapedExpressions(validationRequest, parsedTemplate.Tree.Root)
+
+	for _, definedTemplate := range parsedTemplate.Templates() {
+		limitRanges(definedTemplate.Tree.Root)
+		if validationRequest.sourceMap != nil {
+			validationRequest.sourceMap.instrumentGoTemplate(definedTemplate.Tree.Root, validationRequest.Template)
+		}
+		if recorder != nil {
+			recorder.instrumentGoTemplate(definedTemplate.Tree.Root, validationRequest.Template)
+		}
+	}
+	if recorder != nil {
+		defer func() {
+			validationResponse.Coverage = recorder.coverage()
+		}()
+	}
+
+	// Executed in the background so that a template that doesn't write output or range is also stopped on time.
+	// Once cancelled, the functions of the template and the writer fail, which ends the execution at the next function
+	// call, range or write. A function call in progress, like a sprig function over a large list, still runs to its end
+	writer := &limitedWriter{ctx: ctx, writer: buffer, limits: limits, remaining: limits.MaxOutputBytes}
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				result <- fmt.Errorf("template execution failed: %v", recovered)
+			}
+		}()
+
+		result <- parsedTemplate.Execute(writer, validationRequest.Parameters)
+	}()
+
+	select {
+	case err = <-result:
+		return buffer.String(), err
+	case <-ctx.Done():
+		return "", limits.contextError(ctx.Err())
+	}
+}
 
-	outputTemplate := make([]byte, 1)
-	_, err := starlark.ExecFile(thread, file.Name(), nil, nil)
-	if err == nil {
-		outputObject := make(map[string]interface{})
-		json.Unmarshal([]byte(output), &outputObject)
-		outputTemplate, err = yaml.Marshal(&outputObject)
+func joinVariableErrors(variableErrors []VariableError) string {
+	messages := make([]string, len(variableErrors))
+	for index, variableError := range variableErrors {
+		messages[index] = variableError.String()
 	}
 
-	return string(outputTemplate), err
+	return strings.Join(messages, "; ")
 }
 
 func handlePanic() {
@@ -120,7 +308,8 @@ func VelaFuncMap() template.FuncMap {
 	})
 }
 
-// Simulates the 'vela' function during validation, so as to not fail templates that use it
+// Simulates the 'vela' function during validation, so as to not fail templates that use it.
+// Returns a placeholder like '${VELA_BUILD_BRANCH}' as the build context is not known
 func vela(variableName string) (envVariable string, err error) {
 	if variableName != "" {
 		envVariable = "${" + strings.ToUpper(variableName) + "}"
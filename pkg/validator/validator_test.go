@@ -4,7 +4,10 @@
 package validator
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
+	"sync"
 	"testing"
 
 	"github.com/devatherock/vela-template-tester/test/helper"
@@ -24,7 +27,7 @@ func TestValidateSuccess(test *testing.T) {
 	}
 	validationRequest.Parameters = parameters
 
-	validationResponse := Validate(validationRequest)
+	validationResponse := Validate(context.Background(), validationRequest)
 	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
 	assert.Equal(test, "", validationResponse.Error)
 
@@ -44,7 +47,7 @@ func TestValidateVelaFunctionSuccess(test *testing.T) {
 	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_vela_function_template.yml"))
 	validationRequest.Template = string(input)
 
-	validationResponse := Validate(validationRequest)
+	validationResponse := Validate(context.Background(), validationRequest)
 	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
 	assert.Equal(test, "", validationResponse.Error)
 
@@ -64,7 +67,7 @@ func TestValidateVelaFunctionFailure(test *testing.T) {
 	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_vela_fn_empty_variable_template.yml"))
 	validationRequest.Template = string(input)
 
-	validationResponse := Validate(validationRequest)
+	validationResponse := Validate(context.Background(), validationRequest)
 	assert.Equal(test, "Invalid template", validationResponse.Message)
 	assert.Contains(test, validationResponse.Error, "environment variable name cannot be empty in 'vela' function")
 }
@@ -80,10 +83,18 @@ func TestValidateParseError(test *testing.T) {
 	}
 	validationRequest.Parameters = parameters
 
-	validationResponse := Validate(validationRequest)
+	validationResponse := Validate(context.Background(), validationRequest)
 	assert.Equal(test, "Invalid template", validationResponse.Message)
-	assert.Equal(test, "Unable to parse template", validationResponse.Error)
+	assert.Equal(test, "template: test:4: unterminated quoted string", validationResponse.Error)
 	assert.Equal(test, "", validationResponse.Template)
+	assert.Equal(test, []TemplateDiagnostic{
+		{
+			Stage:   "parse",
+			Line:    4,
+			Message: "unterminated quoted string",
+			Excerpt: "   4 |       branch: {{ default \"[ master, v1 ]' .notification_branch }}",
+		},
+	}, validationResponse.Diagnostics)
 }
 
 func TestValidateInvalidTemplate(test *testing.T) {
@@ -97,7 +108,7 @@ func TestValidateInvalidTemplate(test *testing.T) {
 	}
 	validationRequest.Parameters = parameters
 
-	validationResponse := Validate(validationRequest)
+	validationResponse := Validate(context.Background(), validationRequest)
 	assert.Equal(test, "template is not a valid yaml", validationResponse.Message)
 	assert.Equal(test, "yaml: line 4: did not find expected ',' or ']'", validationResponse.Error)
 
@@ -117,7 +128,7 @@ func TestValidateStarlarkTemplate(test *testing.T) {
 	}
 	validationRequest.Parameters = parameters
 
-	validationResponse := Validate(validationRequest)
+	validationResponse := Validate(context.Background(), validationRequest)
 	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
 	assert.Equal(test, "", validationResponse.Error)
 
@@ -130,3 +141,116 @@ func TestValidateStarlarkTemplate(test *testing.T) {
 
 	assert.Equal(test, expectedOutputMap, processedTemplateMap)
 }
+
+func TestValidateSchemaError(test *testing.T) {
+	validationRequest := ValidationRequest{}
+
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_schema_error_template.yml"))
+	validationRequest.Template = string(input)
+	validationRequest.Parameters = map[string]interface{}{
+		"image": 1.23,
+	}
+
+	validationResponse := Validate(context.Background(), validationRequest)
+	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Equal(test, []SchemaViolation{
+		{"steps[0].image", "expected string, found number"},
+		{"steps[0].ruleset.branch[1]", "expected string, found integer"},
+		{"steps[1].name", "is required"},
+		{"steps[1].pull", "expected string, found boolean"},
+		{"steps[1]", "no commands, environment, parameters, secrets or template provided"},
+	}, validationResponse.SchemaErrors)
+}
+
+func TestValidateFailedStage(test *testing.T) {
+	cases := []struct {
+		validationRequest ValidationRequest
+		expected          string
+	}{
+		{
+			ValidationRequest{Template: "steps:\n  - name: build\n    image: {{ .image }}\n    commands: [ go build ]", Parameters: map[string]interface{}{"image": "golang"}},
+			"",
+		},
+		{
+			ValidationRequest{Template: "steps: {{ .steps", Parameters: map[string]interface{}{}},
+			StageParse,
+		},
+		{
+			ValidationRequest{Template: `steps: {{ if not .steps }}{{ fail "steps are required" }}{{ end }}`, Parameters: map[string]interface{}{}},
+			StageExecute,
+		},
+		{
+			ValidationRequest{Template: "steps: [ {{ .steps }}", Parameters: map[string]interface{}{"steps": "build"}},
+			StageYaml,
+		},
+		{
+			ValidationRequest{Template: "steps:\n  - name: build", Parameters: map[string]interface{}{}},
+			StageSchema,
+		},
+		{
+			ValidationRequest{Template: "steps: {{ .steps }}", Parameters: map[string]interface{}{}, Strict: true},
+			StageExecute,
+		},
+		{
+			ValidationRequest{
+				Template:       "steps: {{ .steps }}",
+				Parameters:     map[string]interface{}{},
+				VariableSchema: "variables:\n  steps:\n    type: array\n    required: true",
+			},
+			StageVariables,
+		},
+		{
+			ValidationRequest{Template: "def main(ctx):\n  fail('no steps')", Type: "starlark"},
+			StageExecute,
+		},
+	}
+
+	for _, data := range cases {
+		validationResponse := Validate(context.Background(), data.validationRequest)
+		assert.Equal(test, data.expected, validationResponse.FailedStage, data.validationRequest.Template)
+	}
+}
+
+func TestValidateConcurrently(test *testing.T) {
+	goTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_template.yml"))
+	starlarkTemplate, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_starlark_template.py"))
+
+	requests := []ValidationRequest{}
+	for index := 0; index < 8; index++ {
+		requests = append(requests,
+			ValidationRequest{
+				Template:    string(goTemplate),
+				Parameters:  map[string]interface{}{"notification_branch": fmt.Sprintf("branch-%d", index), "notification_event": "push"},
+				VelaVersion: "0.23.0",
+				Strict:      true,
+			},
+			ValidationRequest{
+				Template:   string(starlarkTemplate),
+				Type:       "starlark",
+				Parameters: map[string]interface{}{"image": fmt.Sprintf("go:1.%d", index)},
+				Limits:     DefaultLimits(),
+			},
+		)
+	}
+
+	responses := make([]ValidationResponse, len(requests))
+	waitGroup := sync.WaitGroup{}
+	for index, request := range requests {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			responses[index] = Validate(context.Background(), request)
+		}()
+	}
+	waitGroup.Wait()
+
+	for index, response := range responses {
+		assert.Equal(test, "", response.Error)
+		if index%2 == 0 {
+			assert.Contains(test, response.Template, fmt.Sprintf("branch: branch-%d", index/2))
+		} else {
+			assert.Contains(test, response.Template, fmt.Sprintf("image: go:1.%d", index/2))
+		}
+	}
+}
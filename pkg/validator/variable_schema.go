@@ -0,0 +1,252 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Declares the type and constraints of a template variable. Nested variables are declared
+// in 'properties' for objects and in 'items' for arrays
+type VariableSchema struct {
+	Type        string                     `yaml:"type,omitempty"`
+	Description string                     `yaml:"description,omitempty"`
+	Required    bool                       `yaml:"required,omitempty"`
+	Enum        []interface{}              `yaml:"enum,omitempty"`
+	Default     interface{}                `yaml:"default,omitempty"` // Value of the variable when it is not supplied
+	Items       *VariableSchema            `yaml:"items,omitempty"`
+	Properties  map[string]*VariableSchema `yaml:"properties,omitempty"`
+}
+
+// The variable schema file shipped along with a template
+type VariableSchemaFile struct {
+	Variables map[string]*VariableSchema `yaml:"variables"`
+}
+
+// A supplied variable that does not match the variable schema
+type VariableError struct {
+	Name    string `yaml:"name"`
+	Message string `yaml:"message"`
+}
+
+func (variableError VariableError) String() string {
+	return variableError.Name + ": " + variableError.Message
+}
+
+// Validates the supplied parameters against a variable schema and returns all errors
+func ValidateVariables(variableSchema string, parameters interface{}) ([]VariableError, error) {
+	root, err := parseVariableSchema(variableSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+
+	return root.validateObject("", parameters), nil
+}
+
+// Returns the parameters along with the defaults of the variables of a valid variable schema that are not supplied
+func applyVariableDefaults(variableSchema string, parameters interface{}) interface{} {
+	root, err := parseVariableSchema(variableSchema)
+	if err != nil {
+		return parameters
+	}
+
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+
+	return root.applyDefaults(parameters)
+}
+
+// Parses a variable schema into the schema of an object with the variables as properties. The defaults
+// of the variables need to match their schema
+func parseVariableSchema(variableSchema string) (*VariableSchema, error) {
+	schemaFile := VariableSchemaFile{}
+	err := yaml.Unmarshal([]byte(variableSchema), &schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable schema: %s", err.Error())
+	}
+
+	root := &VariableSchema{Type: "object", Properties: schemaFile.Variables}
+	if defaultErrors := root.checkDefaults(""); len(defaultErrors) > 0 {
+		return nil, fmt.Errorf("invalid variable schema: defaults do not match the schema: %s", joinVariableErrors(defaultErrors))
+	}
+
+	return root, nil
+}
+
+// Validates the defaults of a schema and of its items and properties
+func (schema *VariableSchema) checkDefaults(name string) []VariableError {
+	variableErrors := []VariableError{}
+	if schema.Default != nil {
+		variableErrors = append(variableErrors, schema.validate(name, schema.Default)...)
+	}
+	if schema.Items != nil {
+		variableErrors = append(variableErrors, schema.Items.checkDefaults(name+"[]")...)
+	}
+	for _, propertyName := range sortedStringKeys(schema.Properties) {
+		if propertySchema := schema.Properties[propertyName]; propertySchema != nil {
+			variableErrors = append(variableErrors, propertySchema.checkDefaults(joinVariablePath(name, propertyName))...)
+		}
+	}
+
+	return variableErrors
+}
+
+// Returns a copy of an object in which the properties that are not supplied are set to their defaults, if any
+func (schema *VariableSchema) applyDefaults(value interface{}) interface{} {
+	properties, ok := toStringMap(value)
+	if !ok || schema.Properties == nil {
+		return value
+	}
+
+	withDefaults := make(map[string]interface{}, len(properties))
+	for propertyName, propertyValue := range properties {
+		withDefaults[propertyName] = propertyValue
+	}
+	for propertyName, propertySchema := range schema.Properties {
+		if propertySchema == nil {
+			continue
+		}
+
+		propertyValue := withDefaults[propertyName]
+		if propertyValue == nil {
+			propertyValue = propertySchema.Default
+		}
+		if propertyValue != nil {
+			withDefaults[propertyName] = propertySchema.applyDefaults(propertyValue)
+		}
+	}
+
+	return withDefaults
+}
+
+func (schema *VariableSchema) validate(name string, value interface{}) []VariableError {
+	if schema.Type != "" && !matchesType(schema.Type, value) {
+		return []VariableError{{name, fmt.Sprintf("expected %s, found %s", schema.Type, variableType(value))}}
+	}
+
+	if len(schema.Enum) > 0 {
+		allowed := false
+		for _, allowedValue := range schema.Enum {
+			if fmt.Sprint(allowedValue) == fmt.Sprint(value) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return []VariableError{{name, fmt.Sprintf("'%v' is not one of %v", value, schema.Enum)}}
+		}
+	}
+
+	if schema.Items != nil {
+		if items, ok := value.([]interface{}); ok {
+			variableErrors := []VariableError{}
+			for index, item := range items {
+				variableErrors = append(variableErrors, schema.Items.validate(fmt.Sprintf("%s[%d]", name, index), item)...)
+			}
+			return variableErrors
+		}
+	}
+
+	if schema.Properties != nil {
+		return schema.validateObject(name, value)
+	}
+
+	return nil
+}
+
+// Validates the declared properties of an object. Properties that are not declared are reported
+func (schema *VariableSchema) validateObject(name string, value interface{}) []VariableError {
+	properties, ok := toStringMap(value)
+	if !ok {
+		return []VariableError{{name, fmt.Sprintf("expected object, found %s", variableType(value))}}
+	}
+
+	variableErrors := []VariableError{}
+	for _, propertyName := range sortedStringKeys(schema.Properties) {
+		propertySchema := schema.Properties[propertyName]
+		if propertySchema == nil {
+			propertySchema = &VariableSchema{}
+		}
+		propertyValue, present := properties[propertyName]
+
+		if !present || propertyValue == nil {
+			if propertySchema.Required {
+				variableErrors = append(variableErrors, VariableError{joinVariablePath(name, propertyName), "is required"})
+			}
+			continue
+		}
+
+		variableErrors = append(variableErrors, propertySchema.validate(joinVariablePath(name, propertyName), propertyValue)...)
+	}
+
+	for _, propertyName := range sortedStringKeys(properties) {
+		if _, declared := schema.Properties[propertyName]; !declared {
+			variableErrors = append(variableErrors, VariableError{joinVariablePath(name, propertyName), "is not declared in the variable schema"})
+		}
+	}
+
+	return variableErrors
+}
+
+func matchesType(expectedType string, value interface{}) bool {
+	actualType := variableType(value)
+
+	// Integers are numbers too
+	return actualType == expectedType || (expectedType == "number" && actualType == "integer")
+}
+
+// Returns the variable schema type of a parameter parsed from yaml or json
+func variableType(value interface{}) string {
+	switch typedValue := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		// Numbers from json are always parsed as float64
+		if typedValue == math.Trunc(typedValue) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "object"
+	case nil:
+		return "null"
+	default:
+		return strings.TrimPrefix(fmt.Sprintf("%T", value), "*")
+	}
+}
+
+// Converts maps parsed from yaml or json into a map with string keys
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return typedValue, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typedValue))
+		for key, entry := range typedValue {
+			converted[fmt.Sprint(key)] = entry
+		}
+		return converted, true
+	case OrderedMap:
+		converted := make(map[string]interface{}, len(typedValue))
+		for _, entry := range typedValue {
+			converted[fmt.Sprint(entry.Key)] = entry.Value
+		}
+		return converted, true
+	}
+
+	return nil, false
+}
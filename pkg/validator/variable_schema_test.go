@@ -0,0 +1,228 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVariables(test *testing.T) {
+	variableSchema, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.schema.yml"))
+
+	cases := []struct {
+		parameters interface{}
+		expected   []VariableError
+	}{
+		{
+			map[string]interface{}{
+				"go_version": "1.23",
+				"pull":       "always",
+				"commands":   []interface{}{"go build", "go test"},
+			},
+			[]VariableError{},
+		},
+		{
+			nil,
+			[]VariableError{
+				{"commands", "is required"},
+				{"go_version", "is required"},
+			},
+		},
+		{
+			map[interface{}]interface{}{
+				"go_version": 1.23,
+				"pull":       "sometimes",
+				"commands":   []interface{}{"go build", 1},
+				"go_verison": "1.23",
+			},
+			[]VariableError{
+				{"commands[1]", "expected string, found integer"},
+				{"go_version", "expected string, found number"},
+				{"pull", "'sometimes' is not one of [always not_present on_start never]"},
+				{"go_verison", "is not declared in the variable schema"},
+			},
+		},
+		{
+			[]interface{}{"go build"},
+			[]VariableError{
+				{"", "expected object, found array"},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		variableErrors, err := ValidateVariables(string(variableSchema), data.parameters)
+
+		assert.Nil(test, err)
+		assert.Equal(test, data.expected, variableErrors)
+	}
+}
+
+func TestValidateVariablesNestedSchema(test *testing.T) {
+	variableSchema := `
+variables:
+  slack:
+    type: object
+    properties:
+      channel:
+        type: string
+        required: true
+      retries:
+        type: number
+  labels:
+`
+
+	variableErrors, err := ValidateVariables(variableSchema, map[string]interface{}{
+		"slack": map[string]interface{}{
+			"retries": float64(3),
+			"icon":    "robot",
+		},
+		"labels": []interface{}{"team"},
+	})
+
+	assert.Nil(test, err)
+	assert.Equal(test, []VariableError{
+		{"slack.channel", "is required"},
+		{"slack.icon", "is not declared in the variable schema"},
+	}, variableErrors)
+}
+
+func TestValidateVariablesInvalidSchema(test *testing.T) {
+	_, err := ValidateVariables("variables: [", nil)
+
+	assert.Equal(test, "invalid variable schema: yaml: line 1: did not find expected node content", err.Error())
+}
+
+func TestValidateVariablesInvalidDefault(test *testing.T) {
+	variableSchema := `
+variables:
+  pull:
+    type: string
+    enum: [ always, never ]
+    default: sometimes
+  slack:
+    type: object
+    properties:
+      retries:
+        type: number
+        default: three
+`
+
+	_, err := ValidateVariables(variableSchema, nil)
+
+	assert.Equal(test, "invalid variable schema: defaults do not match the schema: "+
+		"pull: 'sometimes' is not one of [always never]; slack.retries: expected number, found string", err.Error())
+}
+
+func TestApplyVariableDefaults(test *testing.T) {
+	variableSchema := `
+variables:
+  pull:
+    type: string
+    default: not_present
+  slack:
+    type: object
+    properties:
+      channel:
+        type: string
+        default: builds
+      retries:
+        type: number
+`
+
+	cases := []struct {
+		parameters interface{}
+		expected   interface{}
+	}{
+		{
+			nil,
+			map[string]interface{}{
+				"pull": "not_present",
+			},
+		},
+		{
+			map[interface{}]interface{}{
+				"pull":  "always",
+				"slack": map[interface{}]interface{}{"retries": 3},
+			},
+			map[string]interface{}{
+				"pull": "always",
+				"slack": map[string]interface{}{
+					"channel": "builds",
+					"retries": 3,
+				},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		assert.Equal(test, data.expected, applyVariableDefaults(variableSchema, data.parameters))
+	}
+}
+
+func TestValidateWithVariableSchema(test *testing.T) {
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.yml"))
+	variableSchema, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.schema.yml"))
+
+	validationResponse := Validate(context.Background(), ValidationRequest{
+		Template:       string(input),
+		VariableSchema: string(variableSchema),
+		Parameters: map[string]interface{}{
+			"go_version": "1.23",
+			"commands":   []interface{}{"go build"},
+		},
+	})
+	assert.Equal(test, "template is a valid yaml", validationResponse.Message)
+	assert.Equal(test, "", validationResponse.Error)
+	assert.Equal(test, "steps:\n  - name: build\n    image: golang:1.23\n    pull: not_present\n    commands:\n      - go build", validationResponse.Template)
+
+	validationResponse = Validate(context.Background(), ValidationRequest{
+		Template:       string(input),
+		VariableSchema: string(variableSchema),
+		Parameters: map[string]interface{}{
+			"go_version": 1.23,
+		},
+	})
+	assert.Equal(test, "Invalid variables", validationResponse.Message)
+	assert.Equal(test, "variables do not match the variable schema: commands: is required; go_version: expected string, found number", validationResponse.Error)
+	assert.Equal(test, "", validationResponse.Template)
+	assert.Equal(test, []VariableError{
+		{"commands", "is required"},
+		{"go_version", "expected string, found number"},
+	}, validationResponse.VariableErrors)
+}
+
+func TestExpandPipelineWithVariableSchema(test *testing.T) {
+	input, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.yml"))
+	variableSchema, _ := ioutil.ReadFile(helper.AbsolutePath("test/testdata/input_variable_schema_template.schema.yml"))
+
+	_, err := expandPipeline(context.Background(), &ValidationRequest{
+		Template: `
+templates:
+  - name: go
+    source: templates/go.yml
+steps:
+  - name: build
+    template:
+      name: go
+      vars:
+        go_version: "1.23"
+        command: go build
+`,
+		Templates: map[string]string{
+			"go": string(input),
+		},
+		VariableSchemas: map[string]string{
+			"go": string(variableSchema),
+		},
+	}, &ValidationResponse{})
+
+	assert.Equal(test, "vars of step 'build' do not match the variable schema of template 'go': "+
+		"commands: is required; command: is not declared in the variable schema", err.Error())
+}
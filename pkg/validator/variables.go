@@ -0,0 +1,423 @@
+package validator
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"go.starlark.net/syntax"
+)
+
+// A variable consumed by a template
+type TemplateVariable struct {
+	Name    string      `yaml:"name"`
+	Default interface{} `yaml:"default,omitempty"`
+	Lines   []int       `yaml:"lines"`
+}
+
+type VariablesResponse struct {
+	Message   string
+	Error     string             `yaml:",omitempty"`
+	Variables []TemplateVariable `yaml:",omitempty"`
+}
+
+// Collects the variables consumed by a template in the order of their first use
+type variableCollector struct {
+	source      string
+	velaVersion string // Vela version whose function map go templates are parsed with
+	variables   []*TemplateVariable
+	byName      map[string]*TemplateVariable
+	expressions map[string]string // Text of the first go template expression of each variable, like '.slack.channel'
+}
+
+func newVariableCollector(source string) *variableCollector {
+	return &variableCollector{
+		source:      source,
+		byName:      make(map[string]*TemplateVariable),
+		expressions: make(map[string]string),
+	}
+}
+
+// Statically lists the variables consumed by a go or starlark template, along with their defaults
+func ListVariables(validationRequest ValidationRequest) VariablesResponse {
+	collector := newVariableCollector(validationRequest.Template)
+	collector.velaVersion = validationRequest.VelaVersion
+
+	var err error
+	if validationRequest.Type == "starlark" {
+		err = collector.collectStarlarkVariables()
+	} else {
+		err = collector.collectGoTemplateVariables()
+	}
+
+	if err != nil {
+		return VariablesResponse{
+			Message: "Invalid template",
+			Error:   err.Error(),
+		}
+	}
+
+	variablesResponse := VariablesResponse{
+		Message:   "template variables",
+		Variables: []TemplateVariable{},
+	}
+	for _, variable := range collector.variables {
+		variablesResponse.Variables = append(variablesResponse.Variables, *variable)
+	}
+
+	return variablesResponse
+}
+
+// Records a use of a variable in a go template along with the text of the expression that accesses it
+func (collector *variableCollector) addExpression(name string, position parse.Pos, expression string, defaultValue interface{}) {
+	collector.add(name, collector.line(position), defaultValue)
+	if _, ok := collector.expressions[name]; !ok && name != "" {
+		collector.expressions[name] = expression
+	}
+}
+
+// Records a use of a variable. The first default found for a variable is retained
+func (collector *variableCollector) add(name string, line int, defaultValue interface{}) {
+	if name == "" {
+		return
+	}
+
+	variable, ok := collector.byName[name]
+	if !ok {
+		variable = &TemplateVariable{Name: name, Lines: []int{}}
+		collector.byName[name] = variable
+		collector.variables = append(collector.variables, variable)
+	}
+
+	if len(variable.Lines) == 0 || variable.Lines[len(variable.Lines)-1] != line {
+		variable.Lines = append(variable.Lines, line)
+	}
+	if variable.Default == nil {
+		variable.Default = defaultValue
+	}
+}
+
+func (collector *variableCollector) collectGoTemplateVariables() error {
+	parsedTemplate, err := parseGoTemplate(collector.source, collector.velaVersion)
+	if err != nil {
+		return err
+	}
+
+	collector.walkGoTemplate(parsedTemplate.Tree.Root, "", true, map[string]string{})
+	return nil
+}
+
+// Walks a go template node. 'dot' is the variable path of dot, if known. 'declarations' holds
+// the variable paths of template variables like '$element'
+func (collector *variableCollector) walkGoTemplate(node parse.Node, dot string, dotKnown bool, declarations map[string]string) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for _, child := range typedNode.Nodes {
+			collector.walkGoTemplate(child, dot, dotKnown, declarations)
+		}
+	case *parse.ActionNode:
+		collector.walkGoPipe(typedNode.Pipe, dot, dotKnown, declarations)
+		collector.declare(typedNode.Pipe, dot, dotKnown, declarations, "")
+	case *parse.IfNode:
+		collector.walkGoPipe(typedNode.Pipe, dot, dotKnown, declarations)
+		collector.walkGoTemplate(typedNode.List, dot, dotKnown, declarations)
+		collector.walkGoTemplate(typedNode.ElseList, dot, dotKnown, declarations)
+	case *parse.WithNode:
+		collector.walkGoPipe(typedNode.Pipe, dot, dotKnown, declarations)
+		innerDot, innerDotKnown := collector.pipePath(typedNode.Pipe, dot, dotKnown, declarations)
+
+		innerDeclarations := copyDeclarations(declarations)
+		collector.declare(typedNode.Pipe, dot, dotKnown, innerDeclarations, "")
+		collector.walkGoTemplate(typedNode.List, innerDot, innerDotKnown, innerDeclarations)
+		collector.walkGoTemplate(typedNode.ElseList, dot, dotKnown, declarations)
+	case *parse.RangeNode:
+		collector.walkGoPipe(typedNode.Pipe, dot, dotKnown, declarations)
+		innerDot, innerDotKnown := collector.pipePath(typedNode.Pipe, dot, dotKnown, declarations)
+		innerDot += "[]"
+
+		innerDeclarations := copyDeclarations(declarations)
+		collector.declare(typedNode.Pipe, dot, dotKnown, innerDeclarations, "[]")
+		collector.walkGoTemplate(typedNode.List, innerDot, innerDotKnown, innerDeclarations)
+		collector.walkGoTemplate(typedNode.ElseList, dot, dotKnown, declarations)
+	case *parse.TemplateNode:
+		collector.walkGoPipe(typedNode.Pipe, dot, dotKnown, declarations)
+	}
+}
+
+// Records the variables used in a pipeline
+func (collector *variableCollector) walkGoPipe(pipe *parse.PipeNode, dot string, dotKnown bool, declarations map[string]string) {
+	if pipe == nil {
+		return
+	}
+
+	for index, command := range pipe.Cmds {
+		var pipedDefault interface{}
+
+		// A variable piped into 'default', like '.name | default "build"'
+		if index+1 < len(pipe.Cmds) && functionName(pipe.Cmds[index+1]) == "default" && len(pipe.Cmds[index+1].Args) == 2 {
+			pipedDefault = literalValue(pipe.Cmds[index+1].Args[1])
+		}
+
+		switch functionName(command) {
+		case "index":
+			// Only the full path of the indexed variable is recorded, like 'index .labels "team"'
+			if len(command.Args) > 1 {
+				path, ok := collector.indexPath(command.Args[1:], dot, dotKnown, declarations)
+				if ok {
+					collector.addExpression(path, command.Args[1].Position(), command.String(), pipedDefault)
+				} else {
+					collector.walkGoArguments(command.Args[1:], dot, dotKnown, declarations, nil)
+				}
+			}
+		case "default":
+			// The last argument is the variable and the one before it the default, like 'default "build" .name'
+			if len(command.Args) == 3 {
+				collector.walkGoArguments(command.Args[2:], dot, dotKnown, declarations, literalValue(command.Args[1]))
+				collector.walkGoArguments(command.Args[1:2], dot, dotKnown, declarations, nil)
+			} else {
+				collector.walkGoArguments(command.Args[1:], dot, dotKnown, declarations, nil)
+			}
+		default:
+			collector.walkGoArguments(command.Args, dot, dotKnown, declarations, pipedDefault)
+		}
+	}
+}
+
+func (collector *variableCollector) walkGoArguments(arguments []parse.Node, dot string, dotKnown bool,
+	declarations map[string]string, defaultValue interface{}) {
+	for _, argument := range arguments {
+		switch typedArgument := argument.(type) {
+		case *parse.FieldNode, *parse.VariableNode:
+			if path, ok := collector.nodePath(argument, dot, dotKnown, declarations); ok {
+				collector.addExpression(path, argument.Position(), argument.String(), defaultValue)
+			}
+		case *parse.PipeNode:
+			collector.walkGoPipe(typedArgument, dot, dotKnown, declarations)
+		}
+	}
+}
+
+// Returns the variable path of a field or variable node, like 'slack.channel'
+func (collector *variableCollector) nodePath(node parse.Node, dot string, dotKnown bool, declarations map[string]string) (string, bool) {
+	switch typedNode := node.(type) {
+	case *parse.DotNode:
+		return dot, dotKnown
+	case *parse.FieldNode:
+		if !dotKnown {
+			return "", false
+		}
+		return joinVariablePath(dot, typedNode.Ident...), true
+	case *parse.VariableNode:
+		if typedNode.Ident[0] == "$" {
+			return joinVariablePath("", typedNode.Ident[1:]...), true
+		}
+		if path, ok := declarations[typedNode.Ident[0]]; ok {
+			return joinVariablePath(path, typedNode.Ident[1:]...), true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the variable path of the arguments of an 'index' call, like 'labels.team' or 'servers[0]'
+func (collector *variableCollector) indexPath(arguments []parse.Node, dot string, dotKnown bool, declarations map[string]string) (string, bool) {
+	path, ok := collector.nodePath(arguments[0], dot, dotKnown, declarations)
+	if !ok {
+		return "", false
+	}
+
+	for _, key := range arguments[1:] {
+		switch typedKey := key.(type) {
+		case *parse.StringNode:
+			path = joinVariablePath(path, typedKey.Text)
+		case *parse.NumberNode:
+			path += "[" + typedKey.Text + "]"
+		default:
+			return "", false
+		}
+	}
+
+	return path, true
+}
+
+// Returns the variable path of a pipeline that is a single variable access, like the one in 'range .steps'
+func (collector *variableCollector) pipePath(pipe *parse.PipeNode, dot string, dotKnown bool, declarations map[string]string) (string, bool) {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+
+	return collector.nodePath(pipe.Cmds[0].Args[0], dot, dotKnown, declarations)
+}
+
+// Records the variable paths of the template variables declared by a pipeline. The last declared
+// variable of a 'range' is the element
+func (collector *variableCollector) declare(pipe *parse.PipeNode, dot string, dotKnown bool, declarations map[string]string, suffix string) {
+	if pipe == nil || len(pipe.Decl) == 0 {
+		return
+	}
+
+	path, ok := collector.pipePath(pipe, dot, dotKnown, declarations)
+	for _, declaration := range pipe.Decl {
+		delete(declarations, declaration.Ident[0])
+	}
+
+	if ok {
+		declarations[pipe.Decl[len(pipe.Decl)-1].Ident[0]] = path + suffix
+	}
+}
+
+func (collector *variableCollector) line(position parse.Pos) int {
+	line, _ := lineAndColumn(collector.source, int(position))
+	return line
+}
+
+func (collector *variableCollector) collectStarlarkVariables() error {
+	file, err := syntax.LegacyFileOptions().Parse(starlarkFileName, collector.source, 0)
+	if err != nil {
+		return err
+	}
+
+	// Names bound to ctx["vars"], starting with the parameter of 'main'
+	contextNames := map[string]bool{"ctx": true}
+	varsNames := map[string]bool{}
+	for _, statement := range file.Stmts {
+		if definition, ok := statement.(*syntax.DefStmt); ok && definition.Name.Name == "main" && len(definition.Params) > 0 {
+			if parameter, ok := definition.Params[0].(*syntax.Ident); ok {
+				contextNames = map[string]bool{parameter.Name: true}
+			}
+		}
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if assignment, ok := node.(*syntax.AssignStmt); ok {
+			if name, ok := assignment.LHS.(*syntax.Ident); ok {
+				if path, ok := starlarkVariablePath(assignment.RHS, contextNames, varsNames); ok && path == "" {
+					varsNames[name.Name] = true
+				}
+			}
+		}
+		return true
+	})
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		expression, ok := node.(syntax.Expr)
+		if !ok {
+			return true
+		}
+
+		path, ok := starlarkVariablePath(expression, contextNames, varsNames)
+		if !ok || path == "" {
+			return true
+		}
+
+		start, _ := expression.Span()
+		var defaultValue interface{}
+		if call, ok := expression.(*syntax.CallExpr); ok && len(call.Args) > 1 {
+			defaultValue = literalValue(call.Args[1])
+		}
+		collector.add(path, int(start.Line), defaultValue)
+
+		// Accesses within the expression are part of the recorded path
+		return false
+	})
+
+	return nil
+}
+
+// Returns the path of a starlark expression within ctx["vars"], like 'image' for ctx["vars"]["image"] or
+// vars.get("image", "alpine"). Returns an empty path for ctx["vars"] itself
+func starlarkVariablePath(expression syntax.Expr, contextNames map[string]bool, varsNames map[string]bool) (string, bool) {
+	switch typedExpression := expression.(type) {
+	case *syntax.Ident:
+		return "", varsNames[typedExpression.Name]
+	case *syntax.ParenExpr:
+		return starlarkVariablePath(typedExpression.X, contextNames, varsNames)
+	case *syntax.IndexExpr:
+		key, isString := literalValue(typedExpression.Y).(string)
+		if identifier, ok := typedExpression.X.(*syntax.Ident); ok && contextNames[identifier.Name] {
+			return "", isString && key == "vars"
+		}
+
+		path, ok := starlarkVariablePath(typedExpression.X, contextNames, varsNames)
+		if !ok {
+			return "", false
+		}
+		if isString {
+			return joinVariablePath(path, key), true
+		}
+		if index, isInteger := literalValue(typedExpression.Y).(int64); isInteger {
+			return path + "[" + strconv.FormatInt(index, 10) + "]", true
+		}
+		return path + "[]", true
+	case *syntax.CallExpr:
+		method, ok := typedExpression.Fn.(*syntax.DotExpr)
+		if !ok || method.Name.Name != "get" || len(typedExpression.Args) == 0 {
+			return "", false
+		}
+		if identifier, ok := method.X.(*syntax.Ident); ok && contextNames[identifier.Name] {
+			key, isString := literalValue(typedExpression.Args[0]).(string)
+			return "", isString && key == "vars"
+		}
+
+		path, ok := starlarkVariablePath(method.X, contextNames, varsNames)
+		key, isString := literalValue(typedExpression.Args[0]).(string)
+		if !ok || !isString {
+			return "", false
+		}
+		return joinVariablePath(path, key), true
+	}
+
+	return "", false
+}
+
+// Returns the value of a literal in a go or starlark template, or nil if the node is not a literal
+func literalValue(node interface{}) interface{} {
+	switch typedNode := node.(type) {
+	case *parse.StringNode:
+		return typedNode.Text
+	case *parse.BoolNode:
+		return typedNode.True
+	case *parse.NumberNode:
+		if typedNode.IsInt {
+			return typedNode.Int64
+		} else if typedNode.IsFloat {
+			return typedNode.Float64
+		}
+	case *syntax.Literal:
+		if bigValue, ok := typedNode.Value.(*big.Int); ok {
+			return bigValue.String()
+		}
+		return typedNode.Value
+	case *syntax.Ident:
+		switch typedNode.Name {
+		case "True":
+			return true
+		case "False":
+			return false
+		}
+	}
+
+	return nil
+}
+
+func joinVariablePath(path string, fields ...string) string {
+	parts := []string{}
+	if path != "" {
+		parts = append(parts, path)
+	}
+
+	return strings.Join(append(parts, fields...), ".")
+}
+
+func copyDeclarations(declarations map[string]string) map[string]string {
+	copied := make(map[string]string, len(declarations))
+	for name, path := range declarations {
+		copied[name] = path
+	}
+
+	return copied
+}
@@ -0,0 +1,112 @@
+//go:build test
+// +build test
+
+package validator
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/devatherock/vela-template-tester/test/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListVariables(test *testing.T) {
+	cases := []struct {
+		inputFile    string
+		templateType string
+		expected     []TemplateVariable
+	}{
+		{
+			"test/testdata/input_variables_template.yml",
+			"",
+			[]TemplateVariable{
+				{"name", "notify", []int{2}},
+				{"image", nil, []int{3}},
+				{"notification_branch", "[ master, v1 ]", []int{5}},
+				{"secrets", nil, []int{6, 7}},
+				{"slack.channel", nil, []int{10}},
+				{"labels.team", nil, []int{11}},
+				{"recipients", nil, []int{12}},
+				{"recipients[].name", nil, []int{13}},
+				{"recipients[].email", nil, []int{13}},
+				{"retries", nil, []int{15}},
+				{"retries.count", int64(3), []int{16}},
+			},
+		},
+		{
+			"test/testdata/input_variables_template.py",
+			"starlark",
+			[]TemplateVariable{
+				{"image", "golang:1.23", []int{3}},
+				{"commands", nil, []int{9}},
+				{"notify", false, []int{13}},
+				{"slack.channel", nil, []int{18}},
+				{"retries", int64(3), []int{19}},
+			},
+		},
+		{
+			"test/testdata/input_template.yml",
+			"",
+			[]TemplateVariable{
+				{"notification_branch", "[ master, v1 ]", []int{10}},
+				{"notification_event", "[ push, tag ]", []int{11}},
+			},
+		},
+	}
+
+	for _, data := range cases {
+		input, _ := ioutil.ReadFile(helper.AbsolutePath(data.inputFile))
+
+		variablesResponse := ListVariables(ValidationRequest{
+			Template: string(input),
+			Type:     data.templateType,
+		})
+
+		assert.Equal(test, "template variables", variablesResponse.Message)
+		assert.Equal(test, "", variablesResponse.Error)
+		assert.Equal(test, data.expected, variablesResponse.Variables)
+	}
+}
+
+func TestListVariablesWithVelaVersion(test *testing.T) {
+	template := "environment:\n  {{- .environment | toYaml | nindent 2 }}"
+
+	variablesResponse := ListVariables(ValidationRequest{Template: template, VelaVersion: "0.17.0"})
+	assert.Equal(test, "", variablesResponse.Error)
+	assert.Equal(test, []TemplateVariable{{Name: "environment", Lines: []int{2}}}, variablesResponse.Variables)
+
+	// 'toYaml' was added to vela in 0.17.0
+	variablesResponse = ListVariables(ValidationRequest{Template: template, VelaVersion: "0.16.0"})
+	assert.Equal(test, "template: test:2: function \"toYaml\" not defined", variablesResponse.Error)
+}
+
+func TestListVariablesError(test *testing.T) {
+	cases := []struct {
+		template      string
+		templateType  string
+		expectedError string
+	}{
+		{
+			"steps: {{ .name | shout }}",
+			"",
+			"template: test:1: function \"shout\" not defined",
+		},
+		{
+			"def main(ctx):\n  return {",
+			"starlark",
+			"template.star:2:11: got newline, want primary expression",
+		},
+	}
+
+	for _, data := range cases {
+		variablesResponse := ListVariables(ValidationRequest{
+			Template: data.template,
+			Type:     data.templateType,
+		})
+
+		assert.Equal(test, "Invalid template", variablesResponse.Message)
+		assert.Equal(test, data.expectedError, variablesResponse.Error)
+		assert.Nil(test, variablesResponse.Variables)
+	}
+}
@@ -0,0 +1,23 @@
+def main(ctx):
+  steps = [
+    {
+      'name': 'build',
+      'image': 'golang:1.23',
+      'commands': [ 'go build' ],
+    },
+  ]
+
+  if ctx["build"]["branch"] == "main" and ctx["build"]["event"] == "push":
+    steps.append({
+      'name': 'publish',
+      'image': 'plugins/docker',
+      'parameters': {
+        'repo': ctx["repo"]["full_name"],
+        'tags': [ ctx["build"]["commit"][:7] ],
+      },
+    })
+
+  return {
+    'version': '1',
+    'steps': steps,
+  }
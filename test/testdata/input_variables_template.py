@@ -0,0 +1,26 @@
+def main(ctx):
+  vars = ctx["vars"]
+  image = vars.get("image", "golang:1.23")
+
+  steps = [
+    {
+      'name': 'build',
+      'image': image,
+      'commands': ctx["vars"]["commands"],
+    },
+  ]
+
+  if vars.get("notify", False):
+    steps.append({
+      'name': 'notify',
+      'image': 'devatherock/simple-slack:0.2.0',
+      'parameters': {
+        'channel': ctx["vars"]["slack"]["channel"],
+        'retries': vars.get("retries", 3),
+      },
+    })
+
+  return {
+    'version': '1',
+    'steps': steps,
+  }